@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+
+	"github.com/crlian/ai-dispatcher/pkg/changes"
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/verify"
+)
+
+// Approval modes for --approve
+const (
+	ApproveAsk           = "ask"
+	ApproveAutoTestsPass = "auto-if-tests-pass"
+)
+
+// Approval decisions for a run or a file
+const (
+	DecisionAccepted = "accepted"
+	DecisionRejected = "rejected"
+	DecisionPartial  = "partial"
+	DecisionNone     = "none" // Nothing changed
+)
+
+// ApprovalResult records which of a run's changes were kept
+type ApprovalResult struct {
+	Mode     string          `json:"mode"`
+	Decision string          `json:"decision"`
+	Files    []*FileDecision `json:"files,omitempty"`
+	Tests    *verify.Result  `json:"tests,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// FileDecision records the decision for one changed file
+type FileDecision struct {
+	Path          string         `json:"path"`
+	Status        changes.Status `json:"status"`
+	Added         int            `json:"added"`
+	Removed       int            `json:"removed"`
+	Decision      string         `json:"decision"`
+	HunksAccepted int            `json:"hunks_accepted,omitempty"`
+	HunksRejected int            `json:"hunks_rejected,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// validateApproveMode checks --approve before anything runs. Asking needs a
+// terminal, so CI has to choose the test-based mode explicitly.
+func validateApproveMode(mode string) error {
+	switch mode {
+	case "":
+		return nil
+	case ApproveAsk:
+		if jsonOutput() || !isatty.IsTerminal(os.Stdin.Fd()) || !isatty.IsTerminal(os.Stdout.Fd()) {
+			return fmt.Errorf("--approve needs a terminal to ask on; use --approve=%s in CI", ApproveAutoTestsPass)
+		}
+		return nil
+	case ApproveAutoTestsPass:
+		return nil
+	default:
+		return fmt.Errorf("invalid --approve %q: must be one of [%s, %s]", mode, ApproveAsk, ApproveAutoTestsPass)
+	}
+}
+
+// reviewChanges compares the tree with the snapshot taken before the run and
+// keeps or reverts the changes according to the approval mode
+func reviewChanges(ctx context.Context, before *changes.Snapshot, mode string) *ApprovalResult {
+	result := &ApprovalResult{Mode: mode, Decision: DecisionNone}
+
+	after, err := changes.Take(before.Dir)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	changed := changes.Compare(before, after)
+	if len(changed) == 0 {
+		return result
+	}
+
+	for _, c := range changed {
+		result.Files = append(result.Files, &FileDecision{
+			Path:    c.Path,
+			Status:  c.Status,
+			Added:   c.Added,
+			Removed: c.Removed,
+		})
+	}
+
+	switch mode {
+	case ApproveAutoTestsPass:
+		result.Tests = runTests(ctx, before.Dir)
+		decideAll(changed, result, result.Tests.Passed)
+	default:
+		askApproval(changed, result)
+	}
+
+	result.Decision = overallDecision(result.Files)
+	return result
+}
+
+// decideAll accepts or rejects every change
+func decideAll(changed []*changes.FileChange, result *ApprovalResult, accept bool) {
+	for i, c := range changed {
+		decideFile(c, result.Files[i], accept)
+	}
+}
+
+// decideFile records a whole-file decision, reverting a rejected file
+func decideFile(c *changes.FileChange, decision *FileDecision, accept bool) {
+	if accept {
+		decision.Decision = DecisionAccepted
+		return
+	}
+	decision.Decision = DecisionRejected
+	if err := c.Revert(); err != nil {
+		decision.Error = err.Error()
+	}
+}
+
+// askApproval shows each file's diff and asks which changes to keep
+func askApproval(changed []*changes.FileChange, result *ApprovalResult) {
+	reader := bufio.NewReader(os.Stdin)
+	bold := color.New(color.Bold).SprintFunc()
+
+	fmt.Println()
+	fmt.Printf("%s %d file(s) changed:\n", bold("Approval"), len(changed))
+	for _, c := range changed {
+		fmt.Printf("   %-8s %s (+%d -%d)\n", c.Status, c.Path, c.Added, c.Removed)
+	}
+	fmt.Println()
+
+	switch prompt(reader, "[a]ccept all, [r]eject all, review [f]ile by file? ", "a", "r", "f") {
+	case "a":
+		decideAll(changed, result, true)
+		return
+	case "r":
+		decideAll(changed, result, false)
+		return
+	}
+
+	for i, c := range changed {
+		decision := result.Files[i]
+		fmt.Println()
+		fmt.Println(colorizeDiff(c.Diff()))
+
+		options := []string{"y", "n"}
+		question := fmt.Sprintf("Keep changes to %s? [y]es, [n]o", c.Path)
+		if len(c.Hunks) > 1 && c.Status == changes.Modified {
+			options = append(options, "h")
+			question += ", choose [h]unks"
+		}
+		if !c.CanRevert() {
+			fmt.Printf("   %s can't be reverted (too large to snapshot); it will be kept\n", c.Path)
+			decideFile(c, decision, true)
+			continue
+		}
+
+		switch prompt(reader, question+"? ", options...) {
+		case "y":
+			decideFile(c, decision, true)
+		case "n":
+			decideFile(c, decision, false)
+		case "h":
+			askHunks(reader, c, decision)
+		}
+	}
+}
+
+// askHunks asks about each hunk of a file and reverts the rejected ones
+func askHunks(reader *bufio.Reader, c *changes.FileChange, decision *FileDecision) {
+	accepted := make([]bool, len(c.Hunks))
+	for i, h := range c.Hunks {
+		fmt.Println()
+		fmt.Println(colorizeDiff(h.String()))
+		accepted[i] = prompt(reader, fmt.Sprintf("Keep hunk %d/%d? [y]es, [n]o? ", i+1, len(c.Hunks)), "y", "n") == "y"
+		if accepted[i] {
+			decision.HunksAccepted++
+		} else {
+			decision.HunksRejected++
+		}
+	}
+
+	switch {
+	case decision.HunksRejected == 0:
+		decision.Decision = DecisionAccepted
+		return
+	case decision.HunksAccepted == 0:
+		decision.Decision = DecisionRejected
+	default:
+		decision.Decision = DecisionPartial
+	}
+	if err := c.ApplyHunks(accepted); err != nil {
+		decision.Error = err.Error()
+	}
+}
+
+// prompt asks until one of the options is given. When input runs out it
+// rejects, so no change is kept without being approved.
+func prompt(reader *bufio.Reader, question string, options ...string) string {
+	for {
+		fmt.Print(question)
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			fmt.Println()
+			if contains(options, "r") {
+				return "r"
+			}
+			return "n"
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if contains(options, answer) {
+			return answer
+		}
+	}
+}
+
+// contains reports whether s is in list
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// overallDecision summarizes the file decisions
+func overallDecision(files []*FileDecision) string {
+	if len(files) == 0 {
+		return DecisionNone
+	}
+	accepted, rejected := 0, 0
+	for _, f := range files {
+		switch f.Decision {
+		case DecisionAccepted:
+			accepted++
+		case DecisionRejected:
+			rejected++
+		}
+	}
+	switch {
+	case accepted == len(files):
+		return DecisionAccepted
+	case rejected == len(files):
+		return DecisionRejected
+	default:
+		return DecisionPartial
+	}
+}
+
+// runTests runs the project's test command in dir. Tests of an interrupted
+// run aren't started, so its changes aren't accepted unchecked.
+func runTests(ctx context.Context, dir string) *verify.Result {
+	command := execTestCmd
+	if command == "" {
+		if cfg, err := config.Load(); err == nil {
+			command = cfg.TestCommand
+		}
+	}
+	if command == "" {
+		command = verify.DetectTestCommand(dir)
+	}
+	if command == "" {
+		return &verify.Result{Output: "no test command configured or detected (set --test-cmd or test_command)"}
+	}
+
+	if ctx.Err() != nil {
+		return &verify.Result{Command: command, ExitCode: -1, Output: fmt.Sprintf("not run: %v", context.Cause(ctx))}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+	return verify.Run(ctx, dir, command)
+}
+
+// printApproval reports which changes were kept
+func printApproval(approval *ApprovalResult) {
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	if approval.Tests != nil {
+		if approval.Tests.Passed {
+			fmt.Printf("   Tests: %s (%s)\n", green("PASSED"), approval.Tests.Command)
+		} else if approval.Tests.Command == "" {
+			fmt.Printf("   Tests: %s\n", yellow(approval.Tests.Output))
+		} else {
+			fmt.Printf("   Tests: %s (%s)\n", red("FAILED"), approval.Tests.Command)
+		}
+	}
+
+	switch approval.Decision {
+	case DecisionNone:
+		fmt.Println("   Approval: no files changed")
+	case DecisionAccepted:
+		fmt.Printf("   Approval: %s all %d changed file(s)\n", green("accepted"), len(approval.Files))
+	case DecisionRejected:
+		fmt.Printf("   Approval: %s all %d changed file(s), changes reverted\n", red("rejected"), len(approval.Files))
+	default:
+		fmt.Printf("   Approval: %s\n", yellow("partially accepted"))
+		for _, f := range approval.Files {
+			fmt.Printf("      %-8s %s\n", f.Decision, f.Path)
+		}
+	}
+
+	for _, f := range approval.Files {
+		if f.Error != "" {
+			fmt.Printf("   %s %s\n", yellow("⚠"), f.Error)
+		}
+	}
+	if approval.Error != "" {
+		fmt.Printf("   %s %s\n", yellow("⚠"), approval.Error)
+	}
+}
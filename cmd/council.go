@@ -5,62 +5,20 @@ import (
 	"fmt"
 	"os"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 	"github.com/crlian/ai-dispatcher/pkg/council"
+	"github.com/crlian/ai-dispatcher/pkg/router"
 	"github.com/crlian/ai-dispatcher/pkg/trackers"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
-// loadingSpinner shows an animated loading indicator
-type loadingSpinner struct {
-	stopChan chan struct{}
-	wg       sync.WaitGroup
-	message  string
-}
-
-func newLoadingSpinner(message string) *loadingSpinner {
-	return &loadingSpinner{
-		stopChan: make(chan struct{}),
-		message:  message,
-	}
-}
-
-func (s *loadingSpinner) Start() {
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		frames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
-		i := 0
-		for {
-			select {
-			case <-s.stopChan:
-				// Clear the spinner line
-				fmt.Print("\r\033[K")
-				return
-			default:
-				if s.message == "" {
-					fmt.Printf("\r%s", frames[i%len(frames)])
-				} else {
-					fmt.Printf("\r%s %s", frames[i%len(frames)], s.message)
-				}
-				i++
-				time.Sleep(80 * time.Millisecond)
-			}
-		}
-	}()
-}
-
-func (s *loadingSpinner) Stop() {
-	close(s.stopChan)
-	s.wg.Wait()
-}
-
-var councilReal bool
+var (
+	councilReal     bool
+	councilStrategy string
+)
 
 // councilCmd represents the council command
 var councilCmd = &cobra.Command{
@@ -93,12 +51,13 @@ Examples:
 
 func init() {
 	councilCmd.Flags().BoolVar(&councilReal, "real", false, "Connect to real AI tools (requires installation)")
+	councilCmd.Flags().StringVar(&councilStrategy, "strategy", "", strategyFlagUsage+" used by plan/ejecuta when no tool was mentioned")
 }
 
 // checkToolAvailability checks which tools are installed and available
 func checkToolAvailability() map[string]bool {
 	available := make(map[string]bool)
-	allTrackers := trackers.GetAllTrackers()
+	allTrackers := loadTrackers()
 
 	for _, tracker := range allTrackers {
 		// Map tool names to standard keys
@@ -173,12 +132,23 @@ func runCouncil(cmd *cobra.Command, args []string) {
 	var orch *council.Orchestrator
 	var availableTools map[string]bool
 
+	strategy, err := loadStrategy(councilStrategy)
+	if err != nil {
+		exitWithError(fmt.Errorf("invalid strategy: %w", err))
+	}
+
 	if useReal {
 		orch = council.NewOrchestrator()
 		orch.SetUseMocks(false)
+		orch.SetProgress(progressMode)
 		// Check which tools are available
 		availableTools = checkToolAvailability()
 		orch.SetAvailableTools(availableTools)
+		analyzer, err := newComplexityAnalyzer()
+		if err != nil {
+			exitWithError(fmt.Errorf("failed to load config: %w", err))
+		}
+		orch.SetRouting(analyzer, router.NewDecisionEngine(loadTrackers(), strategy))
 	} else {
 		orch = council.NewMockOrchestrator()
 		// In mock mode, all tools are "available"
@@ -257,8 +227,8 @@ func runCouncil(cmd *cobra.Command, args []string) {
 			}
 
 			fmt.Println()
-			spinner := newLoadingSpinner("")
-			spinner.Start()
+			spinner := newProgress(os.Stdout, 0)
+			spinner.Start(councilStep("Planning", tool))
 
 			plan, err := orch.Plan(tool)
 			spinner.Stop()
@@ -295,8 +265,8 @@ func runCouncil(cmd *cobra.Command, args []string) {
 
 			fmt.Println()
 			// Start loading spinner for execution
-			spinner := newLoadingSpinner("")
-			spinner.Start()
+			spinner := newProgress(os.Stdout, 0)
+			spinner.Start(councilStep("Executing", tool))
 
 			// Execute with tool
 			result := orch.Execute(tool)
@@ -329,8 +299,8 @@ func runCouncil(cmd *cobra.Command, args []string) {
 			fmt.Println()
 
 			// Start loading spinner
-			spinner := newLoadingSpinner("")
-			spinner.Start()
+			spinner := newProgress(os.Stdout, 0)
+			spinner.Start("Asking " + mentionedTool)
 
 			response := orch.Query(mentionedTool, input)
 			spinner.Stop()
@@ -345,22 +315,21 @@ func runCouncil(cmd *cobra.Command, args []string) {
 			responseChan := orch.Broadcast(input)
 			fmt.Println()
 
-			// Start loading spinner (no message, just the spinner)
-			spinner := newLoadingSpinner("")
-			spinner.Start()
+			// One spinner for the whole broadcast, updated as tools answer
+			spinner := newProgress(os.Stdout, 0)
+			spinner.Start("Asking the council")
 
+			answered := 0
 			for resp := range responseChan {
-				// Stop current spinner
-				spinner.Stop()
+				answered++
+				spinner.Clear()
 
 				// Only display if tool responded successfully
 				if resp.Error == nil && resp.Content != "" {
 					fmt.Println(toolColors[resp.Tool]("["+resp.Tool+"]") + "  " + resp.Content)
 				}
 
-				// Start new spinner for remaining responses
-				spinner = newLoadingSpinner("")
-				spinner.Start()
+				spinner.Update(fmt.Sprintf("Asking the council (%d answered)", answered))
 			}
 
 			// Stop final spinner when channel closes
@@ -370,6 +339,14 @@ func runCouncil(cmd *cobra.Command, args []string) {
 	}
 }
 
+// councilStep describes a step for the spinner, naming the tool when one was chosen
+func councilStep(action, tool string) string {
+	if tool == "" {
+		return action
+	}
+	return action + " with " + tool
+}
+
 // displayPlan renders the plan with Lipgloss styling
 func displayPlan(plan *council.Plan) {
 	// Colors
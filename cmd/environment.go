@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// toolSettings holds the executables, environment and arguments of the tools. Each layer
+// adds to the one before and overrides the variables it sets.
+type toolSettings struct {
+	shared *config.ToolConfig                       // For every tool, from the config
+	tools  map[trackers.ToolType]*config.ToolConfig // For one tool, from the config
+	flags  *config.ToolConfig                       // From --env, --env-allow, --env-deny and after --
+}
+
+// execTools is the tool settings of the run, set by resolveToolSettings
+var execTools = &toolSettings{}
+
+// toolCommands replaces the executable of a tool. Only tests set it, to run
+// a fake agent: the config can't name executables, since a project file
+// comes with whatever repository the dispatcher runs in.
+var toolCommands = map[trackers.ToolType]string{}
+
+// splitPassthrough separates the task from the arguments after --
+func splitPassthrough(cmd *cobra.Command, args []string) (task, passthrough []string) {
+	dash := cmd.ArgsLenAtDash()
+	if dash < 0 {
+		return args, nil
+	}
+	return args[:dash], args[dash:]
+}
+
+// changeDir moves the run to --cwd. Paths given relative to where the
+// dispatcher started are resolved first.
+func changeDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	for _, file := range []struct {
+		flag string
+		path *string
+	}{
+		{"--output-file", &execOutputFile},
+		{"--record", &execRecord},
+	} {
+		if *file.path == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*file.path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", file.flag, err)
+		}
+		*file.path = abs
+	}
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("can't run in --cwd %s: %w", dir, err)
+	}
+	return nil
+}
+
+// resolveToolSettings combines the environment and arguments of the config
+// and the flags. Arguments after -- go to the one tool the run uses.
+func resolveToolSettings(passthrough []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Ignored) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring %s in %s: only the global config and flags can set them\n",
+			strings.Join(cfg.Ignored, ", "), config.ProjectFileName)
+		if slices.Contains(cfg.Ignored, "verify") {
+			fmt.Fprintln(os.Stderr, "         Add this directory to trusted_repos in the global config to run its verify commands")
+		}
+	}
+
+	if len(passthrough) > 0 && len(execRace) > 0 {
+		return fmt.Errorf("--race can't be combined with arguments after --: set them per tool in the config")
+	}
+	if len(passthrough) > 0 && execForce == "" && execWaitFor == "" && !execContinue && execResume == "" {
+		return fmt.Errorf("arguments after -- are passed to one tool: choose it with --force")
+	}
+
+	flags := &config.ToolConfig{
+		Args:     passthrough,
+		Env:      make(map[string]string),
+		EnvAllow: execEnvAllow,
+		EnvDeny:  execEnvDeny,
+	}
+	for _, assignment := range execEnv {
+		key, value, err := delegators.ParseEnvAssignment(assignment)
+		if err != nil {
+			return err
+		}
+		flags.Env[key] = value
+	}
+	shared := &config.ToolConfig{Env: cfg.Env, EnvAllow: cfg.EnvAllow, EnvDeny: cfg.EnvDeny}
+	for _, layer := range []*config.ToolConfig{shared, flags} {
+		if err := validateEnvPatterns(layer); err != nil {
+			return err
+		}
+	}
+
+	tools := make(map[trackers.ToolType]*config.ToolConfig)
+	for name, toolConfig := range cfg.Tools {
+		tool, err := trackers.ValidateToolType(name)
+		if err != nil {
+			return fmt.Errorf("invalid tool in the config: %w", err)
+		}
+		if toolConfig == nil {
+			continue
+		}
+		if err := validateEnvPatterns(toolConfig); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		tools[tool] = toolConfig
+	}
+
+	execTools = &toolSettings{shared: shared, tools: tools, flags: flags}
+	return nil
+}
+
+// validateEnvPatterns checks the allowed and denied variable patterns
+func validateEnvPatterns(layer *config.ToolConfig) error {
+	if err := delegators.ValidatePatterns(layer.EnvAllow); err != nil {
+		return err
+	}
+	return delegators.ValidatePatterns(layer.EnvDeny)
+}
+
+// configure sets a tool's environment and extra arguments, and its executable
+// when a test replaced it
+func (s *toolSettings) configure(delegator delegators.Delegator) {
+	env := &delegators.Environment{Set: make(map[string]string)}
+	var args []string
+	for _, layer := range []*config.ToolConfig{s.shared, s.tools[delegator.GetToolType()], s.flags} {
+		if layer == nil {
+			continue
+		}
+		for key, value := range layer.Env {
+			env.Set[key] = value
+		}
+		env.Allow = append(env.Allow, layer.EnvAllow...)
+		env.Deny = append(env.Deny, layer.EnvDeny...)
+		args = append(args, layer.Args...)
+	}
+	if command := toolCommands[delegator.GetToolType()]; command != "" {
+		delegator.SetCommand(command)
+	}
+
+	if len(env.Set) > 0 || len(env.Allow) > 0 || len(env.Deny) > 0 {
+		delegator.SetEnvironment(env)
+	}
+	delegator.SetExtraArgs(args)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+)
+
+// streamEvents receives the events of the running tools with --json-stream
+// (nil otherwise)
+var streamEvents chan<- *delegators.Event
+
+// streamDone is the last line of --json-stream output
+type streamDone struct {
+	Type   string `json:"type"` // Always "done"
+	Result any    `json:"result"`
+}
+
+// eventStream writes the events of a run as JSON lines, for --json-stream
+type eventStream struct {
+	events  chan *delegators.Event
+	done    chan struct{}
+	encoder *json.Encoder
+}
+
+// startEventStream starts writing the events sent to the stream to w
+func startEventStream(w io.Writer) *eventStream {
+	s := &eventStream{
+		events:  make(chan *delegators.Event, 64),
+		done:    make(chan struct{}),
+		encoder: json.NewEncoder(w),
+	}
+	go func() {
+		defer close(s.done)
+		for event := range s.events {
+			// Keep receiving when the reader is gone, so the tools don't block
+			_ = s.encoder.Encode(event)
+		}
+	}()
+	return s
+}
+
+// Close writes the events sent so far, then the final result. No events
+// may be sent after it is called.
+func (s *eventStream) Close(result any) error {
+	close(s.events)
+	<-s.done
+	if err := s.encoder.Encode(streamDone{Type: "done", Result: result}); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -12,22 +13,66 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/artifacts"
+	"github.com/crlian/ai-dispatcher/pkg/calibration"
+	"github.com/crlian/ai-dispatcher/pkg/changes"
+	"github.com/crlian/ai-dispatcher/pkg/config"
 	"github.com/crlian/ai-dispatcher/pkg/delegators"
+	"github.com/crlian/ai-dispatcher/pkg/history"
+	"github.com/crlian/ai-dispatcher/pkg/progress"
 	"github.com/crlian/ai-dispatcher/pkg/router"
+	"github.com/crlian/ai-dispatcher/pkg/tokenizer"
 	"github.com/crlian/ai-dispatcher/pkg/trackers"
 )
 
 var (
-	execForce   string
-	execVerbose bool
-	execDryRun  bool
-	execJSON    bool
-	execTimeout time.Duration
+	execForce    string
+	execVerbose  bool
+	execDryRun   bool
+	execJSON     bool
+	execTimeout  time.Duration
+	execWait     bool
+	execWaitFor  string
+	execWaitMax  time.Duration
+	execStrategy string
+	execMode     string
+	execFile     string
+	execAttach   []string
+
+	execOutputFile string
+	execJSONStream bool
+	execRecord     string
+
+	execIsolate       bool
+	execIsolateAction string
+
+	execApprove string
+	execTestCmd string
+
+	execContinue bool
+	execResume   string
+
+	execRace     []string
+	execRacePick string
+
+	execVerify        []string
+	execNoVerify      bool
+	execVerifyRetries int
+	execRetryTool     string
+
+	execPermissions   string
+	execAllowCommands []string
+	execDenyCommands  []string
+
+	execCwd      string
+	execEnv      []string
+	execEnvAllow []string
+	execEnvDeny  []string
 )
 
 // execCmd represents the exec command
 var execCmd = &cobra.Command{
-	Use:   "exec [task]",
+	Use:   "exec [task] [-- tool args...]",
 	Short: "Execute a coding task with intelligent routing",
 	Long: `Execute a coding task using the best available AI tool.
 
@@ -42,9 +87,30 @@ Examples:
   ai-dispatcher exec "fix bug in auth.go"
   ai-dispatcher exec "refactor user service" --verbose
   ai-dispatcher exec "add comments" --force opencode
-  ai-dispatcher exec "implement feature" --dry-run`,
-	Args: cobra.ExactArgs(1),
-	Run:  runExec,
+  ai-dispatcher exec "implement feature" --dry-run
+  ai-dispatcher exec "refactor auth" --wait-for claude-code --wait-max 2h
+  ai-dispatcher exec "add tests" --wait
+  ai-dispatcher exec -f task.md
+  git diff | ai-dispatcher exec "review this"
+  ai-dispatcher exec "implement this spec" --attach spec.md --attach mockup.png
+  ai-dispatcher exec "migrate to the new API" --isolate
+  ai-dispatcher exec "rename the config loader" --approve
+  ai-dispatcher exec "fix the flaky test" --approve=auto-if-tests-pass --test-cmd "make test"
+  ai-dispatcher exec "add pagination" --verify "go build ./..." --verify "go test ./..." --verify-retries 2
+  ai-dispatcher exec "now add tests for that" --continue
+  ai-dispatcher exec "speed up the parser" --race claude-code,codex --verify "go test ./..."
+  ai-dispatcher exec "audit the auth flow" --permissions read-only
+  ai-dispatcher exec "fix the build" --allow-command "go build" --deny-command "git push"
+  ai-dispatcher exec "update the changelog" --cwd ../docs --env-deny 'AWS_*'
+  ai-dispatcher exec "port the parser" --force claude-code -- --model opus
+  ai-dispatcher exec "write the migration guide" --output-file guide.md
+  ai-dispatcher exec "fix the flaky test" --record session.jsonl
+  ai-dispatcher exec "fix the lint errors" --json-stream | jq -c 'select(.type == "file_edit")'`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		task, _ := splitPassthrough(cmd, args)
+		return cobra.MaximumNArgs(1)(cmd, task)
+	},
+	Run: runExec,
 }
 
 func init() {
@@ -53,49 +119,165 @@ func init() {
 	execCmd.Flags().BoolVar(&execDryRun, "dry-run", false, "Show routing decision without executing")
 	execCmd.Flags().BoolVar(&execJSON, "json", false, "Output result in JSON format")
 	execCmd.Flags().DurationVar(&execTimeout, "timeout", 5*time.Minute, "Execution timeout")
+	execCmd.Flags().StringVar(&execStrategy, "strategy", "", strategyFlagUsage)
+	execCmd.Flags().StringVar(&execMode, "mode", "auto", "Execution mode (auto, execute, query); auto answers questions read-only")
+	execCmd.Flags().StringVarP(&execFile, "file", "f", "", "Read the task from a file")
+	execCmd.Flags().StringArrayVar(&execAttach, "attach", nil, "Attach a file to the prompt (repeatable; text or image)")
+	execCmd.Flags().StringVarP(&execOutputFile, "output-file", "o", "", "Write the tool's full output to a file")
+	execCmd.Flags().BoolVar(&execJSONStream, "json-stream", false, "Stream the tool's activity as JSON lines, ending with the result")
+	execCmd.MarkFlagsMutuallyExclusive("json", "json-stream")
+	execCmd.Flags().StringVar(&execRecord, "record", "", "Record the tool's raw stdout and stderr with their timing to a file, for ai-dispatcher replay")
+	execCmd.Flags().BoolVar(&execIsolate, "isolate", false, "Run the tool in a temporary git worktree and review its changes afterwards")
+	execCmd.Flags().StringVar(&execIsolateAction, "isolate-action", IsolateAsk, "What to do with isolated changes (ask, apply, keep, discard); ask keeps them when there is no terminal")
+	execCmd.Flags().StringVar(&execApprove, "approve", "", "Review file changes before keeping them (ask, auto-if-tests-pass); --approve alone asks")
+	execCmd.Flags().Lookup("approve").NoOptDefVal = ApproveAsk
+	execCmd.Flags().StringVar(&execTestCmd, "test-cmd", "", "Test command for --approve=auto-if-tests-pass (default: test_command from the config, or detected)")
+	execCmd.Flags().BoolVar(&execContinue, "continue", false, "Continue the tool session of the last run (routes to that run's tool)")
+	execCmd.Flags().StringVar(&execResume, "resume", "", "Resume the tool session of the given run ID (routes to that run's tool)")
+	execCmd.Flags().StringSliceVar(&execRace, "race", nil, "Run the task on several tools at once, each in its own worktree, and pick a result (e.g. claude-code,codex)")
+	execCmd.Flags().StringVar(&execRacePick, "race-pick", RacePickAsk, "How the race winner is picked (ask, first, keep); ask keeps every result when there is no terminal")
+	execCmd.Flags().StringArrayVar(&execVerify, "verify", nil, "Command that must pass after the task (repeatable; default: verify from the config)")
+	execCmd.Flags().BoolVar(&execNoVerify, "no-verify", false, "Skip the verification commands from the config")
+	execCmd.Flags().IntVar(&execVerifyRetries, "verify-retries", 0, "Re-send the task with the failure output up to this many times when verification fails")
+	execCmd.Flags().StringVar(&execRetryTool, "retry-tool", RetrySame, "Tool for retries (same, alternative, or a tool name)")
+	execCmd.Flags().StringVar(&execPermissions, "permissions", "", "What the tool may do: read-only, workspace-write or full-access (default: permissions from the config); tools that can't enforce it aren't routed to")
+	execCmd.Flags().StringArrayVar(&execAllowCommands, "allow-command", nil, "Command the tool may run without asking, matched by prefix (repeatable)")
+	execCmd.Flags().StringArrayVar(&execDenyCommands, "deny-command", nil, "Command the tool must never run, matched by prefix (repeatable)")
+	execCmd.Flags().StringVar(&execCwd, "cwd", "", "Run in this directory, as if started there")
+	execCmd.Flags().StringArrayVar(&execEnv, "env", nil, "Set an environment variable for the tool, KEY=VALUE (repeatable)")
+	execCmd.Flags().StringArrayVar(&execEnvAllow, "env-allow", nil, "Only pass on environment variables matching this pattern, like 'GO*' (repeatable)")
+	execCmd.Flags().StringArrayVar(&execEnvDeny, "env-deny", nil, "Never pass on environment variables matching this pattern, like 'AWS_*' (repeatable)")
+	execCmd.Flags().BoolVar(&execWait, "wait", false, "Wait for the earliest window reset when no tool is available")
+	execCmd.Flags().StringVar(&execWaitFor, "wait-for", "", "Wait until a specific tool has capacity, then use it (claude-code, codex, opencode)")
+	execCmd.Flags().DurationVar(&execWaitMax, "wait-max", 0, "Maximum time to wait for capacity (default: until the window resets)")
 }
 
 func runExec(cmd *cobra.Command, args []string) {
-	task := args[0]
+	args, passthrough := splitPassthrough(cmd, args)
+	input, err := readTaskInput(args, execFile, execAttach, os.Stdin)
+	if err != nil {
+		exitWithError(err)
+	}
+	if err := changeDir(execCwd); err != nil {
+		exitWithError(err)
+	}
+	if err := resolveToolSettings(passthrough); err != nil {
+		exitWithError(err)
+	}
+	if err := validateIsolateAction(execIsolateAction); err != nil {
+		exitWithError(err)
+	}
+	if err := validateApproveMode(execApprove); err != nil {
+		exitWithError(err)
+	}
+	if err := resolveVerifySettings(cmd); err != nil {
+		exitWithError(err)
+	}
+	if err := resolvePermissions(cmd); err != nil {
+		exitWithError(err)
+	}
+	if err := validateSessionFlags(); err != nil {
+		exitWithError(err)
+	}
+	if err := validateRaceFlags(); err != nil {
+		exitWithError(err)
+	}
+	if err := startRecording(); err != nil {
+		exitWithError(err)
+	}
+
+	// The first Ctrl-C stops the tool and reports what it did until then
+	ctx, stop := interruptContext()
+	defer stop()
 
-	// Validate task
-	if strings.TrimSpace(task) == "" {
-		exitWithError(fmt.Errorf("task cannot be empty"))
+	var stream *eventStream
+	if execJSONStream {
+		stream = startEventStream(os.Stdout)
+		streamEvents = stream.events
+	}
+
+	if len(execRace) > 0 {
+		race := executeRace(ctx, input)
+		switch {
+		case stream != nil:
+			if err := stream.Close(race); err != nil {
+				exitWithError(err)
+			}
+		case execJSON:
+			outputExecJSON(race)
+		default:
+			outputRaceText(race)
+		}
+		if race.Cancelled {
+			os.Exit(exitInterrupted)
+		}
+		if race.Error != "" || (!race.DryRun && !raceSucceeded(race)) {
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Execute the pipeline
-	result := executePipeline(task)
+	result := executePipeline(ctx, input)
+	saveErr := saveRunOutput(result)
 
 	// Output based on format
-	if execJSON {
+	switch {
+	case stream != nil:
+		if err := stream.Close(result); err != nil {
+			exitWithError(err)
+		}
+	case execJSON:
 		outputExecJSON(result)
-	} else {
+	default:
 		outputExecText(result)
 	}
+	if saveErr != nil {
+		exitWithError(saveErr)
+	}
 
-	// Exit with error if execution failed
+	// Exit with error if execution or verification failed
+	if interrupted(ctx) {
+		os.Exit(exitInterrupted)
+	}
 	if result.ExecutionResult != nil && !result.ExecutionResult.Success {
 		os.Exit(1)
 	}
+	if result.Verification != nil && !result.Verification.Passed {
+		os.Exit(1)
+	}
 }
 
 // PipelineResult contains the complete result of the execution pipeline
 type PipelineResult struct {
+	RunID           string                        `json:"run_id,omitempty"`
+	ResumedRun      string                        `json:"resumed_run,omitempty"` // Run whose tool session was continued
 	Task            string                        `json:"task"`
+	Attachments     []*delegators.Attachment      `json:"attachments,omitempty"`
+	Artifacts       string                        `json:"artifacts,omitempty"` // Directory with the run's full output
 	Complexity      *analyzers.ComplexityAnalysis `json:"complexity"`
 	Decision        *router.RoutingDecision       `json:"decision"`
 	ExecutionResult *delegators.DelegationResult  `json:"execution_result,omitempty"`
+	Isolation       *IsolationResult              `json:"isolation,omitempty"`
+	Verification    *VerificationResult           `json:"verification,omitempty"`
+	Approval        *ApprovalResult               `json:"approval,omitempty"`
 	DryRun          bool                          `json:"dry_run"`
+	Waited          time.Duration                 `json:"waited,omitempty"`
 	Error           string                        `json:"error,omitempty"`
 	TotalDuration   time.Duration                 `json:"total_duration"`
+
+	workDir string // Where the tool ran, when not the working directory
 }
 
-// executePipeline runs the complete routing and execution pipeline
-func executePipeline(task string) *PipelineResult {
+// executePipeline runs the complete routing and execution pipeline. When ctx
+// is cancelled the running tool is stopped and its partial result returned.
+func executePipeline(ctx context.Context, input *TaskInput) *PipelineResult {
+	task := input.Task
 	start := time.Now()
 	result := &PipelineResult{
-		Task:   task,
-		DryRun: execDryRun,
+		Task:        task,
+		Attachments: input.Attachments,
+		DryRun:      execDryRun,
 	}
 
 	// Step 1: Analyze complexity
@@ -104,12 +286,10 @@ func executePipeline(task string) *PipelineResult {
 		fmt.Println("🔍 Step 1/5: Analyzing task complexity...")
 	}
 
-	allTrackers := trackers.GetAllTrackers()
-	analyzer := analyzers.NewComplexityAnalyzer(allTrackers)
-
-	complexity, err := analyzer.AnalyzeComplexity(task)
+	allTrackers := loadTrackers()
+	complexity, model, err := analyzeTask(ctx, input)
 	if err != nil {
-		result.Error = fmt.Sprintf("complexity analysis failed: %v", err)
+		result.Error = err.Error()
 		result.TotalDuration = time.Since(start)
 		return result
 	}
@@ -117,9 +297,29 @@ func executePipeline(task string) *PipelineResult {
 
 	if execVerbose {
 		fmt.Printf("   Level: %s\n", complexity.Level)
-		fmt.Printf("   Tokens: ~%d\n", complexity.Tokens)
+		if complexity.CalibrationFactor > 0 {
+			fmt.Printf("   Tokens: ~%d (calibrated x%.2f from ~%d)\n", complexity.Tokens, complexity.CalibrationFactor, complexity.RawTokens)
+		} else {
+			fmt.Printf("   Tokens: ~%d\n", complexity.Tokens)
+		}
 		fmt.Printf("   Method: %s (confidence: %.0f%%)\n", complexity.Method, complexity.Confidence*100)
+		fmt.Printf("   Language: %s\n", complexity.Language)
+		fmt.Printf("   Category: %s (confidence: %.0f%%)\n", complexity.Category, complexity.CategoryConfidence*100)
 		fmt.Printf("   Reasoning: %s\n", complexity.Reasoning)
+		for _, vote := range complexity.Votes {
+			if vote.Counted() {
+				fmt.Printf("   Vote: %-9s %s, ~%d tokens (confidence: %.0f%%, weight: %.1f, %s)\n",
+					vote.Analyzer, vote.Level, vote.Tokens, vote.Confidence*100, vote.Weight, vote.Duration.Round(time.Millisecond))
+			} else {
+				fmt.Printf("   Vote: %-9s none (%s)\n", vote.Analyzer, vote.Error)
+			}
+		}
+		if complexity.Signals != nil {
+			fmt.Printf("   Repo context: ~%d tokens across %d files\n", complexity.Signals.ContextTokens, complexity.Signals.FilesTouched)
+		}
+		for _, a := range input.Attachments {
+			fmt.Printf("   Attachment: %s (%d bytes)\n", a.Name, a.Size)
+		}
 	}
 
 	// Step 2: Create decision engine
@@ -128,7 +328,19 @@ func executePipeline(task string) *PipelineResult {
 		fmt.Println("⚙️  Step 2/5: Initializing decision engine...")
 	}
 
-	engine := router.NewDecisionEngine(allTrackers)
+	strategy, err := loadStrategy(execStrategy)
+	if err != nil {
+		result.Error = fmt.Sprintf("invalid strategy: %v", err)
+		result.TotalDuration = time.Since(start)
+		return result
+	}
+	engine := router.NewDecisionEngine(allTrackers, strategy)
+	engine.SetCalibration(model)
+	engine.SetPermissions(execProfile)
+
+	if execVerbose {
+		fmt.Printf("   Strategy: %s\n", strategy.Name())
+	}
 
 	// Step 3: Check availability
 	if execVerbose {
@@ -154,7 +366,53 @@ func executePipeline(task string) *PipelineResult {
 		fmt.Println("🎯 Step 4/5: Making routing decision...")
 	}
 
-	decision, err := engine.MakeDecision(complexity, execForce)
+	forceTool := execForce
+	resumed, err := resumedRun()
+	if err != nil {
+		result.Error = fmt.Sprintf("can't resume session: %v", err)
+		result.TotalDuration = time.Since(start)
+		return result
+	}
+	if resumed != nil {
+		forceTool = resumed.Tool
+		result.ResumedRun = resumed.RunID
+		if execVerbose {
+			fmt.Printf("   Continuing the %s session of run %s\n", resumed.Tool, resumed.RunID)
+		}
+	}
+	if execWaitFor != "" {
+		waitTool, err := trackers.ValidateToolType(execWaitFor)
+		if err != nil {
+			result.Error = fmt.Sprintf("invalid --wait-for tool: %v", err)
+			result.TotalDuration = time.Since(start)
+			return result
+		}
+		if forceTool != "" {
+			if forced, err := trackers.ValidateToolType(forceTool); err == nil && forced != waitTool {
+				result.Error = fmt.Sprintf("--force %s conflicts with --wait-for %s", forced, waitTool)
+				result.TotalDuration = time.Since(start)
+				return result
+			}
+		}
+
+		if !execDryRun {
+			if err := waitForCapacity(ctx, engine, waitTool, result); err != nil {
+				result.Error = fmt.Sprintf("waiting for %s failed: %v", waitTool, err)
+				result.TotalDuration = time.Since(start)
+				return result
+			}
+		}
+		forceTool = string(waitTool)
+	}
+
+	decision, err := engine.MakeDecision(complexity, forceTool)
+	if errors.Is(err, router.ErrNoToolsAvailable) && execWait && !execDryRun {
+		if waitErr := waitForCapacity(ctx, engine, "", result); waitErr != nil {
+			err = fmt.Errorf("%w (waiting for capacity failed: %v)", err, waitErr)
+		} else {
+			decision, err = engine.MakeDecision(complexity, forceTool)
+		}
+	}
 	if err != nil {
 		result.Error = fmt.Sprintf("routing decision failed: %v", err)
 		result.TotalDuration = time.Since(start)
@@ -162,6 +420,33 @@ func executePipeline(task string) *PipelineResult {
 	}
 	result.Decision = decision
 
+	switch execMode {
+	case "", "auto":
+		// A follow-up builds on the session's work, so it always executes
+		if resumed != nil {
+			decision.Mode = router.ModeExecute
+		}
+	case string(router.ModeExecute), string(router.ModeQuery):
+		decision.Mode = router.ExecutionMode(execMode)
+	default:
+		result.Error = fmt.Sprintf("invalid --mode %q: must be one of [auto, execute, query]", execMode)
+		result.TotalDuration = time.Since(start)
+		return result
+	}
+
+	if resumed != nil {
+		decision.Reason = fmt.Sprintf("Continuing the %s session of run %s", decision.SelectedName, resumed.RunID)
+	}
+
+	if execWaitFor != "" {
+		if result.Waited > 0 {
+			decision.Reason = fmt.Sprintf("Using %s after waiting %s for capacity (--wait-for)",
+				decision.SelectedName, delegators.FormatDuration(result.Waited))
+		} else {
+			decision.Reason = fmt.Sprintf("Using %s (requested with --wait-for)", decision.SelectedName)
+		}
+	}
+
 	if execVerbose || execDryRun {
 		fmt.Println()
 		printDecision(decision)
@@ -181,24 +466,300 @@ func executePipeline(task string) *PipelineResult {
 			return result
 		}
 
+		// Advance stateful strategies (e.g. round-robin) now that the decision is used
+		if err := engine.CommitDecision(decision); err != nil && execVerbose {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+
 		// Set timeout
 		delegator.SetTimeout(execTimeout)
+		delegator.SetAttachments(input.Attachments)
+		delegator.SetPermissions(execProfile)
+		execTools.configure(delegator)
+		if resumed != nil {
+			delegator.SetSession(resumed.SessionID)
+		}
+
+		result.RunID = history.NewRunID()
+		result.Artifacts = createArtifacts(result.RunID)
+		delegator.SetLogDir(result.Artifacts)
+		delegator.SetRecording(execRecord)
+		delegator.SetProgress(progressMode)
+		delegator.SetEvents(streamEvents)
+
+		// Run in a throwaway worktree so the checkout is only changed on request
+		workDir := "."
+		if execIsolate {
+			wt, stop, err := startIsolation(result.RunID)
+			if err != nil {
+				result.Error = fmt.Sprintf("isolation failed: %v", err)
+				result.TotalDuration = time.Since(start)
+				return result
+			}
+			delegator.SetWorkDir(wt.Path)
+			workDir = wt.Path
+			result.workDir = wt.Path
+			defer func() {
+				// Nobody is waiting for an answer after an interrupt, so keep the changes
+				action := execIsolateAction
+				if interrupted(ctx) {
+					action = IsolateKeep
+				}
+				result.Isolation = finishIsolation(wt, task, action)
+				stop()
+			}()
+		}
+
+		// Snapshot the tree so the changes can be reviewed and reverted
+		var before *changes.Snapshot
+		if execApprove != "" && decision.Mode != router.ModeQuery {
+			before, err = changes.Take(workDir)
+			if err != nil {
+				result.Error = fmt.Sprintf("approval failed: %v", err)
+				result.TotalDuration = time.Since(start)
+				return result
+			}
+		}
 
-		// Execute task
-		ctx := context.Background()
-		execResult, err := delegator.Execute(ctx, task)
+		// Execute task, or only ask for an answer on the read-only path
+		var execResult *delegators.DelegationResult
+		if decision.Mode == router.ModeQuery {
+			execResult, err = queryTask(ctx, delegator, decision.SelectedTool, task)
+		} else {
+			execResult, err = delegator.Execute(ctx, task)
+		}
+		if err == nil && !execResult.Cancelled && decision.Mode != router.ModeQuery && len(execVerify) > 0 {
+			execResult, result.Verification = verifyAndRetry(ctx, delegator, decision, input, workDir, result.Artifacts, execResult)
+		}
+		// A failed run can still have changed files, so review them either way
+		if before != nil {
+			result.Approval = reviewChanges(ctx, before, execApprove)
+		}
 		if err != nil {
 			result.Error = fmt.Sprintf("execution failed: %v", err)
 			result.TotalDuration = time.Since(start)
 			return result
 		}
 		result.ExecutionResult = execResult
+
+		recordHistory(result)
 	}
 
 	result.TotalDuration = time.Since(start)
 	return result
 }
 
+// createArtifacts creates the directory the run's full output is kept in.
+// The run goes ahead without it if it can't be created.
+func createArtifacts(runID string) string {
+	dir, err := artifacts.Create(config.StateDir(), runID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return ""
+	}
+	return dir
+}
+
+// saveRunOutput writes the final result to the run's artifacts, and the
+// tool's output to --output-file
+func saveRunOutput(result *PipelineResult) error {
+	if result.Artifacts != "" {
+		if err := artifacts.WriteResult(result.Artifacts, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if execOutputFile == "" || result.ExecutionResult == nil {
+		return nil
+	}
+	if err := os.WriteFile(execOutputFile, []byte(result.ExecutionResult.Output), 0o644); err != nil {
+		return fmt.Errorf("failed to write --output-file: %w", err)
+	}
+	return nil
+}
+
+// analyzeTask estimates the task's complexity, calibrated with what past runs
+// actually used. The calibration model is returned for routing.
+func analyzeTask(ctx context.Context, input *TaskInput) (*analyzers.ComplexityAnalysis, *calibration.Model, error) {
+	analyzer, err := newComplexityAnalyzer()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	model, err := calibration.Open(config.StateDir()).LoadModel()
+	if err != nil && execVerbose {
+		fmt.Fprintf(os.Stderr, "Warning: calibration disabled: %v\n", err)
+	}
+	analyzer.SetCalibration(model)
+
+	complexity, err := analyzer.AnalyzeComplexityContext(ctx, input.Task)
+	if err != nil {
+		return nil, nil, fmt.Errorf("complexity analysis failed: %w", err)
+	}
+	addAttachmentTokens(complexity, input.Attachments)
+	return complexity, model, nil
+}
+
+// addAttachmentTokens adds the attachments to the prompt the cost calculator
+// tokenizes per tool, and images (which aren't text) to the estimate
+func addAttachmentTokens(analysis *analyzers.ComplexityAnalysis, attachments []*delegators.Attachment) {
+	for _, a := range attachments {
+		if a.Image {
+			analysis.Tokens += delegators.ImageTokenEstimate
+			if analysis.RawTokens > 0 {
+				analysis.RawTokens += delegators.ImageTokenEstimate
+			}
+			continue
+		}
+		analysis.Prompt += "\n\n" + a.Inline()
+	}
+}
+
+// queryTask answers a question-only task through the delegator's read-only Query path
+func queryTask(ctx context.Context, delegator delegators.Delegator, tool trackers.ToolType, task string) (*delegators.DelegationResult, error) {
+	queryStart := time.Now()
+	answer, err := delegator.Query(ctx, task)
+	if err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+
+	result := &delegators.DelegationResult{
+		Success:    true,
+		Output:     answer,
+		TokensUsed: tokenizer.ForTool(tool).Count(answer),
+		Duration:   time.Since(queryStart),
+		ToolName:   delegator.GetToolName(),
+	}
+	if ctx.Err() != nil {
+		result.Success = false
+		result.Cancelled = true
+		result.CancelReason = context.Cause(ctx).Error()
+		result.Error = result.CancelReason
+	}
+	return result, nil
+}
+
+// recordHistory appends the run to the history and its token usage to the
+// calibration samples. A failure to record is reported but doesn't fail the run
+func recordHistory(result *PipelineResult) {
+	record := &history.Record{
+		RunID:           result.RunID,
+		Time:            time.Now(),
+		Task:            result.Task,
+		Attachments:     attachmentNames(result.Attachments),
+		Tool:            string(result.Decision.SelectedTool),
+		Level:           string(result.Complexity.Level),
+		Category:        string(result.Complexity.Category),
+		Language:        result.Complexity.Language,
+		Mode:            string(result.Decision.Mode),
+		EstimatedTokens: result.Complexity.Tokens,
+		Dir:             result.workDir,
+	}
+	if record.Dir == "" {
+		record.Dir, _ = os.Getwd()
+	}
+	if result.Decision.SelectedCost != nil {
+		record.EstimatedTokens = result.Decision.SelectedCost.EstimatedTokens
+	}
+	if exec := result.ExecutionResult; exec != nil {
+		record.ActualTokens = exec.TokensUsed
+		record.Success = exec.Success
+		record.Cancelled = exec.Cancelled
+		record.Duration = exec.Duration
+		record.SessionID = exec.SessionID
+		if exec.Usage != nil {
+			record.UsageTokens = exec.Usage.Total()
+		}
+	}
+	if v := result.Verification; v != nil {
+		record.Verified = &v.Passed
+		record.Retries = v.Retries()
+		record.ActualTokens = v.TokensUsed()
+	}
+
+	if err := history.Open(config.StateDir()).Append(record); err != nil && execVerbose {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	// Only successful first attempts say something about how many tokens a
+	// task needs, and only the tool's reported usage counts what it read as
+	// well as what it wrote, like the estimate
+	if !record.Success || record.Retries > 0 || record.UsageTokens <= 0 {
+		return
+	}
+	sample := &calibration.Sample{
+		Time:      record.Time,
+		Tool:      record.Tool,
+		Level:     record.Level,
+		Category:  record.Category,
+		Estimated: result.Complexity.UncalibratedTokens(),
+		Actual:    record.UsageTokens,
+	}
+	if err := calibration.Open(config.StateDir()).Append(sample); err != nil && execVerbose {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// waitForCapacity blocks until the tool (or any tool when empty) has capacity,
+// showing a countdown on stderr, and records the time spent in the result
+func waitForCapacity(ctx context.Context, engine *router.DecisionEngine, tool trackers.ToolType, result *PipelineResult) error {
+	redraw := progress.Resolve(progressMode, os.Stderr) == progress.TTY
+	reporter := newProgress(os.Stderr, 0)
+	reporter.Start("")
+	var lastPrinted time.Time
+
+	waitStart := time.Now()
+	_, err := engine.WaitForCapacity(ctx, router.WaitOptions{
+		Tool:    tool,
+		MaxWait: execWaitMax,
+		OnTick: func(status *router.WaitStatus) {
+			// Without a terminal, print a line every poll instead of redrawing
+			if !redraw && time.Since(lastPrinted) < router.DefaultPollInterval {
+				return
+			}
+			lastPrinted = time.Now()
+			reporter.Update(formatWaitStatus(status, tool == ""))
+		},
+	})
+	result.Waited += time.Since(waitStart)
+	reporter.Stop()
+
+	if err == nil && !lastPrinted.IsZero() {
+		fmt.Fprintf(os.Stderr, "✓ Capacity available after %s\n", delegators.FormatDuration(result.Waited))
+	}
+
+	return err
+}
+
+// formatWaitStatus formats a countdown line for a wait in progress
+func formatWaitStatus(status *router.WaitStatus, anyTool bool) string {
+	target := status.ToolName
+	if anyTool {
+		target = "any tool"
+	}
+
+	if status.ResetIn <= 0 {
+		return fmt.Sprintf("⏳ Waiting for %s (%.1f%% available, checking for reset...)", target, status.Available)
+	}
+
+	remaining := status.ResetIn.Round(time.Second)
+	hours := int(remaining.Hours())
+	minutes := int(remaining.Minutes()) % 60
+	seconds := int(remaining.Seconds()) % 60
+
+	var countdown string
+	if hours > 0 {
+		countdown = fmt.Sprintf("%dh %02dm %02ds", hours, minutes, seconds)
+	} else {
+		countdown = fmt.Sprintf("%dm %02ds", minutes, seconds)
+	}
+
+	if anyTool {
+		return fmt.Sprintf("⏳ Waiting for any tool: %s resets in %s", status.ToolName, countdown)
+	}
+	return fmt.Sprintf("⏳ Waiting for %s: resets in %s (%.1f%% available)", target, countdown, status.Available)
+}
+
 // printDecision prints the routing decision with colors
 func printDecision(decision *router.RoutingDecision) {
 	cyan := color.New(color.FgCyan).SprintFunc()
@@ -218,6 +779,16 @@ func printDecision(decision *router.RoutingDecision) {
 		fmt.Printf("   %s: %.1f%%\n", cyan("Available capacity"), decision.SelectedCost.AvailablePercent)
 	}
 
+	if decision.Mode == router.ModeQuery {
+		fmt.Printf("   %s: %s\n", cyan("Mode"), "query (read-only, no files are changed)")
+	}
+	if decision.Permissions != nil {
+		fmt.Printf("   %s: %s\n", cyan("Permissions"), describePermissions(decision.Permissions))
+		for _, skipped := range decision.Skipped {
+			fmt.Printf("   %s\n", yellow(fmt.Sprintf("Skipped %s: %s", skipped.Tool, skipped.Reason)))
+		}
+	}
+
 	if decision.WasForced {
 		fmt.Printf("   %s\n", yellow("⚠️  Tool selection was forced"))
 	}
@@ -246,6 +817,7 @@ func outputExecText(result *PipelineResult) {
 	green := color.New(color.FgGreen).SprintFunc()
 	red := color.New(color.FgRed).SprintFunc()
 	cyan := color.New(color.FgCyan).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
 
 	fmt.Println()
 
@@ -267,7 +839,21 @@ func outputExecText(result *PipelineResult) {
 
 	exec := result.ExecutionResult
 
-	if exec.Success {
+	if exec.Cancelled {
+		fmt.Println()
+		fmt.Println(strings.Repeat("─", 40))
+		fmt.Println()
+		fmt.Printf("%s Task cancelled: %s\n", yellow("⚠"), exec.CancelReason)
+		fmt.Printf("   Tool: %s\n", exec.ToolName)
+		fmt.Printf("   Ran for: %s\n", delegators.FormatDuration(exec.Duration))
+		if exec.SessionID != "" {
+			fmt.Printf("   Session: %s (pick up where it stopped with --resume %s)\n", exec.SessionID, result.RunID)
+		}
+		printFilesChanged(exec.FilesChanged)
+		printActivity(exec.Activity)
+
+		printOutput("Output before it stopped:", exec.Output, result.RunID)
+	} else if exec.Success {
 		fmt.Println()
 		fmt.Println(strings.Repeat("─", 40))
 		fmt.Println()
@@ -275,16 +861,16 @@ func outputExecText(result *PipelineResult) {
 		fmt.Printf("   Tool: %s\n", exec.ToolName)
 		fmt.Printf("   Duration: %s\n", delegators.FormatDuration(exec.Duration))
 		fmt.Printf("   Tokens used: ~%d\n", exec.TokensUsed)
+		if exec.SessionID != "" {
+			fmt.Printf("   Session: %s (follow up with --continue or --resume %s)\n", exec.SessionID, result.RunID)
+		}
+		printFilesChanged(exec.FilesChanged)
+		printActivity(exec.Activity)
 
-		if execVerbose && exec.Output != "" {
-			fmt.Println()
-			fmt.Println("Output:")
-			// Render markdown if output looks like markdown
-			output := exec.Output
-			if isLikelyMarkdown(output) {
-				output = delegators.RenderMarkdown(output)
-			}
-			fmt.Print(delegators.TruncateOutput(output, 5000))
+		// Answers from the query path are the result itself, so always show them
+		showOutput := execVerbose || (result.Decision != nil && result.Decision.Mode == router.ModeQuery)
+		if showOutput {
+			printOutput("Output:", exec.Output, result.RunID)
 		}
 	} else {
 		fmt.Println()
@@ -294,24 +880,98 @@ func outputExecText(result *PipelineResult) {
 		fmt.Printf("   Tool: %s\n", exec.ToolName)
 		fmt.Printf("   Duration: %s\n", delegators.FormatDuration(exec.Duration))
 		fmt.Printf("   Exit code: %d\n", exec.ExitCode)
+		printFilesChanged(exec.FilesChanged)
+		printActivity(exec.Activity)
 
 		if exec.Error != "" {
 			fmt.Printf("   Error: %s\n", exec.Error)
 		}
 
-		if exec.Output != "" {
-			fmt.Println()
-			fmt.Println("Output:")
-			// Render markdown if output looks like markdown
-			output := exec.Output
-			if isLikelyMarkdown(output) {
-				output = delegators.RenderMarkdown(output)
-			}
-			fmt.Print(delegators.TruncateOutput(output, 5000))
-		}
+		printOutput("Output:", exec.Output, result.RunID)
+	}
+
+	if result.Verification != nil {
+		printVerification(result.Verification)
+	}
+	if result.Approval != nil {
+		printApproval(result.Approval)
+	}
+	if result.Isolation != nil {
+		printIsolation(result.Isolation)
+	}
+
+	fmt.Println()
+}
+
+// maxOutputShown bounds the tool output printed in text output; the rest is
+// in the run's artifacts
+const maxOutputShown = 5000
+
+// printOutput prints a tool's output under a heading, rendering markdown
+// when it looks like markdown
+func printOutput(heading, output, runID string) {
+	if output == "" {
+		return
+	}
+	if isLikelyMarkdown(output) {
+		output = delegators.RenderMarkdown(output)
 	}
 
 	fmt.Println()
+	fmt.Println(heading)
+	fmt.Print(delegators.TruncateOutput(output, maxOutputShown))
+	if len(output) > maxOutputShown && runID != "" {
+		fmt.Printf("\n   Full output: ai-dispatcher show %s\n", runID)
+	}
+}
+
+// maxFilesListed bounds the changed files listed in text output
+const maxFilesListed = 20
+
+// printFilesChanged lists the files a tool created, modified or deleted
+func printFilesChanged(files []*changes.FileChange) {
+	if len(files) == 0 {
+		return
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	fmt.Printf("   Files changed: %d\n", len(files))
+	for i, f := range files {
+		if i >= maxFilesListed {
+			fmt.Printf("      ... and %d more\n", len(files)-maxFilesListed)
+			break
+		}
+		if f.Binary {
+			fmt.Printf("      %-8s %s (binary)\n", f.Status, f.Path)
+			continue
+		}
+		if f.Uncounted {
+			fmt.Printf("      %-8s %s\n", f.Status, f.Path)
+			continue
+		}
+		fmt.Printf("      %-8s %s (%s %s)\n", f.Status, f.Path, green(fmt.Sprintf("+%d", f.Added)), red(fmt.Sprintf("-%d", f.Removed)))
+	}
+}
+
+// printActivity summarizes the commands a tool reported running
+func printActivity(activity *delegators.Activity) {
+	if activity == nil || len(activity.Commands) == 0 {
+		return
+	}
+
+	failed := 0
+	for _, c := range activity.Commands {
+		if c.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("   Commands run: %d (%d failed)\n", len(activity.Commands), failed)
+	} else {
+		fmt.Printf("   Commands run: %d\n", len(activity.Commands))
+	}
 }
 
 // isLikelyMarkdown checks if content contains markdown markers
@@ -328,8 +988,14 @@ func isLikelyMarkdown(content string) bool {
 	return false
 }
 
+// jsonOutput reports whether stdout is for JSON, so nothing else may be
+// printed there and nobody can be asked
+func jsonOutput() bool {
+	return execJSON || execJSONStream
+}
+
 // outputExecJSON outputs execution result in JSON format
-func outputExecJSON(result *PipelineResult) {
+func outputExecJSON(result any) {
 	encoder := json.NewEncoder(os.Stdout)
 	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(result); err != nil {
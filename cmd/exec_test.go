@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/calibration"
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+	"github.com/crlian/ai-dispatcher/pkg/history"
+	"github.com/crlian/ai-dispatcher/pkg/router"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+	"github.com/crlian/ai-dispatcher/test/mocks"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// TestExecGolden runs the exec pipeline against the fake agent, replaying
+// the streams in testdata/exec, and compares the output with the golden files
+func TestExecGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    string
+		tool    string
+		mode    string
+		timeout time.Duration
+		agent   mocks.FakeAgent
+		wantArg string // Passed to the tool
+		want    func(t *testing.T, result *PipelineResult)
+	}{
+		{
+			name:    "claude-success",
+			task:    "fix the token expiry bug in auth.go",
+			tool:    "claude-code",
+			agent:   mocks.FakeAgent{Stream: "claude-success.jsonl", Delay: "5ms"},
+			wantArg: "stream-json",
+			want: func(t *testing.T, result *PipelineResult) {
+				exec := result.ExecutionResult
+				if !exec.Success || exec.SessionID != "5f0c9a7e-success" {
+					t.Errorf("Success = %v, SessionID = %q", exec.Success, exec.SessionID)
+				}
+				if exec.Usage == nil || exec.Usage.OutputTokens != 86 {
+					t.Errorf("Usage = %+v, want 86 output tokens", exec.Usage)
+				}
+			},
+		},
+		{
+			name:    "claude-failure",
+			task:    "run the database migration",
+			tool:    "claude-code",
+			agent:   mocks.FakeAgent{Stream: "claude-failure.jsonl", Stderr: "Error: the API is overloaded, try again later", ExitCode: 1},
+			wantArg: "stream-json",
+			want: func(t *testing.T, result *PipelineResult) {
+				if exec := result.ExecutionResult; exec.Success || exec.ExitCode != 1 {
+					t.Errorf("Success = %v, ExitCode = %d, want a failure with exit code 1", exec.Success, exec.ExitCode)
+				}
+			},
+		},
+		{
+			name:    "claude-timeout",
+			task:    "run the full test suite",
+			tool:    "claude-code",
+			timeout: 500 * time.Millisecond,
+			agent:   mocks.FakeAgent{Stream: "claude-timeout.jsonl", Hang: "30s"},
+			wantArg: "stream-json",
+			want: func(t *testing.T, result *PipelineResult) {
+				if exec := result.ExecutionResult; !exec.Cancelled || exec.Success {
+					t.Errorf("Cancelled = %v, Success = %v, want a cancelled run", exec.Cancelled, exec.Success)
+				}
+				if exec := result.ExecutionResult; exec.Duration > 10*time.Second {
+					t.Errorf("Duration = %s, the fake agent wasn't stopped", exec.Duration)
+				}
+			},
+		},
+		{
+			name:    "claude-malformed",
+			task:    "add comments to the parser",
+			tool:    "claude-code",
+			agent:   mocks.FakeAgent{Stream: "claude-malformed.jsonl"},
+			wantArg: "stream-json",
+			want: func(t *testing.T, result *PipelineResult) {
+				if exec := result.ExecutionResult; !exec.Success || !strings.Contains(exec.Output, "unknown key") {
+					t.Errorf("Success = %v, Output = %q, want the unparsed lines kept as text", exec.Success, exec.Output)
+				}
+			},
+		},
+		{
+			name:    "claude-query",
+			task:    "how does the router pick a tool?",
+			tool:    "claude-code",
+			mode:    "query",
+			agent:   mocks.FakeAgent{Stream: "claude-query.txt"},
+			wantArg: "plan",
+			want: func(t *testing.T, result *PipelineResult) {
+				if exec := result.ExecutionResult; !exec.Success || !strings.Contains(exec.Output, "cheapest") {
+					t.Errorf("Success = %v, Output = %q, want the answer", exec.Success, exec.Output)
+				}
+			},
+		},
+		{
+			name:    "codex-success",
+			task:    "add pagination to the list endpoint",
+			tool:    "codex",
+			agent:   mocks.FakeAgent{Stream: "codex-success.jsonl", Delay: "5ms"},
+			wantArg: "--json",
+			want: func(t *testing.T, result *PipelineResult) {
+				exec := result.ExecutionResult
+				if !exec.Success || exec.SessionID != "0199a0c4-codex" {
+					t.Errorf("Success = %v, SessionID = %q", exec.Success, exec.SessionID)
+				}
+				if exec.Activity == nil || len(exec.Activity.Commands) != 1 {
+					t.Errorf("Activity = %+v, want one command", exec.Activity)
+				}
+			},
+		},
+	}
+
+	agent := mocks.BuildFakeAgent(t)
+	testdata, err := filepath.Abs(filepath.Join("testdata", "exec"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupExec(t, agent)
+			execForce = tt.tool
+			if tt.mode != "" {
+				execMode = tt.mode
+			}
+			if tt.timeout > 0 {
+				execTimeout = tt.timeout
+			}
+			tt.agent.Stream = filepath.Join(testdata, tt.agent.Stream)
+			tt.agent.ArgsFile = filepath.Join(t.TempDir(), "args")
+			tt.agent.Setenv(t)
+
+			var result *PipelineResult
+			stdout, stderr := captureOutput(t, func() {
+				result = executePipeline(context.Background(), &TaskInput{Task: tt.task})
+				outputExecText(result)
+			})
+			if result.Error != "" {
+				t.Fatalf("executePipeline() error = %s", result.Error)
+			}
+			tt.want(t, result)
+
+			args, err := os.ReadFile(tt.agent.ArgsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(args), tt.wantArg+"\n") {
+				t.Errorf("tool arguments = %q, want %q", args, tt.wantArg)
+			}
+
+			got := "$ ai-dispatcher exec " + tt.task + "\n" + stdout + "--- progress ---\n" + stderr
+			checkGolden(t, filepath.Join(testdata, tt.name+".golden"), normalizeOutput(got, result.RunID))
+		})
+	}
+}
+
+// TestRecordHistoryCalibration checks that runs are calibrated against the
+// total tokens the tool reported, not the size of its output
+func TestRecordHistoryCalibration(t *testing.T) {
+	t.Setenv("AI_DISPATCHER_HOME", t.TempDir())
+
+	run := func(usage *delegators.Usage) {
+		recordHistory(&PipelineResult{
+			RunID:      history.NewRunID(),
+			Task:       "fix the login crash",
+			Complexity: &analyzers.ComplexityAnalysis{Level: analyzers.Medium, Tokens: 2000},
+			Decision:   &router.RoutingDecision{SelectedTool: trackers.CodexTool},
+			ExecutionResult: &delegators.DelegationResult{
+				Success:    true,
+				TokensUsed: 50, // Counted from the output
+				Usage:      usage,
+			},
+		})
+	}
+	run(&delegators.Usage{InputTokens: 3000, CachedInputTokens: 2500, OutputTokens: 200})
+	run(nil)
+
+	samples, err := calibration.Open(config.StateDir()).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("recorded %d samples, want only the run with usage", len(samples))
+	}
+	if samples[0].Estimated != 2000 || samples[0].Actual != 3200 {
+		t.Errorf("sample = %d estimated, %d actual; want 2000 and 3200", samples[0].Estimated, samples[0].Actual)
+	}
+}
+
+// setupExec isolates an exec run: its own state directory and working
+// directory, every tool replaced by the fake agent, mock trackers, and the
+// exec flags at their defaults
+func setupExec(t *testing.T, agent string) {
+	t.Helper()
+	t.Setenv("AI_DISPATCHER_HOME", t.TempDir())
+	t.Setenv("NO_COLOR", "1")
+
+	work := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	trackersBefore := loadTrackers
+	loadTrackers = func() []trackers.UsageTracker {
+		return []trackers.UsageTracker{
+			mocks.NewMockTracker("Claude Code", trackers.ClaudeCodeTool),
+			mocks.NewMockTracker("Codex", trackers.CodexTool),
+		}
+	}
+	t.Cleanup(func() { loadTrackers = trackersBefore })
+
+	force, mode, timeout := execForce, execMode, execTimeout
+	execMode, execTimeout = "auto", 5*time.Minute
+	t.Cleanup(func() { execForce, execMode, execTimeout = force, mode, timeout })
+
+	commands := toolCommands
+	toolCommands = map[trackers.ToolType]string{trackers.ClaudeCodeTool: agent, trackers.CodexTool: agent}
+	t.Cleanup(func() { toolCommands = commands })
+
+	tools := execTools
+	if err := resolveToolSettings(nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { execTools = tools })
+}
+
+// captureOutput returns what fn prints to stdout and stderr
+func captureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := make([]*os.File, 2)
+	for i, name := range []string{"stdout", "stderr"} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		files[i] = f
+	}
+
+	realStdout, realStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = files[0], files[1]
+	fn()
+	os.Stdout, os.Stderr = realStdout, realStderr
+
+	var out [2]string
+	for i, f := range files {
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = string(data)
+	}
+	return out[0], out[1]
+}
+
+// timings matches the durations in exec's output, which change every run
+var timings = regexp.MustCompile(`(Duration|Ran for): \S+`)
+
+// normalizeOutput replaces what changes between runs
+func normalizeOutput(output, runID string) string {
+	output = timings.ReplaceAllString(output, "$1: <duration>")
+	if runID != "" {
+		output = strings.ReplaceAll(output, runID, "<run-id>")
+	}
+	return output
+}
+
+// checkGolden compares got with a golden file, or rewrites it with -update
+func checkGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run the tests with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run the tests with -update to accept it):\n--- got ---\n%s\n--- want ---\n%s", filepath.Base(path), got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+)
+
+// TaskInput is the task text and the files attached to it
+type TaskInput struct {
+	Task        string
+	Attachments []*delegators.Attachment
+}
+
+// readTaskInput collects the task from the argument, --file or piped stdin,
+// and the attachments from --attach. Piped stdin is the task when no other
+// source gives one, and an attachment otherwise.
+func readTaskInput(args []string, taskFile string, attachPaths []string, stdin *os.File) (*TaskInput, error) {
+	input := &TaskInput{}
+
+	if len(args) > 0 && taskFile != "" {
+		return nil, fmt.Errorf("give the task as an argument or with --file, not both")
+	}
+	if len(args) > 0 {
+		input.Task = args[0]
+	}
+	if taskFile != "" {
+		data, err := os.ReadFile(taskFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read task file: %w", err)
+		}
+		if len(data) > delegators.MaxAttachmentBytes {
+			return nil, fmt.Errorf("task file %s is %d bytes, over the %d byte limit", taskFile, len(data), delegators.MaxAttachmentBytes)
+		}
+		input.Task = string(data)
+	}
+
+	piped, err := readPiped(stdin)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(piped) != "" {
+		if strings.TrimSpace(input.Task) == "" {
+			input.Task = piped
+		} else {
+			attachment, err := delegators.NewTextAttachment(delegators.StdinName, piped)
+			if err != nil {
+				return nil, err
+			}
+			input.Attachments = append(input.Attachments, attachment)
+		}
+	}
+
+	for _, path := range attachPaths {
+		attachment, err := delegators.LoadAttachment(path)
+		if err != nil {
+			return nil, err
+		}
+		input.Attachments = append(input.Attachments, attachment)
+	}
+	if err := delegators.CheckAttachments(input.Attachments); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(input.Task) == "" {
+		return nil, fmt.Errorf("task cannot be empty")
+	}
+	input.Task = strings.TrimSpace(input.Task)
+
+	return input, nil
+}
+
+// readPiped reads stdin when it is a pipe or a redirected file. A terminal
+// (or /dev/null) is never read, so exec doesn't block waiting for input.
+func readPiped(stdin *os.File) (string, error) {
+	if stdin == nil {
+		return "", nil
+	}
+	info, err := stdin.Stat()
+	if err != nil {
+		return "", nil
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 && !info.Mode().IsRegular() {
+		return "", nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(stdin, delegators.MaxAttachmentBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %w", err)
+	}
+	if len(data) > delegators.MaxAttachmentBytes {
+		return "", fmt.Errorf("stdin is over the %d byte limit", delegators.MaxAttachmentBytes)
+	}
+	return string(data), nil
+}
+
+// attachmentNames lists the attachments for results and history
+func attachmentNames(attachments []*delegators.Attachment) []string {
+	var names []string
+	for _, a := range attachments {
+		names = append(names, a.Name)
+	}
+	return names
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/workspace"
+)
+
+// What to do with the changes of an isolated run
+const (
+	IsolateAsk     = "ask"
+	IsolateApply   = "apply"
+	IsolateKeep    = "keep"
+	IsolateDiscard = "discard"
+)
+
+// IsolationResult describes the worktree an isolated run executed in and
+// what happened to its changes
+type IsolationResult struct {
+	Branch   string `json:"branch"`
+	Base     string `json:"base"`
+	DiffStat string `json:"diff_stat,omitempty"`
+	Diff     string `json:"diff,omitempty"`
+	Action   string `json:"action"` // apply, keep, discard, or none when nothing changed
+	Error    string `json:"error,omitempty"`
+}
+
+// validateIsolateAction checks the --isolate-action flag
+func validateIsolateAction(action string) error {
+	switch action {
+	case IsolateAsk, IsolateApply, IsolateKeep, IsolateDiscard:
+		return nil
+	default:
+		return fmt.Errorf("invalid --isolate-action %q: must be one of [ask, apply, keep, discard]", action)
+	}
+}
+
+// startIsolation removes worktrees left by crashed runs and creates one for
+// this run. The returned stop function must be called once the run is done;
+// until then a forced exit removes the worktree.
+func startIsolation(runID string) (*workspace.Worktree, func(), error) {
+	cleanupWorktrees()
+
+	wt, err := workspace.Create(".", config.StateDir(), runID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wt, removeOnInterrupt(wt), nil
+}
+
+// cleanupWorktrees removes the worktrees of runs that were interrupted
+func cleanupWorktrees() {
+	if removed, err := workspace.Cleanup(config.StateDir()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if len(removed) > 0 && execVerbose {
+		fmt.Printf("   Removed %d worktree(s) left by interrupted runs\n", len(removed))
+	}
+}
+
+// removeOnInterrupt removes the worktrees if the process is made to exit by
+// a second interrupt, until the returned stop function is called. After the
+// first one the run stops and its worktrees are finished as usual.
+func removeOnInterrupt(worktrees ...*workspace.Worktree) func() {
+	return onForcedExit(func() {
+		for _, wt := range worktrees {
+			wt.Remove()
+		}
+	})
+}
+
+// finishIsolation collects the worktree's diff and applies, keeps or
+// discards it as action says. Afterwards at most the branch of kept changes
+// remains.
+func finishIsolation(wt *workspace.Worktree, task, action string) *IsolationResult {
+	result := &IsolationResult{Branch: wt.Branch, Base: wt.Base}
+
+	diff, err := wt.Diff()
+	if err == nil {
+		result.DiffStat, err = wt.DiffStat()
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Action = IsolateKeep
+		if keepErr := wt.Keep(commitMessage(task)); keepErr != nil {
+			result.Error = fmt.Sprintf("%v; %v", err, keepErr)
+		}
+		return result
+	}
+	result.Diff = diff
+
+	if diff == "" {
+		result.Action = "none"
+		if err := wt.Remove(); err != nil {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	if action == IsolateAsk {
+		action = askIsolateAction(result)
+	}
+	result.Action = action
+
+	switch action {
+	case IsolateApply:
+		if err = wt.Apply(); err != nil {
+			// Don't lose the changes when they don't apply cleanly
+			result.Action = IsolateKeep
+			if keepErr := wt.Keep(commitMessage(task)); keepErr != nil {
+				err = fmt.Errorf("%v; %v", err, keepErr)
+			} else {
+				err = fmt.Errorf("%v; changes kept on branch %s instead", err, wt.Branch)
+			}
+		}
+	case IsolateKeep:
+		err = wt.Keep(commitMessage(task))
+	default:
+		err = wt.Remove()
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// askIsolateAction shows the diff and asks what to do with it. Without a
+// terminal to ask on, the changes are kept on their branch.
+func askIsolateAction(result *IsolationResult) string {
+	if jsonOutput() || !isatty.IsTerminal(os.Stdin.Fd()) || !isatty.IsTerminal(os.Stdout.Fd()) {
+		return IsolateKeep
+	}
+
+	cyan := color.New(color.FgCyan).SprintFunc()
+	fmt.Println()
+	fmt.Printf("%s Changes made in the isolated worktree:\n", cyan("ℹ"))
+	fmt.Println(colorizeDiff(result.Diff))
+	fmt.Println(result.DiffStat)
+	fmt.Println()
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("[a]pply to working tree, [k]eep on branch %s, [d]iscard? ", result.Branch)
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return IsolateKeep
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "a", "apply":
+			return IsolateApply
+		case "k", "keep":
+			return IsolateKeep
+		case "d", "discard":
+			return IsolateDiscard
+		}
+	}
+}
+
+// colorizeDiff colors added and removed lines of a diff
+func colorizeDiff(diff string) string {
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
+
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = cyan(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = green(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = red(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commitMessage is the message of the commit that keeps an isolated run's changes
+func commitMessage(task string) string {
+	summary := strings.TrimSpace(strings.SplitN(task, "\n", 2)[0])
+	if len([]rune(summary)) > 72 {
+		summary = string([]rune(summary)[:69]) + "..."
+	}
+	return fmt.Sprintf("ai-dispatcher: %s\n\n%s", summary, task)
+}
+
+// printIsolation reports what happened to an isolated run's changes
+func printIsolation(isolation *IsolationResult) {
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	switch isolation.Action {
+	case "none":
+		fmt.Println("   Isolated: no files changed")
+	case IsolateApply:
+		fmt.Println("   Isolated: changes applied to the working tree")
+	case IsolateKeep:
+		fmt.Printf("   Isolated: changes kept on branch %s (git merge %s)\n", isolation.Branch, isolation.Branch)
+	case IsolateDiscard:
+		fmt.Println("   Isolated: changes discarded")
+	}
+	if isolation.Error != "" {
+		fmt.Printf("   %s %s\n", yellow("⚠"), isolation.Error)
+	}
+}
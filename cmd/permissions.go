@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
+)
+
+// execProfile is the permission profile requested for the run, from the
+// flags and the config (nil when none was, so each tool runs with its
+// default and any tool can be routed to)
+var execProfile *permissions.Profile
+
+// resolvePermissions builds the run's permission profile from the config
+// and the flags, which override the config field by field
+func resolvePermissions(cmd *cobra.Command) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	var profile *permissions.Profile
+	if cfg.Permissions != nil {
+		copied := *cfg.Permissions
+		profile = &copied
+	}
+
+	flags := cmd.Flags()
+	if profile == nil && (flags.Changed("permissions") || flags.Changed("allow-command") || flags.Changed("deny-command")) {
+		profile = permissions.Default()
+	}
+	if profile == nil {
+		execProfile = nil
+		return nil
+	}
+	if flags.Changed("permissions") {
+		profile.Level = permissions.Level(execPermissions)
+	}
+	if flags.Changed("allow-command") {
+		profile.AllowedCommands = execAllowCommands
+	}
+	if flags.Changed("deny-command") {
+		profile.DeniedCommands = execDenyCommands
+	}
+	if profile.Level == "" {
+		profile.Level = permissions.Default().Level
+	}
+
+	if err := profile.Validate(); err != nil {
+		return fmt.Errorf("invalid permissions: %w", err)
+	}
+	execProfile = profile
+	return nil
+}
+
+// describePermissions formats a profile with its command rules
+func describePermissions(profile *permissions.Profile) string {
+	description := string(profile.Level)
+	if len(profile.AllowedCommands) > 0 {
+		description += "; allowed: " + strings.Join(profile.AllowedCommands, ", ")
+	}
+	if len(profile.DeniedCommands) > 0 {
+		description += "; denied: " + strings.Join(profile.DeniedCommands, ", ")
+	}
+	return description
+}
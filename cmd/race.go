@@ -0,0 +1,630 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/artifacts"
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+	"github.com/crlian/ai-dispatcher/pkg/history"
+	"github.com/crlian/ai-dispatcher/pkg/router"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+	"github.com/crlian/ai-dispatcher/pkg/verify"
+	"github.com/crlian/ai-dispatcher/pkg/workspace"
+)
+
+// How the winner of a race is picked
+const (
+	RacePickAsk   = "ask"
+	RacePickFirst = "first" // The first result that succeeds and passes verification
+	RacePickKeep  = "keep"  // No winner; every result is kept on its branch
+)
+
+// What happened to a racer
+const (
+	RacerRunning   = "running"
+	RacerDone      = "done"
+	RacerFailed    = "failed"
+	RacerCancelled = "cancelled"
+)
+
+// RaceResult is the outcome of running one task on several tools at once
+type RaceResult struct {
+	RunID         string                        `json:"run_id,omitempty"`
+	Task          string                        `json:"task"`
+	Attachments   []*delegators.Attachment      `json:"attachments,omitempty"`
+	Complexity    *analyzers.ComplexityAnalysis `json:"complexity"`
+	Racers        []*Racer                      `json:"racers"`
+	Winner        trackers.ToolType             `json:"winner,omitempty"`
+	DryRun        bool                          `json:"dry_run"`
+	Cancelled     bool                          `json:"cancelled,omitempty"` // Interrupted before a winner was picked
+	CancelReason  string                        `json:"cancel_reason,omitempty"`
+	Error         string                        `json:"error,omitempty"`
+	TotalDuration time.Duration                 `json:"total_duration"`
+}
+
+// Racer is one tool's run in a race, in its own worktree
+type Racer struct {
+	Tool          trackers.ToolType `json:"tool"`
+	Name          string            `json:"name"`
+	Status        string            `json:"status"`
+	Branch        string            `json:"branch,omitempty"`
+	Artifacts     string            `json:"artifacts,omitempty"` // Directory with the racer's full output
+	EstimatedCost float64           `json:"estimated_cost"`
+	Cost          float64           `json:"cost"` // From the tokens actually used
+	TokensUsed    int               `json:"tokens_used"`
+	Duration      time.Duration     `json:"duration"`
+	FilesChanged  int               `json:"files_changed"`
+	Added         int               `json:"added"`
+	Removed       int               `json:"removed"`
+	Checks        []*verify.Result  `json:"checks,omitempty"`
+	Action        string            `json:"action,omitempty"` // apply, keep, discard, or none when nothing changed
+	Error         string            `json:"error,omitempty"`
+
+	index     int
+	decision  *router.RoutingDecision
+	result    *delegators.DelegationResult
+	worktree  *workspace.Worktree
+	delegator delegators.Delegator
+	cancel    context.CancelFunc
+}
+
+// Passed reports whether the racer finished and its verification passed
+func (r *Racer) Passed() bool {
+	return r.Status == RacerDone && verify.Passed(r.Checks)
+}
+
+// raceSucceeded reports whether any racer finished successfully
+func raceSucceeded(result *RaceResult) bool {
+	for _, racer := range result.Racers {
+		if racer.Status == RacerDone {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRaceFlags checks --race and the flags it can't be combined with
+func validateRaceFlags() error {
+	if len(execRace) == 0 {
+		return nil
+	}
+	switch execRacePick {
+	case RacePickAsk, RacePickFirst, RacePickKeep:
+	default:
+		return fmt.Errorf("invalid --race-pick %q: must be one of [ask, first, keep]", execRacePick)
+	}
+
+	conflicts := []struct {
+		flag string
+		set  bool
+	}{
+		{"--force", execForce != ""},
+		{"--wait", execWait},
+		{"--wait-for", execWaitFor != ""},
+		{"--isolate", execIsolate}, // Racers are always isolated
+		{"--approve", execApprove != ""},
+		{"--continue", execContinue},
+		{"--resume", execResume != ""},
+		{"--mode query", execMode == string(router.ModeQuery)},
+		{"--output-file", execOutputFile != ""},
+		{"--record", execRecord != ""},
+	}
+	for _, c := range conflicts {
+		if c.set {
+			return fmt.Errorf("--race can't be combined with %s", c.flag)
+		}
+	}
+
+	_, err := parseRaceTools(execRace)
+	return err
+}
+
+// parseRaceTools validates the tools to race, which must be at least two
+func parseRaceTools(names []string) ([]trackers.ToolType, error) {
+	var tools []trackers.ToolType
+	seen := make(map[trackers.ToolType]bool)
+	for _, name := range names {
+		tool, err := trackers.ValidateToolType(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --race tool: %w", err)
+		}
+		if !seen[tool] {
+			seen[tool] = true
+			tools = append(tools, tool)
+		}
+	}
+	if len(tools) < 2 {
+		return nil, errors.New("--race needs at least two different tools")
+	}
+	return tools, nil
+}
+
+// executeRace runs the task on every --race tool concurrently, each in its
+// own worktree, and applies the result the user picks
+func executeRace(ctx context.Context, input *TaskInput) *RaceResult {
+	start := time.Now()
+	result := &RaceResult{Task: input.Task, Attachments: input.Attachments, DryRun: execDryRun}
+	fail := func(format string, args ...any) *RaceResult {
+		result.Error = fmt.Sprintf(format, args...)
+		result.TotalDuration = time.Since(start)
+		return result
+	}
+
+	tools, err := parseRaceTools(execRace)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	allTrackers := loadTrackers()
+	complexity, model, err := analyzeTask(ctx, input)
+	if err != nil {
+		return fail("%v", err)
+	}
+	result.Complexity = complexity
+
+	strategy, err := loadStrategy(execStrategy)
+	if err != nil {
+		return fail("invalid strategy: %v", err)
+	}
+	engine := router.NewDecisionEngine(allTrackers, strategy)
+	engine.SetCalibration(model)
+	engine.SetPermissions(execProfile)
+
+	for i, tool := range tools {
+		decision, err := engine.MakeDecision(complexity, string(tool))
+		if err != nil {
+			return fail("routing %s failed: %v", tool, err)
+		}
+		racer := &Racer{Tool: tool, Name: decision.SelectedName, Status: RacerRunning, index: i, decision: decision}
+		if decision.SelectedCost != nil {
+			racer.EstimatedCost = decision.SelectedCost.EstimatedCost
+		}
+		result.Racers = append(result.Racers, racer)
+	}
+	if execDryRun {
+		result.TotalDuration = time.Since(start)
+		return result
+	}
+
+	result.RunID = history.NewRunID()
+	if err := runRacers(ctx, result, input); err != nil {
+		return fail("%v", err)
+	}
+
+	result.TotalDuration = time.Since(start)
+	return result
+}
+
+// runRacers creates a worktree per racer, runs them concurrently and applies,
+// keeps or discards their changes. When ctx is cancelled every racer stops
+// and their changes are kept on their branches.
+func runRacers(ctx context.Context, result *RaceResult, input *TaskInput) error {
+	cleanupWorktrees()
+	var worktrees []*workspace.Worktree
+	for _, racer := range result.Racers {
+		wt, err := workspace.Create(".", config.StateDir(), racerRunID(result, racer))
+		if err != nil {
+			for _, created := range worktrees {
+				created.Remove()
+			}
+			return fmt.Errorf("isolation failed: %w", err)
+		}
+		racer.worktree = wt
+		racer.Branch = wt.Branch
+		worktrees = append(worktrees, wt)
+	}
+	stop := removeOnInterrupt(worktrees...)
+	defer stop()
+
+	// Start every racer, streaming its progress under its own label
+	var outputMu sync.Mutex
+	done := make(chan *Racer, len(result.Racers))
+	for _, racer := range result.Racers {
+		delegator, err := delegators.GetDelegator(racer.Tool)
+		if err != nil {
+			racer.Status = RacerFailed
+			racer.Error = fmt.Sprintf("failed to get delegator: %v", err)
+			done <- racer
+			continue
+		}
+		delegator.SetTimeout(execTimeout)
+		delegator.SetAttachments(input.Attachments)
+		delegator.SetPermissions(execProfile)
+		execTools.configure(delegator)
+		delegator.SetWorkDir(racer.worktree.Path)
+		output := newLabelWriter(os.Stderr, &outputMu, racerLabel(racer))
+		delegator.SetOutput(output)
+		racer.Artifacts = createArtifacts(racerRunID(result, racer))
+		delegator.SetLogDir(racer.Artifacts)
+		delegator.SetEvents(streamEvents)
+		racer.delegator = delegator
+
+		racerCtx, cancel := context.WithCancel(ctx)
+		racer.cancel = cancel
+		go func(racer *Racer) {
+			runRacer(racerCtx, racer, input.Task)
+			output.Flush()
+			done <- racer
+		}(racer)
+	}
+
+	winner, rest := pickWinner(ctx, result.Racers, done)
+	if winner == nil && interrupted(ctx) {
+		result.Cancelled = true
+		result.CancelReason = context.Cause(ctx).Error()
+	}
+	if winner != nil {
+		result.Winner = winner.Tool
+	}
+	finishRace(result, winner, rest)
+
+	for _, racer := range result.Racers {
+		// Racers cancelled because another won didn't finish anything worth recording
+		if racer.Artifacts != "" {
+			if err := artifacts.WriteResult(racer.Artifacts, racer); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		if racer.result != nil && (racer.Status != RacerCancelled || result.Cancelled) {
+			recordHistory(&PipelineResult{
+				RunID:           racerRunID(result, racer),
+				Task:            input.Task,
+				Attachments:     input.Attachments,
+				Complexity:      result.Complexity,
+				Decision:        racer.decision,
+				ExecutionResult: racer.result,
+				workDir:         racer.worktree.Path,
+			})
+		}
+	}
+	return nil
+}
+
+// racerRunID is the run ID a racer is recorded under
+func racerRunID(result *RaceResult, racer *Racer) string {
+	return result.RunID + "-" + string(racer.Tool)
+}
+
+// runRacer executes the task and verifies the racer's worktree
+func runRacer(ctx context.Context, racer *Racer, task string) {
+	execResult, err := racer.delegator.Execute(ctx, task)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		racer.Status = RacerCancelled
+		return
+	case err != nil:
+		racer.Status = RacerFailed
+		racer.Error = err.Error()
+		return
+	}
+
+	racer.result = execResult
+	racer.Duration = execResult.Duration
+	racer.TokensUsed = execResult.TokensUsed
+	racer.Cost = float64(execResult.TokensUsed) * router.GetToolPricing()[racer.Tool] / 1000.0
+	racer.FilesChanged = len(execResult.FilesChanged)
+	for _, f := range execResult.FilesChanged {
+		racer.Added += f.Added
+		racer.Removed += f.Removed
+	}
+	switch {
+	case ctx.Err() != nil && !execResult.Success:
+		racer.Status = RacerCancelled
+		return
+	case !execResult.Success:
+		racer.Status = RacerFailed
+		racer.Error = execResult.Error
+		return
+	}
+
+	if len(execVerify) > 0 {
+		verifyCtx, cancel := context.WithTimeout(ctx, execTimeout)
+		racer.Checks = verify.RunAll(verifyCtx, racer.worktree.Path, execVerify)
+		cancel()
+		if ctx.Err() != nil {
+			racer.Status = RacerCancelled
+			return
+		}
+	}
+	racer.Status = RacerDone
+}
+
+// pickWinner waits for the racers and returns the one whose changes are
+// applied, if any, and whether the others are kept or discarded. Once a
+// winner is known the others are cancelled; it returns after every racer
+// has stopped. An interrupted race has no winner and keeps every result.
+func pickWinner(ctx context.Context, racers []*Racer, done <-chan *Racer) (*Racer, string) {
+	mode := execRacePick
+	if mode == RacePickAsk && (jsonOutput() || !isatty.IsTerminal(os.Stdin.Fd()) || !isatty.IsTerminal(os.Stdout.Fd())) {
+		mode = RacePickKeep // Nobody to ask, so keep every result for later
+	}
+
+	var winner *Racer
+	var lines <-chan string
+	if mode == RacePickAsk {
+		lines = readLines(os.Stdin)
+	}
+
+	remaining := len(racers)
+	for remaining > 0 && winner == nil {
+		select {
+		case racer := <-done:
+			remaining--
+			if !jsonOutput() {
+				printRacerFinished(racer)
+			}
+			switch {
+			case mode == RacePickFirst && racer.Passed():
+				winner = racer
+			case mode == RacePickAsk && racer.Status == RacerDone && remaining > 0:
+				fmt.Printf("   Type %d and Enter to apply %s now and cancel the rest\n", racer.index+1, racer.Name)
+			}
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil
+				continue
+			}
+			if racer := racerByNumber(racers, line); racer != nil && racer.Status == RacerDone {
+				winner = racer
+			}
+		}
+	}
+
+	// Cancel the losers and wait for them to stop
+	for _, racer := range racers {
+		if racer != winner && racer.cancel != nil {
+			racer.cancel()
+		}
+	}
+	for ; remaining > 0; remaining-- {
+		<-done
+	}
+
+	switch {
+	case winner != nil:
+		return winner, IsolateDiscard
+	case mode != RacePickAsk, interrupted(ctx):
+		return nil, IsolateKeep // Nothing to apply, so don't lose any result
+	}
+
+	fmt.Println()
+	printRaceComparison(racers)
+	return askWinner(racers, lines)
+}
+
+// askWinner asks which finished racer to apply once all have stopped, and
+// what to do with the rest. Without an answer every result is kept.
+func askWinner(racers []*Racer, lines <-chan string) (*Racer, string) {
+	for {
+		fmt.Print("\nApply which result? [number], [k]eep all on branches, [d]iscard all: ")
+		line, ok := <-lines
+		if !ok {
+			fmt.Println()
+			return nil, IsolateKeep
+		}
+		switch strings.ToLower(line) {
+		case "k", "keep":
+			return nil, IsolateKeep
+		case "d", "discard":
+			return nil, IsolateDiscard
+		}
+		if racer := racerByNumber(racers, line); racer != nil && racer.Status == RacerDone {
+			return racer, IsolateDiscard
+		}
+	}
+}
+
+// finishRace applies the winner and keeps or discards the other worktrees.
+// Racers cancelled because another won are always discarded.
+func finishRace(result *RaceResult, winner *Racer, rest string) {
+	for _, racer := range result.Racers {
+		wt := racer.worktree
+		var err error
+		switch {
+		case racer == winner:
+			racer.Action = IsolateApply
+			if err = wt.Apply(); err != nil {
+				// Don't lose the winning changes when they don't apply cleanly
+				racer.Action = IsolateKeep
+				if keepErr := wt.Keep(commitMessage(result.Task)); keepErr != nil {
+					err = fmt.Errorf("%v; %v", err, keepErr)
+				} else {
+					err = fmt.Errorf("%v; changes kept on branch %s instead", err, wt.Branch)
+				}
+			}
+		case rest == IsolateKeep && (racer.Status != RacerCancelled || result.Cancelled):
+			diff, diffErr := wt.Diff()
+			if diffErr == nil && diff == "" {
+				racer.Action = "none"
+				err = wt.Remove()
+			} else {
+				racer.Action = IsolateKeep
+				err = wt.Keep(commitMessage(result.Task))
+			}
+		default:
+			racer.Action = IsolateDiscard
+			err = wt.Remove()
+		}
+		if err != nil {
+			if racer.Error != "" {
+				racer.Error += "; "
+			}
+			racer.Error += err.Error()
+		}
+	}
+}
+
+// racerByNumber returns the racer with the 1-based number typed by the user
+func racerByNumber(racers []*Racer, line string) *Racer {
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(racers) {
+		return nil
+	}
+	return racers[n-1]
+}
+
+// readLines sends each line read from r on the returned channel, which is
+// closed at the end of the input
+func readLines(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- strings.TrimSpace(line)
+		}
+	}()
+	return lines
+}
+
+// racerColors tell the racers' progress apart
+var racerColors = []color.Attribute{color.FgCyan, color.FgMagenta, color.FgYellow, color.FgBlue, color.FgGreen}
+
+// racerLabel is the prefix of a racer's progress lines
+func racerLabel(racer *Racer) string {
+	label := fmt.Sprintf("[%d %s]", racer.index+1, racer.Tool)
+	return color.New(racerColors[racer.index%len(racerColors)]).Sprint(label)
+}
+
+// labelWriter prefixes each complete line with a label. Writers sharing a
+// mutex never interleave their lines.
+type labelWriter struct {
+	out   io.Writer
+	mu    *sync.Mutex
+	label string
+	buf   []byte
+}
+
+// newLabelWriter creates a writer that prefixes lines written to out
+func newLabelWriter(out io.Writer, mu *sync.Mutex, label string) *labelWriter {
+	return &labelWriter{out: out, mu: mu, label: label}
+}
+
+// Write buffers p and writes out every line it completes
+func (w *labelWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.mu.Lock()
+		fmt.Fprintf(w.out, "%s %s\n", w.label, w.buf[:i])
+		w.mu.Unlock()
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush writes out the last line if it wasn't completed
+func (w *labelWriter) Flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+	w.mu.Lock()
+	fmt.Fprintf(w.out, "%s %s\n", w.label, w.buf)
+	w.mu.Unlock()
+	w.buf = nil
+}
+
+// verifySummary formats a racer's verification outcome
+func verifySummary(racer *Racer) string {
+	switch {
+	case len(racer.Checks) == 0:
+		return "-"
+	case verify.Passed(racer.Checks):
+		return color.New(color.FgGreen).Sprint("PASSED")
+	default:
+		return color.New(color.FgRed).Sprint("FAILED")
+	}
+}
+
+// printRacerFinished reports a racer that stopped
+func printRacerFinished(racer *Racer) {
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	switch racer.Status {
+	case RacerDone:
+		fmt.Printf("%s %s finished in %s: %d file(s), +%d -%d, verify %s\n",
+			green("✓"), racer.Name, delegators.FormatDuration(racer.Duration), racer.FilesChanged, racer.Added, racer.Removed, verifySummary(racer))
+	case RacerFailed:
+		fmt.Printf("%s %s failed: %s\n", red("✗"), racer.Name, racer.Error)
+	case RacerCancelled:
+		fmt.Printf("%s %s cancelled\n", red("✗"), racer.Name)
+	}
+}
+
+// printRaceComparison shows the racers side by side
+func printRaceComparison(racers []*Racer) {
+	bold := color.New(color.Bold).SprintFunc()
+	fmt.Println(bold("🏁 Race results"))
+	fmt.Printf("   %-2s %-12s %-10s %-9s %-8s %-9s %-16s %s\n", "#", "Tool", "Status", "Duration", "Tokens", "Cost", "Changes", "Verify")
+	for _, racer := range racers {
+		changes := fmt.Sprintf("%d files +%d -%d", racer.FilesChanged, racer.Added, racer.Removed)
+		fmt.Printf("   %-2d %-12s %-10s %-9s %-8d %-9s %-16s %s\n",
+			racer.index+1, racer.Name, racer.Status, delegators.FormatDuration(racer.Duration),
+			racer.TokensUsed, router.FormatCost(racer.Cost), changes, verifySummary(racer))
+	}
+}
+
+// outputRaceText outputs a race result in text format
+func outputRaceText(result *RaceResult) {
+	red := color.New(color.FgRed).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	fmt.Println()
+	if result.Error != "" {
+		fmt.Printf("%s %s: %s\n", red("✗"), red("Error"), result.Error)
+		return
+	}
+
+	if result.DryRun {
+		fmt.Printf("%s Dry run - the race would run:\n", cyan("ℹ"))
+		for _, racer := range result.Racers {
+			fmt.Printf("   %d. %s (estimated %s)\n", racer.index+1, racer.Name, router.FormatCost(racer.EstimatedCost))
+		}
+		return
+	}
+
+	if result.Cancelled {
+		fmt.Printf("%s Race cancelled: %s\n", yellow("⚠"), result.CancelReason)
+	}
+	printRaceComparison(result.Racers)
+	fmt.Println()
+	for _, racer := range result.Racers {
+		switch racer.Action {
+		case IsolateApply:
+			fmt.Printf("   %s: changes applied to the working tree\n", racer.Name)
+		case IsolateKeep:
+			fmt.Printf("   %s: changes kept on branch %s\n", racer.Name, racer.Branch)
+		case IsolateDiscard:
+			fmt.Printf("   %s: discarded\n", racer.Name)
+		case "none":
+			fmt.Printf("   %s: no files changed\n", racer.Name)
+		}
+		if racer.Error != "" && racer.Status != RacerFailed {
+			fmt.Printf("   %s %s\n", yellow("⚠"), racer.Error)
+		}
+	}
+	fmt.Printf("   Total time: %s\n", delegators.FormatDuration(result.TotalDuration))
+}
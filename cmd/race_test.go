@@ -0,0 +1,385 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/history"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+	"github.com/crlian/ai-dispatcher/pkg/workspace"
+	"github.com/crlian/ai-dispatcher/test/mocks"
+)
+
+func TestParseRaceTools(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []trackers.ToolType
+		wantErr string
+	}{
+		{name: "two tools", names: []string{"claude-code", "codex"}, want: []trackers.ToolType{trackers.ClaudeCodeTool, trackers.CodexTool}},
+		{name: "spaces and duplicates", names: []string{" codex", "claude-code ", "codex"}, want: []trackers.ToolType{trackers.CodexTool, trackers.ClaudeCodeTool}},
+		{name: "one tool", names: []string{"codex"}, wantErr: "at least two"},
+		{name: "the same tool twice", names: []string{"codex", "codex"}, wantErr: "at least two"},
+		{name: "unknown tool", names: []string{"codex", "vim"}, wantErr: "invalid --race tool"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRaceTools(tt.names)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseRaceTools() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRaceTools() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseRaceTools() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseRaceTools() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRaceFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func()
+		wantErr string
+	}{
+		{name: "no race", set: func() { execRace = nil; execForce = "codex" }},
+		{name: "race", set: func() {}},
+		{name: "invalid pick", set: func() { execRacePick = "best" }, wantErr: "invalid --race-pick"},
+		{name: "force", set: func() { execForce = "codex" }, wantErr: "--force"},
+		{name: "isolate", set: func() { execIsolate = true }, wantErr: "--isolate"},
+		{name: "continue", set: func() { execContinue = true }, wantErr: "--continue"},
+		{name: "query mode", set: func() { execMode = "query" }, wantErr: "--mode query"},
+		{name: "record", set: func() { execRecord = "run.json" }, wantErr: "--record"},
+		{name: "one tool", set: func() { execRace = []string{"codex"} }, wantErr: "at least two"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			race, pick, force, isolate := execRace, execRacePick, execForce, execIsolate
+			cont, mode, record := execContinue, execMode, execRecord
+			t.Cleanup(func() {
+				execRace, execRacePick, execForce, execIsolate = race, pick, force, isolate
+				execContinue, execMode, execRecord = cont, mode, record
+			})
+			execRace, execRacePick = []string{"claude-code", "codex"}, RacePickAsk
+			execForce, execIsolate, execContinue, execMode, execRecord = "", false, false, "auto", ""
+			tt.set()
+
+			err := validateRaceFlags()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateRaceFlags() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateRaceFlags() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLabelWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := newLabelWriter(&out, &mu, "[1 codex]")
+
+	for _, chunk := range []string{"first li", "ne\nsecond line\nthi", "rd", " line\nno newline"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "[1 codex] first line\n[1 codex] second line\n[1 codex] third line\n"
+	if out.String() != want {
+		t.Fatalf("before Flush got %q, want %q", out.String(), want)
+	}
+
+	w.Flush()
+	want += "[1 codex] no newline\n"
+	if out.String() != want {
+		t.Errorf("after Flush got %q, want %q", out.String(), want)
+	}
+	w.Flush()
+	if out.String() != want {
+		t.Errorf("a second Flush wrote %q", strings.TrimPrefix(out.String(), want))
+	}
+}
+
+// fakeRacers stands in for running racers: they stop with the given
+// statuses, in order, and those with no status stop when cancelled
+func fakeRacers(statuses []string) ([]*Racer, <-chan *Racer) {
+	done := make(chan *Racer, len(statuses))
+	var racers []*Racer
+	for i, status := range statuses {
+		racer := &Racer{Tool: trackers.CodexTool, Name: "Racer", Status: RacerRunning, index: i, cancel: func() {}}
+		if status == "" {
+			ctx, cancel := context.WithCancel(context.Background())
+			racer.cancel = cancel
+			go func() {
+				<-ctx.Done()
+				racer.Status = RacerCancelled
+				done <- racer
+			}()
+		}
+		racers = append(racers, racer)
+	}
+	go func() {
+		for i, status := range statuses {
+			if status != "" {
+				racers[i].Status = status
+				done <- racers[i]
+			}
+		}
+	}()
+	return racers, done
+}
+
+func TestPickWinner(t *testing.T) {
+	tests := []struct {
+		name       string
+		pick       string
+		finish     []string // Status each racer finishes with, in order; "" never finishes
+		wantWinner int      // -1 for none
+		wantRest   string
+		wantStatus []string
+	}{
+		{
+			name:       "first passing racer wins and the rest are cancelled",
+			pick:       RacePickFirst,
+			finish:     []string{"", RacerDone, ""},
+			wantWinner: 1,
+			wantRest:   IsolateDiscard,
+			wantStatus: []string{RacerCancelled, RacerDone, RacerCancelled},
+		},
+		{
+			name:       "failed racers don't win",
+			pick:       RacePickFirst,
+			finish:     []string{RacerFailed, RacerDone},
+			wantWinner: 1,
+			wantRest:   IsolateDiscard,
+			wantStatus: []string{RacerFailed, RacerDone},
+		},
+		{
+			name:       "nobody passes",
+			pick:       RacePickFirst,
+			finish:     []string{RacerFailed, RacerFailed},
+			wantWinner: -1,
+			wantRest:   IsolateKeep,
+			wantStatus: []string{RacerFailed, RacerFailed},
+		},
+		{
+			name:       "keep waits for everyone",
+			pick:       RacePickKeep,
+			finish:     []string{RacerDone, RacerDone},
+			wantWinner: -1,
+			wantRest:   IsolateKeep,
+			wantStatus: []string{RacerDone, RacerDone},
+		},
+		{
+			// Nobody to ask in a test, so every result is kept
+			name:       "ask without a terminal",
+			pick:       RacePickAsk,
+			finish:     []string{RacerDone, RacerFailed},
+			wantWinner: -1,
+			wantRest:   IsolateKeep,
+			wantStatus: []string{RacerDone, RacerFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pick := execRacePick
+			execRacePick = tt.pick
+			t.Cleanup(func() { execRacePick = pick })
+
+			racers, done := fakeRacers(tt.finish)
+			var winner *Racer
+			var rest string
+			captureOutput(t, func() {
+				winner, rest = pickWinner(context.Background(), racers, done)
+			})
+
+			switch {
+			case tt.wantWinner < 0 && winner != nil:
+				t.Errorf("winner = racer %d, want none", winner.index)
+			case tt.wantWinner >= 0 && winner != racers[tt.wantWinner]:
+				t.Errorf("winner = %v, want racer %d", winner, tt.wantWinner)
+			}
+			if rest != tt.wantRest {
+				t.Errorf("rest = %q, want %q", rest, tt.wantRest)
+			}
+			// Every racer has stopped and been received when pickWinner returns
+			for i, racer := range racers {
+				if racer.Status != tt.wantStatus[i] {
+					t.Errorf("racer %d status = %q, want %q", i, racer.Status, tt.wantStatus[i])
+				}
+			}
+			if len(done) != 0 {
+				t.Errorf("%d racers left in done", len(done))
+			}
+		})
+	}
+}
+
+// initGitRepo makes dir a git repository with one committed file
+func initGitRepo(t *testing.T, dir string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "test"},
+		{"config", "user.email", "test@example.com"},
+		{"add", "-A"},
+		{"commit", "-q", "-m", "initial"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v: %s", args[0], err, out)
+		}
+	}
+}
+
+func TestFinishRaceKeepsUnappliableWinner(t *testing.T) {
+	repo := t.TempDir()
+	initGitRepo(t, repo)
+	state := t.TempDir()
+
+	result := &RaceResult{Task: "rewrite main"}
+	for i, id := range []string{"run-claude-code", "run-codex"} {
+		wt, err := workspace.Create(repo, state, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { wt.Remove() })
+		result.Racers = append(result.Racers, &Racer{Status: RacerDone, index: i, worktree: wt, Branch: wt.Branch})
+	}
+	winner, loser := result.Racers[0], result.Racers[1]
+
+	// The winner's change conflicts with one made meanwhile in the repository
+	if err := os.WriteFile(filepath.Join(winner.worktree.Path, "main.go"), []byte("package winner\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte("package edited\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	finishRace(result, winner, IsolateDiscard)
+
+	if winner.Action != IsolateKeep {
+		t.Errorf("winner action = %q, want %q", winner.Action, IsolateKeep)
+	}
+	if !strings.Contains(winner.Error, "kept on branch "+winner.Branch) {
+		t.Errorf("winner error = %q, want it to name the branch", winner.Error)
+	}
+	out, err := exec.Command("git", "-C", repo, "show", winner.Branch+":main.go").CombinedOutput()
+	if err != nil || string(out) != "package winner\n" {
+		t.Errorf("branch %s has main.go = %q (%v), want the winner's change", winner.Branch, out, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "main.go")); string(data) != "package edited\n" {
+		t.Errorf("repository main.go = %q, want the edit left alone", data)
+	}
+
+	if loser.Action != IsolateDiscard {
+		t.Errorf("loser action = %q, want %q", loser.Action, IsolateDiscard)
+	}
+	if _, err := os.Stat(loser.worktree.Path); !os.IsNotExist(err) {
+		t.Errorf("loser worktree still exists: %v", err)
+	}
+}
+
+// TestRaceEndToEnd races two tools played by the fake agent, which both
+// write the same file, and applies whichever passes first
+func TestRaceEndToEnd(t *testing.T) {
+	agent := mocks.BuildFakeAgent(t)
+	stream, err := filepath.Abs(filepath.Join("testdata", "exec", "claude-success.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupExec(t, agent)
+	work, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initGitRepo(t, work)
+
+	race, pick := execRace, execRacePick
+	execRace, execRacePick = []string{"claude-code", "codex"}, RacePickFirst
+	t.Cleanup(func() { execRace, execRacePick = race, pick })
+
+	fake := mocks.FakeAgent{Stream: stream, Write: "race.txt"}
+	fake.Setenv(t)
+
+	var result *RaceResult
+	stdout, stderr := captureOutput(t, func() {
+		result = executeRace(context.Background(), &TaskInput{Task: "add a race file"})
+		outputRaceText(result)
+	})
+	if result.Error != "" {
+		t.Fatalf("executeRace() error = %s", result.Error)
+	}
+	if len(result.Racers) != 2 || result.Winner == "" {
+		t.Fatalf("racers = %d, winner = %q, want two racers and a winner", len(result.Racers), result.Winner)
+	}
+
+	var winner *Racer
+	for _, racer := range result.Racers {
+		if racer.Tool == result.Winner {
+			winner = racer
+			if racer.Action != IsolateApply || racer.Status != RacerDone {
+				t.Errorf("winner %s: status %q, action %q, want done and applied", racer.Tool, racer.Status, racer.Action)
+			}
+			continue
+		}
+		if racer.Action != IsolateDiscard {
+			t.Errorf("loser %s: action %q, want %q", racer.Tool, racer.Action, IsolateDiscard)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(work, "race.txt")); err != nil || string(data) != "written by fakeagent\n" {
+		t.Errorf("race.txt = %q (%v), want the winner's file applied", data, err)
+	}
+	if !strings.Contains(stdout, "changes applied to the working tree") {
+		t.Errorf("output doesn't report the applied changes:\n%s", stdout)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		if line != "" && !strings.HasPrefix(line, "[1 claude-code] ") && !strings.HasPrefix(line, "[2 codex] ") {
+			t.Errorf("progress line isn't labelled with its racer: %q", line)
+		}
+	}
+
+	records, err := history.Open(config.StateDir()).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded bool
+	for _, record := range records {
+		if record.RunID == racerRunID(result, winner) {
+			recorded = true
+		}
+	}
+	if !recorded {
+		t.Errorf("history has no record of the winner %s: %d records", winner.Tool, len(records))
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+)
+
+var replaySpeed float64
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Replay a recorded tool session",
+	Long: `Replay a session recorded with exec --record, running the tool's output
+through the same parser and markdown rendering as the original run, at the
+recorded pace. No tool runs and no quota is spent.
+
+The transcript is printed to stdout and what the tool printed to stderr to
+stderr, each when the tool printed it. A recording of a run whose task was
+retried after failing verification holds every attempt; they are replayed
+in order.
+
+Recordings hold the exact bytes the tool printed, so they make reproducible
+reports of parsing bugs and fixtures for tests. They also hold the task and
+everything the tool printed: check them before sharing.
+
+Examples:
+  ai-dispatcher exec "fix the flaky test" --record session.jsonl
+  ai-dispatcher replay session.jsonl
+  ai-dispatcher replay session.jsonl --speed 4
+  ai-dispatcher replay session.jsonl --speed 0 > transcript.txt`,
+	Args: cobra.ExactArgs(1),
+	Run:  runReplay,
+}
+
+func init() {
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "Playback speed: 1 is the recorded pace, 4 four times faster, 0 without pauses")
+}
+
+func runReplay(cmd *cobra.Command, args []string) {
+	if replaySpeed < 0 {
+		exitWithError(fmt.Errorf("invalid --speed %v: must be 0 or more", replaySpeed))
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		exitWithError(fmt.Errorf("failed to open recording: %w", err))
+	}
+	recordings, err := delegators.ReadRecordings(f)
+	f.Close()
+	if err != nil {
+		exitWithError(err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	faint := color.New(color.Faint).SprintFunc()
+	for i, rec := range recordings {
+		if len(recordings) > 1 {
+			fmt.Fprintln(os.Stderr, faint(fmt.Sprintf("── Session %d of %d ──", i+1, len(recordings))))
+		}
+
+		result, err := rec.Replay(ctx, delegators.ReplayOptions{
+			Speed:  replaySpeed,
+			Output: os.Stdout,
+			Stderr: os.Stderr,
+			Render: isatty.IsTerminal(os.Stdout.Fd()),
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+		if result.Cancelled {
+			os.Exit(exitInterrupted)
+		}
+		fmt.Fprintln(os.Stderr, faint(describeReplay(rec, result)))
+	}
+}
+
+// describeReplay summarizes how a recorded session ended
+func describeReplay(rec *delegators.Recording, result *delegators.DelegationResult) string {
+	summary := fmt.Sprintf("%s exited with code %d after %s", rec.ToolName, rec.ExitCode, delegators.FormatDuration(rec.Duration))
+	if !rec.Started.IsZero() {
+		summary += fmt.Sprintf(", recorded %s", rec.Started.Local().Format("2006-01-02 15:04"))
+	}
+	if result.SessionID != "" {
+		summary += fmt.Sprintf(" (session %s)", result.SessionID)
+	}
+	return summary
+}
+
+// startRecording empties the --record file, so it only holds this run's
+// sessions, and checks it can be written before any tool runs
+func startRecording() error {
+	if execRecord == "" || execDryRun {
+		return nil
+	}
+	if err := os.WriteFile(execRecord, nil, 0o644); err != nil {
+		return fmt.Errorf("can't record to %s: %w", execRecord, err)
+	}
+	return nil
+}
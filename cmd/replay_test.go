@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+	"github.com/crlian/ai-dispatcher/test/mocks"
+)
+
+// TestExecRecordReplay records a run through the exec pipeline and checks
+// that replaying it gives the run's transcript and result
+func TestExecRecordReplay(t *testing.T) {
+	agent := mocks.BuildFakeAgent(t)
+	stream, err := filepath.Abs(filepath.Join("testdata", "exec", "claude-success.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setupExec(t, agent)
+	execForce = "claude-code"
+	execRecord = filepath.Join(t.TempDir(), "session.jsonl")
+	t.Cleanup(func() { execRecord = "" })
+	(&mocks.FakeAgent{Stream: stream, Stderr: "warning: using a fake agent"}).Setenv(t)
+
+	if err := startRecording(); err != nil {
+		t.Fatal(err)
+	}
+	var result *PipelineResult
+	captureOutput(t, func() {
+		result = executePipeline(context.Background(), &TaskInput{Task: "fix the token expiry bug in auth.go"})
+	})
+	if result.Error != "" {
+		t.Fatalf("executePipeline() error = %s", result.Error)
+	}
+
+	f, err := os.Open(execRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	recordings, err := delegators.ReadRecordings(f)
+	if err != nil {
+		t.Fatalf("ReadRecordings() error = %v", err)
+	}
+	if len(recordings) != 1 || recordings[0].Command != agent {
+		t.Fatalf("recordings = %+v, want one session of the fake agent", recordings)
+	}
+
+	var transcript bytes.Buffer
+	replayed, err := recordings[0].Replay(context.Background(), delegators.ReplayOptions{Output: &transcript})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	run := result.ExecutionResult
+	if replayed.Output != run.Output || replayed.SessionID != run.SessionID || replayed.TokensUsed != run.TokensUsed {
+		t.Errorf("replay = %+v, run = %+v", replayed, run)
+	}
+	want := "Reading auth.go to find the bug.\nThe token expiry was compared in seconds against milliseconds. Fixed the comparison.\n"
+	if transcript.String() != want {
+		t.Errorf("transcript = %q, want %q", transcript.String(), want)
+	}
+}
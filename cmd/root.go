@@ -3,8 +3,17 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/history"
+	"github.com/crlian/ai-dispatcher/pkg/progress"
+	"github.com/crlian/ai-dispatcher/pkg/router"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
 )
 
 // Version information (set by main package)
@@ -28,8 +37,20 @@ Features:
   • Usage limit monitoring
   • Multi-tool support`,
 	Version: Version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		mode, err := progress.ParseMode(progressFlag)
+		if err != nil {
+			exitWithError(err)
+		}
+		progressMode = mode
+	},
 }
 
+var (
+	progressFlag string
+	progressMode progress.Mode // Set from --progress before any command runs
+)
+
 // Execute runs the root command
 func Execute() error {
 	return rootCmd.Execute()
@@ -42,10 +63,26 @@ func init() {
 		Version, BuildTime, GitCommit,
 	))
 
+	rootCmd.PersistentFlags().StringVar(&progressFlag, "progress", string(progress.Auto), "How progress is shown: auto, tty, plain (timestamped lines) or none; auto is plain in CI and when not on a terminal")
+
 	// Add subcommands
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(execCmd)
 	rootCmd.AddCommand(councilCmd)
+	rootCmd.AddCommand(simulateCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(replayCmd)
+}
+
+// loadTrackers returns the usage trackers the commands route with (replaced
+// in tests, which can't read real usage)
+var loadTrackers = trackers.GetAllTrackers
+
+// newProgress returns a progress reporter writing to f, in the --progress
+// mode or the one detected for f
+func newProgress(f *os.File, idle time.Duration) progress.Reporter {
+	return progress.New(progress.Resolve(progressMode, f), f, idle)
 }
 
 // exitWithError prints error and exits
@@ -53,3 +90,55 @@ func exitWithError(err error) {
 	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	os.Exit(1)
 }
+
+// strategyFlagUsage is the help text shared by every --strategy flag
+var strategyFlagUsage = fmt.Sprintf("Routing strategy (%s)", strings.Join(router.StrategyNames(), ", "))
+
+// loadStrategy resolves the routing strategy from the --strategy flag,
+// falling back to the config file and then the default strategy. The fastest
+// strategy is given the tools' median durations from the history.
+func loadStrategy(name string) (router.Strategy, error) {
+	if name == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+		name = cfg.Strategy
+	}
+	strategy, err := router.GetStrategy(name, config.StateDir())
+	if err != nil {
+		return nil, err
+	}
+	if fastest, ok := strategy.(*router.FastestStrategy); ok {
+		if records, err := history.Open(config.StateDir()).Load(); err == nil {
+			durations := make(map[trackers.ToolType]time.Duration)
+			for tool, d := range history.MedianDurations(records) {
+				durations[trackers.ToolType(tool)] = d
+			}
+			fastest.SetDurations(durations)
+		}
+	}
+	return strategy, nil
+}
+
+// newComplexityAnalyzer creates an analyzer with the keyword packs from the
+// config and the recorded runs as an extra voter
+func newComplexityAnalyzer() (*analyzers.ComplexityAnalyzer, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var packs map[string]*analyzers.KeywordPack
+	analyzer := analyzers.NewComplexityAnalyzer()
+	if len(cfg.Keywords) > 0 {
+		packs = analyzers.MergeKeywordPacks(analyzers.DefaultKeywordPacks(), cfg.Keywords)
+		analyzer.SetKeywordPacks(packs)
+	}
+
+	// Past runs vote too; without a readable history the other analyzers decide
+	if records, err := history.Open(config.StateDir()).Load(); err == nil && len(records) > 0 {
+		analyzer.AddAnalyzer(analyzers.NewHistoryAnalyzer(records, packs), 1, 0)
+	}
+	return analyzer, nil
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/history"
+	"github.com/crlian/ai-dispatcher/pkg/router"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// validateSessionFlags checks that --continue and --resume are used alone
+// and with flags a resumed session can honour
+func validateSessionFlags() error {
+	if !execContinue && execResume == "" {
+		return nil
+	}
+	switch {
+	case execContinue && execResume != "":
+		return errors.New("--continue and --resume can't be used together")
+	case execMode == string(router.ModeQuery):
+		return errors.New("--mode query can't resume a session; resumed runs always execute")
+	case execIsolate:
+		// Tools keep sessions per directory, so a worktree can't find them
+		return errors.New("--isolate can't resume a session started in the working tree")
+	}
+	return nil
+}
+
+// resumedRun returns the run whose tool session this run continues, or nil
+// when it starts a new session
+func resumedRun() (*history.Record, error) {
+	if !execContinue && execResume == "" {
+		return nil, nil
+	}
+	store := history.Open(config.StateDir())
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the working directory: %w", err)
+	}
+
+	// Tools keep sessions per directory, so only runs here can be resumed
+	var record *history.Record
+	if execContinue {
+		record, err = store.LastWithSession(dir)
+	} else {
+		record, err = store.Find(execResume)
+		switch {
+		case err != nil:
+		case record.SessionID == "":
+			err = fmt.Errorf("run %s has no session to resume (%s didn't report one)", record.RunID, record.Tool)
+		case record.Dir != "" && record.Dir != dir:
+			err = fmt.Errorf("run %s ran in %s; its session can only be resumed from there", record.RunID, record.Dir)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Routing sticks to the tool that owns the session
+	tool, err := trackers.ValidateToolType(record.Tool)
+	if err != nil {
+		return nil, fmt.Errorf("run %s used unknown tool %q", record.RunID, record.Tool)
+	}
+	for _, flag := range []struct{ name, value string }{{"--force", execForce}, {"--wait-for", execWaitFor}} {
+		if flag.value == "" {
+			continue
+		}
+		if requested, err := trackers.ValidateToolType(flag.value); err == nil && requested != tool {
+			return nil, fmt.Errorf("%s %s conflicts with the session of run %s, which belongs to %s", flag.name, requested, record.RunID, tool)
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/history"
+)
+
+func TestResumedRun(t *testing.T) {
+	t.Setenv("AI_DISPATCHER_HOME", t.TempDir())
+	here, other := t.TempDir(), t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(here); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if here, err = os.Getwd(); err != nil {
+		t.Fatal(err)
+	}
+
+	store := history.Open(config.StateDir())
+	for _, record := range []*history.Record{
+		{RunID: "20260101-120000-aaaaaa", Tool: "claude-code", SessionID: "here", Dir: here},
+		{RunID: "20260101-130000-bbbbbb", Tool: "codex", SessionID: "other", Dir: other},
+		{RunID: "20260101-140000-cccccc", Tool: "codex"},
+	} {
+		if err := store.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		cont    bool
+		resume  string
+		wantRun string
+		wantErr string
+	}{
+		{name: "continue skips other directories", cont: true, wantRun: "20260101-120000-aaaaaa"},
+		{name: "resume here", resume: "20260101-120000", wantRun: "20260101-120000-aaaaaa"},
+		{name: "resume from another directory", resume: "20260101-130000", wantErr: "can only be resumed from there"},
+		{name: "resume without a session", resume: "20260101-140000", wantErr: "has no session"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			execContinue, execResume = tt.cont, tt.resume
+			t.Cleanup(func() { execContinue, execResume = false, "" })
+
+			record, err := resumedRun()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resumedRun() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resumedRun() error = %v", err)
+			}
+			if record.RunID != tt.wantRun {
+				t.Errorf("resumedRun() = %s, want %s", record.RunID, tt.wantRun)
+			}
+		})
+	}
+}
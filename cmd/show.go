@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+
+	"github.com/crlian/ai-dispatcher/pkg/artifacts"
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+	"github.com/crlian/ai-dispatcher/pkg/history"
+)
+
+// defaultPager pages output when $PAGER is unset
+const defaultPager = "less -R"
+
+var (
+	showRaw     bool
+	showStderr  bool
+	showJSON    bool
+	showNoPager bool
+)
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <run-id>",
+	Short: "Show the full output of a past run",
+	Long: `Show the full output of a run from its artifacts directory, with markdown
+rendering, paged through $PAGER (less -R when unset) on a terminal.
+
+Every exec run keeps the tool's raw stream, stderr, parsed transcript and
+final result under ~/.ai-dispatcher/runs/<run-id>. A unique prefix of the
+run ID is enough.
+
+Examples:
+  ai-dispatcher show 20250114-093012-a1b2c3
+  ai-dispatcher show 20250114-0930 --stderr
+  ai-dispatcher show 20250114-0930 --json | jq .execution_result`,
+	Args: cobra.ExactArgs(1),
+	Run:  runShow,
+}
+
+func init() {
+	showCmd.Flags().BoolVar(&showRaw, "raw", false, "Show the raw stream as the tool printed it")
+	showCmd.Flags().BoolVar(&showStderr, "stderr", false, "Show what the tool printed to stderr")
+	showCmd.Flags().BoolVar(&showJSON, "json", false, "Show the final result as JSON")
+	showCmd.Flags().BoolVar(&showNoPager, "no-pager", false, "Print to stdout instead of paging")
+	showCmd.MarkFlagsMutuallyExclusive("raw", "stderr", "json")
+}
+
+func runShow(cmd *cobra.Command, args []string) {
+	stateDir := config.StateDir()
+	record, err := history.Open(stateDir).Find(args[0])
+	if err != nil {
+		exitWithError(err)
+	}
+	dir := artifacts.Dir(stateDir, record.RunID)
+
+	var content string
+	switch {
+	case showRaw:
+		content, err = artifacts.Read(dir, artifacts.StreamFile)
+	case showStderr:
+		content, err = artifacts.Read(dir, artifacts.StderrFile)
+	case showJSON:
+		content, err = artifacts.Read(dir, artifacts.ResultFile)
+	default:
+		content, err = formatTranscript(record, dir)
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+
+	if err := page(content, showNoPager || showJSON); err != nil {
+		exitWithError(err)
+	}
+}
+
+// formatTranscript formats a run's summary and its transcript, rendering
+// markdown when it looks like markdown
+func formatTranscript(record *history.Record, dir string) (string, error) {
+	transcript, err := artifacts.Read(dir, artifacts.TranscriptFile)
+	if err != nil {
+		return "", err
+	}
+
+	status := "failed"
+	switch {
+	case record.Cancelled:
+		status = "cancelled"
+	case record.Success:
+		status = "succeeded"
+	}
+
+	var b strings.Builder
+	cyan := color.New(color.FgCyan).SprintFunc()
+	field := func(label, format string, args ...any) {
+		fmt.Fprintf(&b, "%s %s\n", cyan(fmt.Sprintf("%-10s", label+":")), fmt.Sprintf(format, args...))
+	}
+	field("Run", "%s", record.RunID)
+	field("Time", "%s", record.Time.Local().Format("2006-01-02 15:04:05"))
+	field("Tool", "%s (%s)", record.Tool, record.Mode)
+	field("Result", "%s in %s, ~%d tokens", status, delegators.FormatDuration(record.Duration), record.ActualTokens)
+	field("Task", "%s", strings.TrimSpace(strings.SplitN(record.Task, "\n", 2)[0]))
+	field("Artifacts", "%s", dir)
+	b.WriteString("\n")
+
+	if isLikelyMarkdown(transcript) {
+		transcript = delegators.RenderMarkdown(transcript)
+	}
+	b.WriteString(transcript)
+	return b.String(), nil
+}
+
+// page writes content through $PAGER when stdout is a terminal, and straight
+// to stdout otherwise
+func page(content string, noPager bool) error {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = defaultPager
+	}
+	fields := strings.Fields(pager)
+	if noPager || len(fields) == 0 || !isatty.IsTerminal(os.Stdout.Fd()) {
+		_, err := io.WriteString(os.Stdout, content)
+		return err
+	}
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		_, err := io.WriteString(os.Stdout, content) // No pager installed
+		return err
+	}
+
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pager %q failed: %w", pager, err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+)
+
+// exitInterrupted is the exit code of a run stopped by SIGINT or SIGTERM
+const exitInterrupted = 130
+
+var (
+	interruptMu       sync.Mutex
+	interruptCleanups = map[int]func(){}
+	nextCleanupID     int
+)
+
+// interruptContext returns a context that is cancelled on the first SIGINT or
+// SIGTERM, with the signal as its cause, so the running tool is stopped and
+// its partial result reported. A second signal kills the running tools, runs
+// the registered cleanups and exits immediately.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	// The tools run in their own process groups, which the terminal's signals
+	// don't reach, and the grace period of the first signal ends with us.
+	// Registered first so they stop before the cleanups remove their files.
+	stopKilling := onForcedExit(delegators.KillRunning)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			cancel(fmt.Errorf("interrupted by %s", signalName(sig)))
+			fmt.Fprintln(os.Stderr, "\nStopping... (press Ctrl-C again to quit now)")
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			runInterruptCleanups()
+			os.Exit(exitInterrupted)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		stopKilling()
+		cancel(nil)
+	}
+}
+
+// onForcedExit registers a cleanup to run if the process is made to exit by
+// a second signal, until the returned function unregisters it
+func onForcedExit(cleanup func()) func() {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+
+	id := nextCleanupID
+	nextCleanupID++
+	interruptCleanups[id] = cleanup
+	return func() {
+		interruptMu.Lock()
+		defer interruptMu.Unlock()
+		delete(interruptCleanups, id)
+	}
+}
+
+// runInterruptCleanups runs the cleanups registered with onForcedExit, in
+// the order they were registered
+func runInterruptCleanups() {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	ids := make([]int, 0, len(interruptCleanups))
+	for id := range interruptCleanups {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		interruptCleanups[id]()
+	}
+}
+
+// interrupted reports whether ctx was cancelled by a signal
+func interrupted(ctx context.Context) bool {
+	return ctx.Err() != nil
+}
+
+// signalName returns the conventional name of a signal (SIGINT, SIGTERM)
+func signalName(sig os.Signal) string {
+	switch sig {
+	case os.Interrupt:
+		return "SIGINT"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	default:
+		return sig.String()
+	}
+}
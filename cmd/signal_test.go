@@ -0,0 +1,108 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+)
+
+// TestForcedExitHelper runs a tool that ignores SIGTERM under
+// interruptContext, for TestForcedExitKillsTools to interrupt twice
+func TestForcedExitHelper(t *testing.T) {
+	pidFile := os.Getenv("FORCED_EXIT_PID_FILE")
+	if pidFile == "" {
+		t.Skip("run by TestForcedExitKillsTools")
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	bd := delegators.NewBaseDelegator("Shell", "shell", "sh")
+	bd.SetOutput(io.Discard)
+	script := `trap "" TERM; sleep 300 & echo $! > ` + pidFile + `; wait`
+	bd.ExecuteCommand(ctx, []string{"-c", script})
+}
+
+// TestForcedExitKillsTools checks that a second signal kills the tool's
+// process group instead of leaving it running after the dispatcher exits
+func TestForcedExitKillsTools(t *testing.T) {
+	if os.Getenv("FORCED_EXIT_PID_FILE") != "" {
+		t.Skip("helper process")
+	}
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	helper := exec.Command(os.Args[0], "-test.run=^TestForcedExitHelper$")
+	helper.Env = append(os.Environ(), "FORCED_EXIT_PID_FILE="+pidFile)
+	stderr, err := helper.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := helper.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer helper.Process.Kill()
+
+	// Wait for the tool to start its child
+	var pid int
+	for deadline := time.Now().Add(10 * time.Second); pid == 0; {
+		if data, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(data), "\n") {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the tool didn't start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer syscall.Kill(pid, syscall.SIGKILL)
+
+	// The first signal only stops the tool gracefully, which it ignores
+	helper.Process.Signal(os.Interrupt)
+	lines := bufio.NewScanner(stderr)
+	for lines.Scan() && !strings.Contains(lines.Text(), "Stopping") {
+	}
+	if !alive(pid) {
+		t.Fatal("the tool's child stopped on SIGTERM, which it ignores")
+	}
+
+	helper.Process.Signal(os.Interrupt)
+	go io.Copy(io.Discard, stderr)
+	ctx, cancel := context.WithTimeout(context.Background(), delegators.KillGracePeriod/2)
+	defer cancel()
+	err = helper.Wait()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitInterrupted {
+		t.Fatalf("helper exited with %v, want code %d", err, exitInterrupted)
+	}
+	for alive(pid) {
+		if ctx.Err() != nil {
+			t.Fatalf("the tool's child %d is still running after a forced exit", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// alive reports whether a process is running. Orphans whose parent doesn't
+// reap them stay zombies, which count as stopped.
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	// pid (comm) state ...
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
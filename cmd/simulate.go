@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/crlian/ai-dispatcher/pkg/router"
+	"github.com/crlian/ai-dispatcher/pkg/simulator"
+)
+
+var (
+	simulateScenario string
+	simulateJSON     bool
+)
+
+// simulateCmd represents the simulate command
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Replay a recorded scenario through the router offline",
+	Long: `Replay a sequence of tasks against scripted tool states without calling any AI tool.
+
+For every task the simulator reports:
+  • Which tool the router would select
+  • The projected spend
+  • The remaining capacity after the task
+  • When each tool's window would be exhausted
+
+Use it to tune routing policies and thresholds before rolling them out.
+
+Scenario file (YAML):
+  name: morning rush
+  availability_threshold: 5
+  tools:
+    - tool: claude-code
+      available: 60
+      resets_in: 2h
+      window_tokens: 20000
+  tasks:
+    - at: 0m
+      task: "fix typo in README"
+    - at: 15m
+      task: "refactor the auth service"
+      tokens: 3000
+
+Examples:
+  ai-dispatcher simulate --scenario scenario.yaml
+  ai-dispatcher simulate --scenario scenario.yaml --json`,
+	Args: cobra.NoArgs,
+	Run:  runSimulate,
+}
+
+func init() {
+	simulateCmd.Flags().StringVar(&simulateScenario, "scenario", "", "Path to the scenario file (YAML or JSON)")
+	simulateCmd.Flags().BoolVar(&simulateJSON, "json", false, "Output report in JSON format")
+	simulateCmd.MarkFlagRequired("scenario")
+}
+
+func runSimulate(cmd *cobra.Command, args []string) {
+	scenario, err := simulator.LoadScenario(simulateScenario)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	report, err := simulator.NewSimulator(scenario).Run()
+	if err != nil {
+		exitWithError(fmt.Errorf("simulation failed: %w", err))
+	}
+
+	if simulateJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			exitWithError(fmt.Errorf("failed to encode JSON: %w", err))
+		}
+		return
+	}
+
+	outputSimulationReport(report)
+}
+
+// outputSimulationReport prints the simulation report as tables
+func outputSimulationReport(report *simulator.Report) {
+	cyan := color.New(color.FgCyan).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	fmt.Println()
+	if report.Scenario != "" {
+		fmt.Printf("🧪 Simulation: %s\n", report.Scenario)
+	} else {
+		fmt.Println("🧪 Simulation")
+	}
+	fmt.Printf("   Availability threshold: %.1f%%\n", report.Threshold)
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", "At", "Task", "Level", "Tool", "Cost", "Left")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", "──", "────", "─────", "────", "────", "────")
+	for _, outcome := range report.Outcomes {
+		tool := outcome.ToolName
+		cost := router.FormatCost(outcome.EstimatedCost)
+		left := fmt.Sprintf("%.1f%%", outcome.AvailableAfter)
+		if outcome.Error != "" {
+			tool = red("✗ unrouted")
+			cost = "N/A"
+			left = "N/A"
+		} else if outcome.WasForced {
+			tool = yellow(tool + " (forced)")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatOffset(outcome.At),
+			truncateTask(outcome.Task, 40),
+			outcome.Level,
+			tool,
+			cost,
+			left,
+		)
+	}
+	w.Flush()
+	fmt.Println()
+
+	fmt.Println(cyan("Projected usage"))
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", "Tool", "Tasks", "Tokens", "Spend", "Left", "Exhausted")
+	for _, summary := range report.Tools {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%.1f%%\t%d\n",
+			summary.ToolName,
+			summary.Tasks,
+			summary.Tokens,
+			router.FormatCost(summary.Spend),
+			summary.FinalAvailable,
+			summary.Exhaustions,
+		)
+	}
+	w.Flush()
+	fmt.Println()
+
+	if len(report.Exhaustions) > 0 {
+		fmt.Println(cyan("Window exhaustion"))
+		for _, ex := range report.Exhaustions {
+			fmt.Printf("   %s %s exhausted at %s (resets at %s) by %q\n",
+				red("✗"), ex.ToolName, formatOffset(ex.At), formatOffset(ex.ResetsAt), truncateTask(ex.Task, 40))
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("   Total projected spend: %s\n", router.FormatCost(report.TotalSpend))
+	if report.Unrouted > 0 {
+		fmt.Printf("   %s\n", red(fmt.Sprintf("%d task(s) could not be routed", report.Unrouted)))
+	}
+	fmt.Println()
+}
+
+// formatOffset formats a scenario offset as +HhMm
+func formatOffset(d time.Duration) string {
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours > 0 {
+		return fmt.Sprintf("+%dh%02dm", hours, minutes)
+	}
+	return fmt.Sprintf("+%dm", minutes)
+}
+
+// truncateTask shortens a task description for table display
+func truncateTask(task string, max int) string {
+	runes := []rune(task)
+	if len(runes) <= max {
+		return task
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/crlian/ai-dispatcher/pkg/calibration"
+	"github.com/crlian/ai-dispatcher/pkg/config"
+)
+
+var statsJSON bool
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show token estimation accuracy and calibration factors",
+	Long: `Show how far the analyzer's token estimates were from the tokens actually
+used, and the correction factors fitted from past runs.
+
+Factors are fitted per tool, complexity level and category ("*" aggregates
+over a field). A group needs at least 3 runs before its factor is applied;
+otherwise the next broader group is used.`,
+	Args: cobra.NoArgs,
+	Run:  runStats,
+}
+
+func init() {
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Output in JSON format")
+}
+
+// StatsReport is the JSON output of the stats command
+type StatsReport struct {
+	Samples    int                   `json:"samples"`
+	MinSamples int                   `json:"min_samples"`
+	Overall    *calibration.Factor   `json:"overall,omitempty"`
+	Factors    []*calibration.Factor `json:"factors"`
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+	samples, err := calibration.Open(config.StateDir()).Load()
+	if err != nil {
+		exitWithError(err)
+	}
+
+	model := calibration.Fit(samples)
+	report := &StatsReport{
+		Samples:    len(samples),
+		MinSamples: calibration.MinSamples,
+		Overall:    model.Overall(),
+		Factors:    model.Factors(),
+	}
+
+	if statsJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			exitWithError(fmt.Errorf("failed to encode JSON: %w", err))
+		}
+		return
+	}
+
+	outputStatsTable(report)
+}
+
+// outputStatsTable prints the estimation error and factors as a table
+func outputStatsTable(report *StatsReport) {
+	gray := color.New(color.FgHiBlack).SprintFunc()
+
+	fmt.Println()
+	fmt.Println("📈 Token Estimation Stats")
+	fmt.Println()
+
+	if report.Overall == nil {
+		fmt.Println("No runs recorded yet. Stats appear after the first successful exec.")
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf("Runs: %d\n", report.Samples)
+	fmt.Printf("Estimation error: %.0f%% uncalibrated, %.0f%% calibrated (x%.2f overall)\n",
+		report.Overall.ErrorBefore*100, report.Overall.ErrorAfter*100, report.Overall.Value)
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", "Tool", "Level", "Category", "Runs", "Factor", "Error", "Calibrated")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", "────", "─────", "────────", "────", "──────", "─────", "──────────")
+
+	for _, f := range report.Factors {
+		factor := fmt.Sprintf("x%.2f", f.Value)
+		if f.Samples < report.MinSamples {
+			factor = gray(factor + " (unused)")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%.0f%%\t%.0f%%\n",
+			orAll(f.Key.Tool),
+			orAll(f.Key.Level),
+			orAll(f.Key.Category),
+			f.Samples,
+			factor,
+			f.ErrorBefore*100,
+			f.ErrorAfter*100,
+		)
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Printf("Groups with fewer than %d runs fall back to a broader group.\n", report.MinSamples)
+	fmt.Println()
+}
+
+// orAll shows an aggregated key field as "*"
+func orAll(field string) string {
+	if field == "" {
+		return "*"
+	}
+	return field
+}
@@ -5,18 +5,23 @@ import (
 	"fmt"
 	"os"
 	"text/tabwriter"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
 	"github.com/crlian/ai-dispatcher/pkg/router"
-	"github.com/crlian/ai-dispatcher/pkg/trackers"
 )
 
 var (
-	statusJSON bool
+	statusJSON     bool
+	statusStrategy string
 )
 
+// statusProgressDelay is how long checking the tools takes before a spinner shows
+const statusProgressDelay = 300 * time.Millisecond
+
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
 	Use:   "status",
@@ -25,33 +30,84 @@ var statusCmd = &cobra.Command{
   • Available capacity percentage
   • Remaining time until limit reset
   • Current cost in 5-hour window
-  • Availability status`,
+  • Availability status
+  • Preference order under the selected routing strategy`,
 	Run: runStatus,
 }
 
 func init() {
 	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "Output in JSON format")
+	statusCmd.Flags().StringVar(&statusStrategy, "strategy", "", strategyFlagUsage)
 }
 
 func runStatus(cmd *cobra.Command, args []string) {
 	// Get all trackers
-	allTrackers := trackers.GetAllTrackers()
+	allTrackers := loadTrackers()
+
+	strategy, err := loadStrategy(statusStrategy)
+	if err != nil {
+		exitWithError(fmt.Errorf("invalid strategy: %w", err))
+	}
 
 	// Create decision engine
-	engine := router.NewDecisionEngine(allTrackers)
+	engine := router.NewDecisionEngine(allTrackers, strategy)
 
-	// Get tool status
+	// Get tool status, which can mean asking each tool's API for its usage
+	reporter := newProgress(os.Stderr, statusProgressDelay)
+	reporter.Start("Checking tool usage")
 	statuses, err := engine.GetToolStatus()
+	reporter.Stop()
 	if err != nil {
 		exitWithError(fmt.Errorf("failed to get tool status: %w", err))
 	}
 
+	// Rank the tools as the strategy would for a typical (medium) task
+	rankStatuses(engine, statuses)
+
 	// Output based on format
 	if statusJSON {
 		outputJSON(statuses)
 	} else {
 		outputTable(statuses)
+		fmt.Printf("Routing strategy: %s\n", strategy.Name())
+		if next := nextPick(statuses); next != nil {
+			fmt.Printf("Next pick for a medium task: %s\n", next.ToolName)
+		} else {
+			fmt.Println("Next pick for a medium task: none (no tools available)")
+		}
+		fmt.Println()
+	}
+}
+
+// rankStatuses fills in each tool's position in the strategy's preference order
+func rankStatuses(engine *router.DecisionEngine, statuses []*router.ToolStatus) {
+	ranked, err := engine.Rank(&analyzers.ComplexityAnalysis{
+		Level:      analyzers.Medium,
+		Tokens:     500,
+		Confidence: 1.0,
+		Method:     "status",
+	})
+	if err != nil {
+		return
+	}
+
+	for i, estimate := range ranked {
+		for _, status := range statuses {
+			if status.Tool == estimate.Tool {
+				status.Rank = i + 1
+			}
+		}
+	}
+}
+
+// nextPick returns the tool ranked first, if any
+func nextPick(statuses []*router.ToolStatus) *router.ToolStatus {
+	for _, status := range statuses {
+		if status.Rank == 1 {
+			return status
+		}
 	}
+	return nil
 }
 
 // outputJSON outputs status in JSON format
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/crlian/ai-dispatcher/pkg/config"
+	"github.com/crlian/ai-dispatcher/pkg/delegators"
+	"github.com/crlian/ai-dispatcher/pkg/router"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+	"github.com/crlian/ai-dispatcher/pkg/verify"
+)
+
+// Where retries after a failed verification are sent
+const (
+	RetrySame        = "same"
+	RetryAlternative = "alternative" // The best available tool other than the one that failed
+)
+
+// VerificationResult records the verification commands run after a task and
+// the attempts made to fix their failures
+type VerificationResult struct {
+	Commands []string       `json:"commands"`
+	Passed   bool           `json:"passed"`
+	Rounds   []*VerifyRound `json:"rounds"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// VerifyRound is one attempt at the task and the verification that followed
+type VerifyRound struct {
+	Round      int              `json:"round"` // 0 is the original attempt
+	Tool       string           `json:"tool"`
+	Success    bool             `json:"success"` // The tool's own exit status
+	TokensUsed int              `json:"tokens_used"`
+	Duration   time.Duration    `json:"duration"`
+	Checks     []*verify.Result `json:"checks"`
+}
+
+// Retries returns how many times the task was re-sent
+func (v *VerificationResult) Retries() int {
+	if len(v.Rounds) == 0 {
+		return 0
+	}
+	return len(v.Rounds) - 1
+}
+
+// TokensUsed returns the tokens used across all attempts
+func (v *VerificationResult) TokensUsed() int {
+	total := 0
+	for _, round := range v.Rounds {
+		total += round.TokensUsed
+	}
+	return total
+}
+
+// resolveVerifySettings fills the verification flags that weren't given from
+// the config and validates them
+func resolveVerifySettings(cmd *cobra.Command) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	if !flags.Changed("verify") && !execNoVerify {
+		execVerify = cfg.Verify
+	}
+	if execNoVerify {
+		execVerify = nil
+	}
+	if !flags.Changed("verify-retries") {
+		execVerifyRetries = cfg.VerifyRetries
+	}
+	if !flags.Changed("retry-tool") && cfg.RetryTool != "" {
+		execRetryTool = cfg.RetryTool
+	}
+
+	if execVerifyRetries < 0 {
+		return fmt.Errorf("invalid --verify-retries %d: must not be negative", execVerifyRetries)
+	}
+	switch execRetryTool {
+	case RetrySame, RetryAlternative:
+	default:
+		if _, err := trackers.ValidateToolType(execRetryTool); err != nil {
+			return fmt.Errorf("invalid --retry-tool %q: must be same, alternative or a tool name", execRetryTool)
+		}
+	}
+	return nil
+}
+
+// verifyAndRetry runs the verification commands after a task. While they fail
+// and retries remain, the task is re-sent with the failure output appended.
+// It returns the result of the last attempt.
+func verifyAndRetry(ctx context.Context, delegator delegators.Delegator, decision *router.RoutingDecision,
+	input *TaskInput, workDir, logDir string, execResult *delegators.DelegationResult) (*delegators.DelegationResult, *VerificationResult) {
+	verification := &VerificationResult{Commands: execVerify}
+
+	for round := 0; ; round++ {
+		if execVerbose {
+			fmt.Println()
+			fmt.Printf("🔎 Verifying (round %d)...\n", round)
+		}
+		checks := runVerification(ctx, workDir)
+		verification.Rounds = append(verification.Rounds, &VerifyRound{
+			Round:      round,
+			Tool:       execResult.ToolName,
+			Success:    execResult.Success,
+			TokensUsed: execResult.TokensUsed,
+			Duration:   execResult.Duration,
+			Checks:     checks,
+		})
+		if execVerbose {
+			for _, check := range checks {
+				fmt.Printf("   %s %s\n", checkStatus(check), check.Command)
+			}
+		}
+
+		if verify.Passed(checks) {
+			verification.Passed = true
+			return execResult, verification
+		}
+		if round >= execVerifyRetries {
+			return execResult, verification
+		}
+
+		if round == 0 {
+			retry, err := retryDelegator(delegator, decision, input.Attachments, workDir, logDir)
+			if err != nil {
+				verification.Error = fmt.Sprintf("retry failed: %v", err)
+				return execResult, verification
+			}
+			delegator = retry
+		}
+		// The same tool continues its session, so it keeps the context of the attempt
+		if delegator.GetToolName() == execResult.ToolName && execResult.SessionID != "" {
+			delegator.SetSession(execResult.SessionID)
+		}
+		if execVerbose {
+			fmt.Printf("   Re-sending the task to %s with the failures (retry %d/%d)\n", delegator.GetToolName(), round+1, execVerifyRetries)
+		}
+
+		next, err := delegator.Execute(ctx, verify.FailurePrompt(input.Task, checks))
+		if err != nil {
+			verification.Error = fmt.Sprintf("retry failed: %v", err)
+			return execResult, verification
+		}
+		if next.Cancelled {
+			verification.Error = fmt.Sprintf("retry %s", next.CancelReason)
+			return next, verification
+		}
+		execResult = next
+	}
+}
+
+// runVerification runs the verification commands in workDir
+func runVerification(ctx context.Context, workDir string) []*verify.Result {
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+	return verify.RunAll(ctx, workDir, execVerify)
+}
+
+// retryDelegator returns the delegator retries are sent to
+func retryDelegator(current delegators.Delegator, decision *router.RoutingDecision,
+	attachments []*delegators.Attachment, workDir, logDir string) (delegators.Delegator, error) {
+	var tool trackers.ToolType
+	switch execRetryTool {
+	case RetrySame:
+		return current, nil
+	case RetryAlternative:
+		for _, alt := range decision.Alternatives {
+			if alt.IsAvailable && alt.Tool != decision.SelectedTool {
+				tool = alt.Tool
+				break
+			}
+		}
+		if tool == "" {
+			return current, nil // Nothing else is available, so try again with the same tool
+		}
+	default:
+		parsed, err := trackers.ValidateToolType(execRetryTool)
+		if err != nil {
+			return nil, err
+		}
+		if parsed == decision.SelectedTool {
+			return current, nil
+		}
+		tool = parsed
+	}
+
+	delegator, err := delegators.GetDelegator(tool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delegator: %w", err)
+	}
+	delegator.SetTimeout(execTimeout)
+	delegator.SetAttachments(attachments)
+	delegator.SetPermissions(execProfile)
+	execTools.configure(delegator)
+	delegator.SetWorkDir(workDir)
+	delegator.SetLogDir(logDir)
+	delegator.SetRecording(execRecord)
+	delegator.SetProgress(progressMode)
+	delegator.SetEvents(streamEvents)
+	return delegator, nil
+}
+
+// checkStatus formats whether a verification command passed
+func checkStatus(check *verify.Result) string {
+	if check.Passed {
+		return color.New(color.FgGreen).Sprint("PASSED")
+	}
+	return color.New(color.FgRed).Sprint("FAILED")
+}
+
+// printVerification reports the verification of the last attempt and how
+// many retries it took
+func printVerification(verification *VerificationResult) {
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	last := verification.Rounds[len(verification.Rounds)-1]
+	for _, check := range last.Checks {
+		fmt.Printf("   Verify: %s (%s)\n", checkStatus(check), check.Command)
+		if !check.Passed && check.Output != "" {
+			for _, line := range strings.Split(strings.TrimRight(verify.Tail(check.Output, 2000), "\n"), "\n") {
+				fmt.Printf("      %s\n", line)
+			}
+		}
+	}
+	if retries := verification.Retries(); retries > 0 {
+		fmt.Printf("   Retries: %d (%d tokens across all attempts)\n", retries, verification.TokensUsed())
+	}
+	if verification.Error != "" {
+		fmt.Printf("   %s %s\n", yellow("⚠"), verification.Error)
+	}
+}
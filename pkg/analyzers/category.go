@@ -0,0 +1,191 @@
+package analyzers
+
+import (
+	"strings"
+)
+
+// TaskCategory represents the kind of work a task asks for
+type TaskCategory string
+
+const (
+	BugFix        TaskCategory = "bugfix"
+	Feature       TaskCategory = "feature"
+	Refactor      TaskCategory = "refactor"
+	Tests         TaskCategory = "tests"
+	Documentation TaskCategory = "docs"
+	Review        TaskCategory = "review"
+	Question      TaskCategory = "question"
+	Ops           TaskCategory = "ops"
+)
+
+// DefaultCategory is used when no category keyword matches
+const DefaultCategory = Feature
+
+// defaultCategoryConfidence is reported when the category falls back to the default
+const defaultCategoryConfidence = 0.3
+
+// questionMarkWeight is added to the question score when the task is phrased as a question
+const questionMarkWeight = 1.0
+
+// AllCategories returns every task category
+func AllCategories() []TaskCategory {
+	return []TaskCategory{BugFix, Feature, Refactor, Tests, Documentation, Review, Question, Ops}
+}
+
+// ValidateCategory converts a string to a TaskCategory
+func ValidateCategory(category string) (TaskCategory, bool) {
+	for _, c := range AllCategories() {
+		if string(c) == strings.ToLower(category) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// GetCategoryDescription returns a human-readable description of the category
+func GetCategoryDescription(category TaskCategory) string {
+	switch category {
+	case BugFix:
+		return "Bug fix"
+	case Feature:
+		return "New feature"
+	case Refactor:
+		return "Refactor"
+	case Tests:
+		return "Test writing"
+	case Documentation:
+		return "Documentation"
+	case Review:
+		return "Code review"
+	case Question:
+		return "Question or explanation"
+	case Ops:
+		return "Ops or scripting"
+	default:
+		return "Unknown category"
+	}
+}
+
+// IsReadOnly returns true if the task only asks for information and
+// doesn't need the tool to change files. A question that also asks for a
+// change ("why does the login fail? fix it") isn't.
+func (ca *ComplexityAnalysis) IsReadOnly() bool {
+	return ca.Category == Question && len(ca.Actions) == 0
+}
+
+// enCategoryKeywords are the built-in English category keywords
+var enCategoryKeywords = map[string][]Keyword{
+	string(BugFix): {
+		{"fix", 1}, {"bug", 1}, {"error", 1}, {"crash", 1}, {"broken", 1},
+		{"fail", 1}, {"failing", 1}, {"regression", 1}, {"exception", 1}, {"issue", 0.5},
+	},
+	string(Feature): {
+		{"add", 1}, {"implement", 1}, {"create", 1}, {"new", 0.5}, {"support", 0.5},
+		{"feature", 1}, {"build", 1}, {"introduce", 1},
+	},
+	string(Refactor): {
+		{"refactor", 1.5}, {"clean up", 1}, {"restructure", 1}, {"simplify", 1},
+		{"extract", 1}, {"rename", 1}, {"reorganize", 1}, {"move", 0.5},
+	},
+	string(Tests): {
+		{"test", 1.5}, {"unit test", 1}, {"coverage", 1}, {"spec", 1}, {"benchmark", 1},
+	},
+	string(Documentation): {
+		{"document", 1.5}, {"docs", 1.5}, {"readme", 1}, {"comment", 0.5},
+		{"docstring", 1}, {"changelog", 1}, {"typo", 0.5},
+	},
+	string(Review): {
+		{"review", 1.5}, {"audit", 1}, {"look over", 1}, {"critique", 1}, {"check", 0.5},
+	},
+	string(Question): {
+		{"explain", 1.5}, {"why", 1}, {"how", 1}, {"what", 1}, {"where", 1},
+		{"understand", 1}, {"describe", 0.5},
+	},
+	string(Ops): {
+		{"deploy", 1}, {"script", 1}, {"ci", 1}, {"pipeline", 1}, {"docker", 1},
+		{"release", 1}, {"install", 1}, {"configure", 0.5}, {"cron", 1}, {"kubernetes", 1},
+	},
+}
+
+// enActionKeywords are the built-in English verbs that ask for a change
+var enActionKeywords = []Keyword{
+	{"fix", 1}, {"add", 1}, {"implement", 1}, {"create", 1}, {"write", 1},
+	{"change", 1}, {"update", 1}, {"remove", 1}, {"delete", 1}, {"rename", 1},
+	{"refactor", 1}, {"replace", 1}, {"make", 1}, {"edit", 1}, {"patch", 1},
+	{"move", 1}, {"convert", 1}, {"migrate", 1}, {"install", 1}, {"deploy", 1},
+}
+
+// esActionKeywords are the built-in Spanish verbs that ask for a change
+var esActionKeywords = []Keyword{
+	{"corregir", 1}, {"corrige", 1}, {"arreglar", 1}, {"añadir", 1}, {"agregar", 1},
+	{"implementar", 1}, {"crear", 1}, {"escribir", 1}, {"escribe", 1}, {"cambiar", 1},
+	{"actualizar", 1}, {"eliminar", 1}, {"borrar", 1}, {"renombrar", 1},
+	{"refactorizar", 1}, {"reemplazar", 1}, {"haz", 1}, {"mover", 1}, {"mueve", 1},
+	{"migrar", 1}, {"instalar", 1}, {"desplegar", 1},
+}
+
+// esCategoryKeywords are the built-in Spanish category keywords
+var esCategoryKeywords = map[string][]Keyword{
+	string(BugFix): {
+		{"corregir", 1}, {"arreglar", 1}, {"error", 1}, {"fallo", 1}, {"bug", 1},
+		{"falla", 1}, {"roto", 1}, {"excepción", 1},
+	},
+	string(Feature): {
+		{"añadir", 1}, {"agregar", 1}, {"implementar", 1}, {"crear", 1}, {"nuevo", 0.5},
+		{"soporte", 0.5}, {"funcionalidad", 1},
+	},
+	string(Refactor): {
+		{"refactorizar", 1.5}, {"limpiar", 1}, {"reestructurar", 1}, {"simplificar", 1},
+		{"extraer", 1}, {"renombrar", 1}, {"reorganizar", 1}, {"mover", 0.5},
+	},
+	string(Tests): {
+		{"prueba", 1.5}, {"test", 1.5}, {"cobertura", 1}, {"pruebas unitarias", 1},
+	},
+	string(Documentation): {
+		{"documentar", 1.5}, {"documentación", 1.5}, {"readme", 1}, {"comentario", 0.5},
+		{"changelog", 1}, {"errata", 0.5},
+	},
+	string(Review): {
+		{"revisar", 1.5}, {"revisión", 1.5}, {"auditar", 1}, {"auditoría", 1},
+	},
+	string(Question): {
+		{"explica", 1.5}, {"explicar", 1.5}, {"cuál", 1}, {"dónde", 1},
+		{"entender", 1}, {"describe", 0.5},
+	},
+	string(Ops): {
+		{"desplegar", 1}, {"script", 1}, {"ci", 1}, {"pipeline", 1}, {"docker", 1},
+		{"release", 1}, {"instalar", 1}, {"configurar", 0.5},
+	},
+}
+
+// classify scores every category against the stemmed task and returns the
+// best one with its confidence
+func (cp *compiledPack) classify(task string, stems []string) (TaskCategory, float64) {
+	scores := make(map[TaskCategory]float64, len(cp.categories))
+	for category, keywords := range cp.categories {
+		scores[category], _ = scoreKeywords(keywords, stems)
+	}
+
+	trimmed := strings.TrimSpace(task)
+	if strings.HasSuffix(trimmed, "?") || strings.HasPrefix(trimmed, "¿") {
+		scores[Question] += questionMarkWeight
+	}
+
+	best, bestScore, total := DefaultCategory, 0.0, 0.0
+	// Iterate in a fixed order so ties are deterministic
+	for _, category := range AllCategories() {
+		score := scores[category]
+		total += score
+		if score > bestScore {
+			best, bestScore = category, score
+		}
+	}
+
+	if bestScore == 0 {
+		return DefaultCategory, defaultCategoryConfidence
+	}
+
+	// Share of the total score, damped so a single weak match isn't certain
+	confidence := bestScore / (total + 0.5)
+	return best, confidence
+}
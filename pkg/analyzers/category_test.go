@@ -0,0 +1,77 @@
+package analyzers
+
+import "testing"
+
+func TestCategoryClassification(t *testing.T) {
+	analyzer := &ComplexityAnalyzer{}
+
+	tests := []struct {
+		name          string
+		task          string
+		expected      TaskCategory
+		minConfidence float64
+	}{
+		{"bug fix", "fix the crash in the login handler", BugFix, 0.6},
+		{"feature", "add pagination support to the users endpoint", Feature, 0.5},
+		{"refactor", "refactor the payment service into smaller modules", Refactor, 0.5},
+		{"tests", "write unit tests for the router package", Tests, 0.6},
+		{"documentation", "document the configuration options in the readme", Documentation, 0.6},
+		{"review", "review the changes in the auth package", Review, 0.6},
+		{"question", "how does the decision engine pick a tool?", Question, 0.6},
+		{"ops", "write a docker script to deploy the service", Ops, 0.6},
+		{"spanish question", "¿Cómo funciona el motor de decisiones?", Question, 0.6},
+		{"spanish bug fix", "arregla el error en el manejador de sesiones", BugFix, 0.6},
+		{"spanish explanation", "explica el flujo de autenticación", Question, 0.6},
+		{"no keywords falls back to feature", "pagination for users", DefaultCategory, 0.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := analyzer.heuristicAnalysis(tt.task)
+
+			if result.Category != tt.expected {
+				t.Errorf("category = %v, want %v", result.Category, tt.expected)
+			}
+			if result.CategoryConfidence < tt.minConfidence {
+				t.Errorf("category confidence = %.2f, want >= %.2f", result.CategoryConfidence, tt.minConfidence)
+			}
+			if result.CategoryConfidence > 1 {
+				t.Errorf("category confidence = %.2f, want <= 1", result.CategoryConfidence)
+			}
+		})
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	if !(&ComplexityAnalysis{Category: Question}).IsReadOnly() {
+		t.Error("questions should be read-only")
+	}
+	if (&ComplexityAnalysis{Category: BugFix}).IsReadOnly() {
+		t.Error("bug fixes should not be read-only")
+	}
+}
+
+func TestQuestionsAskingForChanges(t *testing.T) {
+	analyzer := &ComplexityAnalyzer{}
+
+	tests := []struct {
+		name string
+		task string
+		want bool
+	}{
+		{"question", "how does the decision engine pick a tool?", true},
+		{"spanish question", "¿Cómo funciona el motor de decisiones?", true},
+		{"question then fix", "why does X fail? fix it", false},
+		{"question then change", "how does the cache work? make it faster", false},
+		{"spanish question then fix", "¿dónde se valida el token? arregla la validación", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := analyzer.heuristicAnalysis(tt.task)
+			if got := result.IsReadOnly(); got != tt.want {
+				t.Errorf("IsReadOnly() = %v, want %v (category %s, actions %q)", got, tt.want, result.Category, result.Actions)
+			}
+		})
+	}
+}
@@ -1,13 +1,14 @@
 package analyzers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os/exec"
+	"math"
 	"strings"
 	"time"
 
-	"github.com/crlian/ai-dispatcher/pkg/trackers"
+	"github.com/crlian/ai-dispatcher/pkg/calibration"
+	"github.com/crlian/ai-dispatcher/pkg/tokenizer"
 )
 
 // ComplexityLevel represents the complexity classification of a task
@@ -21,161 +22,205 @@ const (
 
 // ComplexityAnalysis contains the result of analyzing a task's complexity
 type ComplexityAnalysis struct {
-	Level      ComplexityLevel `json:"level"`      // Classification: simple, medium, or complex
-	Tokens     int             `json:"tokens"`     // Estimated tokens needed
-	Reasoning  string          `json:"reasoning"`  // Explanation of the classification
-	Confidence float64         `json:"confidence"` // Confidence score (0.0-1.0)
-	Method     string          `json:"method"`     // "llm" or "heuristic"
+	Level              ComplexityLevel `json:"level"`                        // Classification: simple, medium, or complex
+	Tokens             int             `json:"tokens"`                       // Estimated tokens needed
+	Reasoning          string          `json:"reasoning"`                    // Explanation of the classification
+	Confidence         float64         `json:"confidence"`                   // Confidence score (0.0-1.0)
+	Method             string          `json:"method"`                       // "llm" or "heuristic"
+	Category           TaskCategory    `json:"category"`                     // Kind of work: bugfix, feature, question, ...
+	CategoryConfidence float64         `json:"category_confidence"`          // Confidence of the category (0.0-1.0)
+	Actions            []string        `json:"actions,omitempty"`            // Verbs in the task asking for a change
+	Language           string          `json:"language"`                     // Detected language of the task (en, es, ...)
+	RawTokens          int             `json:"raw_tokens,omitempty"`         // Estimate before calibration
+	CalibrationFactor  float64         `json:"calibration_factor,omitempty"` // Correction applied from past runs
+	Prompt             string          `json:"-"`                            // Task text, tokenized per tool by the cost calculator
+	Signals            *RepoSignals    `json:"signals,omitempty"`            // What the working directory revealed about the task
+	Votes              []*Vote         `json:"votes,omitempty"`              // Each analyzer's vote when analyzed by an ensemble
 }
 
-// ComplexityAnalyzer analyzes task complexity using LLM with heuristic fallback
+// ComplexityAnalyzer analyzes task complexity with an ensemble of analyzers
 type ComplexityAnalyzer struct {
-	trackers []trackers.UsageTracker
-	timeout  time.Duration
+	workDir     string             // Repository inspected for signals (disabled when empty)
+	keywords    *keywordMatcher    // Keyword packs (built-in packs when nil)
+	calibration *calibration.Model // Corrections fitted from past runs (none when nil)
+	extra       []Member           // Analyzers added on top of the built-in ones
 }
 
-// NewComplexityAnalyzer creates a new complexity analyzer
-func NewComplexityAnalyzer(trackers []trackers.UsageTracker) *ComplexityAnalyzer {
-	return &ComplexityAnalyzer{
-		trackers: trackers,
-		timeout:  10 * time.Second,
+// keywordThreshold is the keyword score that decides a simple or complex level
+const keywordThreshold = 1.0
+
+// defaultMatcher matches the built-in keyword packs
+var defaultMatcher = newKeywordMatcher(DefaultKeywordPacks())
+
+// NewComplexityAnalyzer creates a new complexity analyzer that inspects the current directory
+func NewComplexityAnalyzer() *ComplexityAnalyzer {
+	return &ComplexityAnalyzer{workDir: "."}
+}
+
+// SetKeywordPacks sets the keyword packs used by the heuristic analysis
+func (ca *ComplexityAnalyzer) SetKeywordPacks(packs map[string]*KeywordPack) {
+	ca.keywords = newKeywordMatcher(packs)
+}
+
+// SetCalibration sets the model used to correct token estimates
+func (ca *ComplexityAnalyzer) SetCalibration(model *calibration.Model) {
+	ca.calibration = model
+}
+
+// SetWorkDir sets the repository inspected for signals (empty disables inspection)
+func (ca *ComplexityAnalyzer) SetWorkDir(dir string) {
+	ca.workDir = dir
+}
+
+// AddAnalyzer adds an analyzer to the ensemble (weight 1 and
+// DefaultAnalyzerTimeout when zero)
+func (ca *ComplexityAnalyzer) AddAnalyzer(analyzer Analyzer, weight float64, timeout time.Duration) {
+	ca.extra = append(ca.extra, Member{Analyzer: analyzer, Weight: weight, Timeout: timeout})
+}
+
+// Ensemble returns the analyzers that vote on a task: the keyword heuristic,
+// the repository when a work directory is set, and any added analyzers. The
+// LLM analyzer isn't one of them until it asks a model.
+func (ca *ComplexityAnalyzer) Ensemble() *Ensemble {
+	ensemble := NewEnsemble()
+	ensemble.Add(&HeuristicAnalyzer{keywords: ca.matcher()}, 1, 0)
+	if ca.workDir != "" {
+		ensemble.Add(NewRepoAnalyzer(ca.workDir), 1, 0)
 	}
+	for _, m := range ca.extra {
+		ensemble.Add(m.Analyzer, m.Weight, m.Timeout)
+	}
+	return ensemble
 }
 
 // AnalyzeComplexity analyzes the complexity of a task
-// It first attempts to use an LLM, then falls back to heuristic analysis
 func (ca *ComplexityAnalyzer) AnalyzeComplexity(task string) (*ComplexityAnalysis, error) {
-	// Try LLM analysis first
-	analysis, err := ca.llmAnalysis(task)
-	if err == nil {
-		return analysis, nil
-	}
+	return ca.AnalyzeComplexityContext(context.Background(), task)
+}
 
-	// Fallback to heuristic analysis
-	return ca.heuristicAnalysis(task), nil
-}
-
-// llmAnalysis uses the cheapest available LLM to analyze task complexity
-func (ca *ComplexityAnalyzer) llmAnalysis(task string) (*ComplexityAnalysis, error) {
-	// Find the tool with the most available capacity
-	var bestTracker trackers.UsageTracker
-	var maxAvailable float64 = 0
-
-	for _, tracker := range ca.trackers {
-		available, err := tracker.GetAvailablePercentage()
-		if err != nil {
-			continue
-		}
-		if available > maxAvailable {
-			maxAvailable = available
-			bestTracker = tracker
-		}
+// AnalyzeComplexityContext analyzes the complexity of a task, stopping the
+// analyzers when ctx is done
+func (ca *ComplexityAnalyzer) AnalyzeComplexityContext(ctx context.Context, task string) (*ComplexityAnalysis, error) {
+	analysis, err := ca.Ensemble().Analyze(ctx, task)
+	if err != nil {
+		return nil, err
 	}
-
-	if bestTracker == nil || maxAvailable < 5.0 {
-		return nil, fmt.Errorf("no LLM available for complexity analysis")
+	analysis.Prompt = task
+	if analysis.Language == "" || analysis.Category == "" {
+		// Analyzers that only classify the level leave the rest to keywords
+		match := ca.matcher().match(task)
+		analysis.Language = match.language
+		analysis.Category = match.category
+		analysis.CategoryConfidence = match.categoryConf
+		analysis.Actions = match.actionTerms
 	}
 
-	// Construct prompt for LLM
-	// In a real implementation, this would use the prompt to call the actual LLM tool
-	// For now, we use a simple mock response
-	_ = task // Acknowledge task parameter
+	ca.applyCalibration(analysis)
 
-	// Execute the tool with a mock response (demonstration only)
-	toolName := bestTracker.GetToolName()
-	cmd := exec.Command("echo", fmt.Sprintf(`{"level": "medium", "tokens": 500, "reasoning": "Using %s for analysis"}`, toolName))
+	return analysis, nil
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("LLM execution failed: %w", err)
+// applyCalibration corrects the token estimate with the factor fitted from
+// past runs of the same level and category, keeping the raw estimate
+func (ca *ComplexityAnalyzer) applyCalibration(analysis *ComplexityAnalysis) {
+	factor := ca.calibration.Lookup("", string(analysis.Level), string(analysis.Category))
+	if factor == nil {
+		return
 	}
 
-	// Parse LLM response
-	var result struct {
-		Level     string `json:"level"`
-		Tokens    int    `json:"tokens"`
-		Reasoning string `json:"reasoning"`
-	}
+	analysis.RawTokens = analysis.Tokens
+	analysis.Tokens = int(math.Round(float64(analysis.Tokens) * factor.Value))
+	analysis.CalibrationFactor = factor.Value
+	analysis.Reasoning = fmt.Sprintf("%s; calibrated x%.2f from %d past runs (%s)",
+		analysis.Reasoning, factor.Value, factor.Samples, factor.Key)
+}
 
-	if err := json.Unmarshal(output, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse LLM response: %w", err)
+// UncalibratedTokens returns the token estimate before calibration
+func (ca *ComplexityAnalysis) UncalibratedTokens() int {
+	if ca.RawTokens > 0 {
+		return ca.RawTokens
 	}
-
-	return &ComplexityAnalysis{
-		Level:      ComplexityLevel(result.Level),
-		Tokens:     result.Tokens,
-		Reasoning:  result.Reasoning,
-		Confidence: 0.9, // High confidence for LLM analysis
-		Method:     "llm",
-	}, nil
+	return ca.Tokens
 }
 
-// heuristicAnalysis performs rule-based complexity analysis
-func (ca *ComplexityAnalyzer) heuristicAnalysis(task string) *ComplexityAnalysis {
-	taskLower := strings.ToLower(task)
-	wordCount := len(strings.Fields(task))
-
-	// Complex keywords
-	complexKeywords := []string{
-		"refactor", "architecture", "migrate", "redesign",
-		"implement", "create new", "build", "design",
-		"multiple", "entire", "all", "system",
+// matcher returns the configured keyword matcher, or the built-in one
+func (ca *ComplexityAnalyzer) matcher() *keywordMatcher {
+	if ca.keywords == nil {
+		return defaultMatcher
 	}
+	return ca.keywords
+}
 
-	// Simple keywords
-	simpleKeywords := []string{
-		"fix typo", "add comment", "rename", "delete",
-		"update text", "change color", "format",
+// levelRank orders complexity levels from simple to complex
+func levelRank(level ComplexityLevel) int {
+	switch level {
+	case Simple:
+		return 0
+	case Medium:
+		return 1
+	case Complex:
+		return 2
+	default:
+		return -1
 	}
+}
 
-	// Check for complex keywords
-	complexCount := 0
-	for _, keyword := range complexKeywords {
-		if strings.Contains(taskLower, keyword) {
-			complexCount++
-		}
-	}
+// heuristicAnalysis performs rule-based complexity analysis with the configured keywords
+func (ca *ComplexityAnalyzer) heuristicAnalysis(task string) *ComplexityAnalysis {
+	return heuristicAnalysis(ca.matcher(), task)
+}
 
-	// Check for simple keywords
-	simpleCount := 0
-	for _, keyword := range simpleKeywords {
-		if strings.Contains(taskLower, keyword) {
-			simpleCount++
-		}
-	}
+// heuristicAnalysis performs rule-based complexity analysis
+func heuristicAnalysis(keywords *keywordMatcher, task string) *ComplexityAnalysis {
+	wordCount := len(strings.Fields(task))
+
+	match := keywords.match(task)
 
 	// Determine complexity level
 	var level ComplexityLevel
-	var tokens int
 	var reasoning string
 
-	if complexCount > 0 || wordCount > 20 {
+	if match.complexScore >= keywordThreshold || wordCount > 20 {
 		level = Complex
-		tokens = 1500
-		reasoning = fmt.Sprintf("Task appears complex (word count: %d, complex keywords: %d)", wordCount, complexCount)
-	} else if simpleCount > 0 || wordCount < 5 {
+		reasoning = fmt.Sprintf("Task appears complex (word count: %d, %s)", wordCount, match)
+	} else if match.simpleScore >= keywordThreshold || wordCount < 5 {
 		level = Simple
-		tokens = 150
-		reasoning = fmt.Sprintf("Task appears simple (word count: %d, simple keywords: %d)", wordCount, simpleCount)
+		reasoning = fmt.Sprintf("Task appears simple (word count: %d, %s)", wordCount, match)
 	} else {
 		level = Medium
-		tokens = 500
-		reasoning = fmt.Sprintf("Task appears medium complexity (word count: %d)", wordCount)
+		reasoning = fmt.Sprintf("Task appears medium complexity (word count: %d, %s)", wordCount, match)
 	}
 
 	return &ComplexityAnalysis{
-		Level:      level,
-		Tokens:     tokens,
-		Reasoning:  reasoning,
-		Confidence: 0.6, // Lower confidence for heuristic
-		Method:     "heuristic",
+		Level:              level,
+		Tokens:             baseTokens[level],
+		Reasoning:          reasoning,
+		Confidence:         0.6, // Lower confidence for heuristic
+		Method:             "heuristic",
+		Language:           match.language,
+		Category:           match.category,
+		CategoryConfidence: match.categoryConf,
+		Actions:            match.actionTerms,
 	}
 }
 
 // EstimateTokens estimates the number of tokens for a given task description
 func EstimateTokens(text string) int {
-	// Rough estimate: ~4 characters per token
-	return len(text) / 4
+	return tokenizer.Default().Count(text)
+}
+
+// ValidateLevel converts a string to a ComplexityLevel
+func ValidateLevel(level string) (ComplexityLevel, bool) {
+	switch ComplexityLevel(strings.ToLower(strings.TrimSpace(level))) {
+	case Simple:
+		return Simple, true
+	case Medium:
+		return Medium, true
+	case Complex:
+		return Complex, true
+	default:
+		return "", false
+	}
 }
 
 // GetComplexityDescription returns a human-readable description of the complexity level
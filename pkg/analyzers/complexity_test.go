@@ -3,6 +3,8 @@ package analyzers
 import (
 	"strings"
 	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/calibration"
 )
 
 func TestHeuristicAnalysis(t *testing.T) {
@@ -101,17 +103,22 @@ func TestEstimateTokens(t *testing.T) {
 		{
 			name:     "short text",
 			text:     "hello",
-			expected: 1, // 5 chars / 4 = 1.25 -> 1
+			expected: 1,
 		},
 		{
 			name:     "medium text",
 			text:     "This is a test string with some words",
-			expected: 9, // 38 chars / 4 = 9.5 -> 9
+			expected: 8, // One token per word
 		},
 		{
 			name:     "long text",
 			text:     strings.Repeat("test ", 100),
-			expected: 125, // 500 chars / 4 = 125
+			expected: 101, // 100 words plus the trailing space
+		},
+		{
+			name:     "spanish text",
+			text:     "¿Cómo está la configuración?",
+			expected: 7, // Accented characters cost extra, so "configuración" is two tokens
 		},
 	}
 
@@ -202,3 +209,37 @@ func TestComplexityAnalysisMethods(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyCalibration(t *testing.T) {
+	var samples []*calibration.Sample
+	for i := 0; i < 4; i++ {
+		samples = append(samples, &calibration.Sample{Tool: "codex", Level: "medium", Category: "bugfix", Estimated: 500, Actual: 2500})
+	}
+
+	analyzer := &ComplexityAnalyzer{}
+	analyzer.SetCalibration(calibration.Fit(samples))
+
+	analysis := &ComplexityAnalysis{Level: Medium, Category: BugFix, Tokens: 500}
+	analyzer.applyCalibration(analysis)
+
+	if analysis.RawTokens != 500 || analysis.UncalibratedTokens() != 500 {
+		t.Errorf("RawTokens = %d, want 500", analysis.RawTokens)
+	}
+	if analysis.Tokens <= 1500 {
+		t.Errorf("Tokens = %d, want the estimate scaled toward 2500", analysis.Tokens)
+	}
+
+	// Other levels fall back to the factor fitted over every run
+	other := &ComplexityAnalysis{Level: Complex, Category: Refactor, Tokens: 1500}
+	analyzer.applyCalibration(other)
+	if other.CalibrationFactor != analysis.CalibrationFactor {
+		t.Errorf("CalibrationFactor = %v, want the overall factor %v", other.CalibrationFactor, analysis.CalibrationFactor)
+	}
+
+	// Without a model the estimate is left alone
+	uncalibrated := &ComplexityAnalysis{Level: Complex, Category: Refactor, Tokens: 1500}
+	(&ComplexityAnalyzer{}).applyCalibration(uncalibrated)
+	if uncalibrated.Tokens != 1500 || uncalibrated.CalibrationFactor != 0 {
+		t.Errorf("uncalibrated analysis changed: %+v", uncalibrated)
+	}
+}
@@ -0,0 +1,230 @@
+package analyzers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAnalyzerTimeout bounds an analyzer added without a timeout
+const DefaultAnalyzerTimeout = 10 * time.Second
+
+// Member is an analyzer taking part in an ensemble
+type Member struct {
+	Analyzer Analyzer
+	Weight   float64       // Multiplies the analyzer's confidence (1 when zero)
+	Timeout  time.Duration // Time the analyzer gets before its vote is dropped (DefaultAnalyzerTimeout when zero)
+}
+
+// Vote is one analyzer's contribution to an ensemble result
+type Vote struct {
+	Analyzer   string          `json:"analyzer"`
+	Level      ComplexityLevel `json:"level,omitempty"`
+	Tokens     int             `json:"tokens,omitempty"`
+	Confidence float64         `json:"confidence"`
+	Weight     float64         `json:"weight"`
+	Duration   time.Duration   `json:"duration"`
+	Error      string          `json:"error,omitempty"` // Why the analyzer didn't vote: failed, timed out or abstained
+
+	analysis *ComplexityAnalysis
+}
+
+// Counted reports whether the vote took part in the result
+func (v *Vote) Counted() bool {
+	return v.analysis != nil
+}
+
+// String formats the vote for reasoning, e.g. "heuristic=complex(60%)"
+func (v *Vote) String() string {
+	if !v.Counted() {
+		return fmt.Sprintf("%s=none(%s)", v.Analyzer, v.Error)
+	}
+	return fmt.Sprintf("%s=%s(%.0f%%)", v.Analyzer, v.Level, v.Confidence*100)
+}
+
+// Ensemble runs several analyzers concurrently and combines their results by
+// confidence-weighted voting
+type Ensemble struct {
+	members []Member
+}
+
+// NewEnsemble creates an ensemble of the given members
+func NewEnsemble(members ...Member) *Ensemble {
+	e := &Ensemble{}
+	for _, m := range members {
+		e.Add(m.Analyzer, m.Weight, m.Timeout)
+	}
+	return e
+}
+
+// Add adds an analyzer with its weight and timeout (defaults when zero)
+func (e *Ensemble) Add(analyzer Analyzer, weight float64, timeout time.Duration) {
+	if weight <= 0 {
+		weight = 1
+	}
+	if timeout <= 0 {
+		timeout = DefaultAnalyzerTimeout
+	}
+	e.members = append(e.members, Member{Analyzer: analyzer, Weight: weight, Timeout: timeout})
+}
+
+// Members returns the analyzers in the ensemble
+func (e *Ensemble) Members() []Member {
+	return e.members
+}
+
+// Name returns "ensemble"
+func (e *Ensemble) Name() string {
+	return "ensemble"
+}
+
+// Analyze runs every member and combines their votes. The level with the
+// highest sum of weight × confidence wins; its tokens are the weighted mean
+// of the votes for that level. It fails only when no analyzer votes.
+func (e *Ensemble) Analyze(ctx context.Context, task string) (*ComplexityAnalysis, error) {
+	votes := make([]*Vote, len(e.members))
+
+	var wg sync.WaitGroup
+	for i, member := range e.members {
+		wg.Add(1)
+		go func(i int, member Member) {
+			defer wg.Done()
+			votes[i] = runMember(ctx, member, task)
+		}(i, member)
+	}
+	wg.Wait()
+
+	return combineVotes(votes)
+}
+
+// runMember runs one analyzer within its timeout. An analyzer that ignores
+// its context is abandoned when the timeout expires.
+func runMember(ctx context.Context, member Member, task string) *Vote {
+	vote := &Vote{Analyzer: member.Analyzer.Name(), Weight: member.Weight}
+
+	ctx, cancel := context.WithTimeout(ctx, member.Timeout)
+	defer cancel()
+
+	type outcome struct {
+		analysis *ComplexityAnalysis
+		err      error
+	}
+	done := make(chan outcome, 1)
+
+	start := time.Now()
+	go func() {
+		analysis, err := member.Analyzer.Analyze(ctx, task)
+		done <- outcome{analysis, err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result.err = ctx.Err()
+	}
+	vote.Duration = time.Since(start)
+
+	switch {
+	case errors.Is(result.err, ErrAbstain):
+		vote.Error = "abstained"
+	case errors.Is(result.err, context.DeadlineExceeded):
+		vote.Error = fmt.Sprintf("timed out after %s", member.Timeout)
+	case result.err != nil:
+		vote.Error = result.err.Error()
+	case result.analysis == nil:
+		vote.Error = "no result"
+	case levelRank(result.analysis.Level) < 0:
+		vote.Error = fmt.Sprintf("unknown level %q", result.analysis.Level)
+	default:
+		vote.analysis = result.analysis
+		vote.Level = result.analysis.Level
+		vote.Tokens = result.analysis.Tokens
+		vote.Confidence = math.Max(0, math.Min(1, result.analysis.Confidence))
+	}
+
+	return vote
+}
+
+// combineVotes merges the counted votes into a single analysis
+func combineVotes(votes []*Vote) (*ComplexityAnalysis, error) {
+	scores := make(map[ComplexityLevel]float64)
+	total := 0.0
+	for _, v := range votes {
+		if v.Counted() {
+			scores[v.Level] += v.Weight * v.Confidence
+			total += v.Weight * v.Confidence
+		}
+	}
+
+	var winner ComplexityLevel
+	found := false
+	// Iterate from complex to simple so ties favour the larger estimate
+	for _, level := range []ComplexityLevel{Complex, Medium, Simple} {
+		for _, v := range votes {
+			if v.Counted() && v.Level == level && (!found || scores[level] > scores[winner]) {
+				winner, found = level, true
+			}
+		}
+	}
+
+	if !found {
+		reasons := make([]string, 0, len(votes))
+		for _, v := range votes {
+			reasons = append(reasons, v.String())
+		}
+		return nil, fmt.Errorf("no analyzer produced a result: %s", strings.Join(reasons, ", "))
+	}
+
+	analysis := &ComplexityAnalysis{Level: winner, Method: "ensemble"}
+
+	tokenSum, tokenWeight := 0.0, 0.0
+	var best *Vote
+	var summary []string
+	for _, v := range votes {
+		summary = append(summary, v.String())
+		if !v.Counted() {
+			continue
+		}
+
+		// Language, category and repo signals come from whichever analyzer found them
+		if analysis.Language == "" && v.analysis.Language != "" {
+			analysis.Language = v.analysis.Language
+		}
+		if analysis.Category == "" && v.analysis.Category != "" {
+			analysis.Category = v.analysis.Category
+			analysis.CategoryConfidence = v.analysis.CategoryConfidence
+			analysis.Actions = v.analysis.Actions
+		}
+		if analysis.Signals == nil && v.analysis.Signals != nil {
+			analysis.Signals = v.analysis.Signals
+		}
+
+		if v.Level != winner {
+			continue
+		}
+		w := v.Weight * v.Confidence
+		tokenSum += w * float64(v.Tokens)
+		tokenWeight += w
+		if best == nil || w > best.Weight*best.Confidence {
+			best = v
+		}
+	}
+
+	if tokenWeight > 0 {
+		analysis.Tokens = int(math.Round(tokenSum / tokenWeight))
+		analysis.Confidence = scores[winner] / total
+	} else {
+		// Every vote for the winner had zero confidence
+		analysis.Tokens = best.Tokens
+	}
+
+	analysis.Reasoning = fmt.Sprintf("%s; votes: %s", best.analysis.Reasoning, strings.Join(summary, ", "))
+	analysis.Votes = votes
+
+	return analysis, nil
+}
@@ -0,0 +1,184 @@
+package analyzers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedAnalyzer returns a fixed result, error or delay
+type fixedAnalyzer struct {
+	name     string
+	analysis *ComplexityAnalysis
+	err      error
+	delay    time.Duration
+}
+
+func (fa *fixedAnalyzer) Name() string {
+	return fa.name
+}
+
+func (fa *fixedAnalyzer) Analyze(ctx context.Context, task string) (*ComplexityAnalysis, error) {
+	if fa.delay > 0 {
+		select {
+		case <-time.After(fa.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return fa.analysis, fa.err
+}
+
+// vote returns an analyzer that votes for a level
+func vote(name string, level ComplexityLevel, tokens int, confidence float64) *fixedAnalyzer {
+	return &fixedAnalyzer{name: name, analysis: &ComplexityAnalysis{
+		Level: level, Tokens: tokens, Confidence: confidence, Reasoning: name + " reasoning",
+	}}
+}
+
+func TestEnsembleVoting(t *testing.T) {
+	tests := []struct {
+		name           string
+		members        []Member
+		wantLevel      ComplexityLevel
+		wantTokens     int
+		wantConfidence float64
+	}{
+		{
+			name:           "single analyzer",
+			members:        []Member{{Analyzer: vote("a", Simple, 150, 0.6)}},
+			wantLevel:      Simple,
+			wantTokens:     150,
+			wantConfidence: 1,
+		},
+		{
+			name: "confidence outweighs numbers",
+			members: []Member{
+				{Analyzer: vote("a", Simple, 150, 0.3)},
+				{Analyzer: vote("b", Simple, 150, 0.3)},
+				{Analyzer: vote("c", Complex, 2000, 0.9)},
+			},
+			wantLevel:      Complex,
+			wantTokens:     2000,
+			wantConfidence: 0.6,
+		},
+		{
+			name: "weight changes the winner",
+			members: []Member{
+				{Analyzer: vote("a", Medium, 500, 0.6), Weight: 2},
+				{Analyzer: vote("b", Complex, 1500, 0.9)},
+			},
+			wantLevel:      Medium,
+			wantTokens:     500,
+			wantConfidence: 1.2 / 2.1,
+		},
+		{
+			name: "tokens are the weighted mean of the winning votes",
+			members: []Member{
+				{Analyzer: vote("a", Medium, 400, 0.6)},
+				{Analyzer: vote("b", Medium, 1000, 0.3)},
+				{Analyzer: vote("c", Simple, 100, 0.5)},
+			},
+			wantLevel:      Medium,
+			wantTokens:     600,
+			wantConfidence: 0.9 / 1.4,
+		},
+		{
+			name: "failed and abstaining analyzers don't vote",
+			members: []Member{
+				{Analyzer: vote("a", Simple, 150, 0.2)},
+				{Analyzer: &fixedAnalyzer{name: "b", err: errors.New("boom")}},
+				{Analyzer: &fixedAnalyzer{name: "c", err: ErrAbstain}},
+			},
+			wantLevel:      Simple,
+			wantTokens:     150,
+			wantConfidence: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis, err := NewEnsemble(tt.members...).Analyze(context.Background(), "task")
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if analysis.Level != tt.wantLevel {
+				t.Errorf("Level = %v, want %v", analysis.Level, tt.wantLevel)
+			}
+			if analysis.Tokens != tt.wantTokens {
+				t.Errorf("Tokens = %d, want %d", analysis.Tokens, tt.wantTokens)
+			}
+			if diff := analysis.Confidence - tt.wantConfidence; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Confidence = %v, want %v", analysis.Confidence, tt.wantConfidence)
+			}
+			if len(analysis.Votes) != len(tt.members) {
+				t.Errorf("len(Votes) = %d, want %d", len(analysis.Votes), len(tt.members))
+			}
+			if analysis.Method != "ensemble" {
+				t.Errorf("Method = %q, want ensemble", analysis.Method)
+			}
+		})
+	}
+}
+
+func TestEnsembleTimeout(t *testing.T) {
+	ensemble := NewEnsemble(
+		Member{Analyzer: vote("fast", Simple, 150, 0.6)},
+		Member{Analyzer: &fixedAnalyzer{name: "slow", analysis: &ComplexityAnalysis{Level: Complex, Confidence: 0.9}, delay: time.Second}, Timeout: 10 * time.Millisecond},
+	)
+
+	start := time.Now()
+	analysis, err := ensemble.Analyze(context.Background(), "task")
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Analyze() took %v, want the slow analyzer cut off", elapsed)
+	}
+	if analysis.Level != Simple {
+		t.Errorf("Level = %v, want %v", analysis.Level, Simple)
+	}
+
+	slow := analysis.Votes[1]
+	if slow.Counted() || !strings.Contains(slow.Error, "timed out") {
+		t.Errorf("slow vote = %+v, want a timeout", slow)
+	}
+}
+
+func TestEnsembleNoVotes(t *testing.T) {
+	ensemble := NewEnsemble(Member{Analyzer: &fixedAnalyzer{name: "a", err: ErrAbstain}})
+	if _, err := ensemble.Analyze(context.Background(), "task"); err == nil {
+		t.Error("Analyze() error = nil, want an error when no analyzer votes")
+	}
+}
+
+func TestComplexityAnalyzerEnsemble(t *testing.T) {
+	var names []string
+	for _, m := range NewComplexityAnalyzer().Ensemble().Members() {
+		names = append(names, m.Analyzer.Name())
+	}
+	// The LLM analyzer doesn't ask a model yet, so it mustn't outvote the rest
+	if got := strings.Join(names, ","); got != "heuristic,repo" {
+		t.Errorf("Ensemble() members = %s, want heuristic,repo", got)
+	}
+}
+
+func TestComplexityAnalyzerAddAnalyzer(t *testing.T) {
+	analyzer := NewComplexityAnalyzer()
+	analyzer.SetWorkDir("")
+	analyzer.AddAnalyzer(vote("in-house", Complex, 3000, 0.9), 2, 0)
+
+	analysis, err := analyzer.AnalyzeComplexity("fix the login crash")
+	if err != nil {
+		t.Fatalf("AnalyzeComplexity() error = %v", err)
+	}
+	if analysis.Level != Complex || analysis.Tokens != 3000 {
+		t.Errorf("analysis = %s/%d, want the in-house analyzer to win", analysis.Level, analysis.Tokens)
+	}
+	// The heuristic still fills in the category
+	if analysis.Category != BugFix {
+		t.Errorf("Category = %v, want %v", analysis.Category, BugFix)
+	}
+}
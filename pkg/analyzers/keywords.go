@@ -0,0 +1,355 @@
+package analyzers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLanguage is reported when a task's language can't be detected
+const DefaultLanguage = "en"
+
+// minStemLength keeps suffix stripping from reducing words to nothing
+const minStemLength = 3
+
+// Keyword is a term that hints at a complexity level, with how strongly it does.
+// In YAML it is either a plain string (weight 1) or {term, weight}.
+type Keyword struct {
+	Term   string  `yaml:"term"`
+	Weight float64 `yaml:"weight"`
+}
+
+// UnmarshalYAML accepts both "refactor" and {term: refactor, weight: 0.5}
+func (k *Keyword) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		k.Term = node.Value
+		k.Weight = 1
+		return nil
+	}
+
+	type plain Keyword
+	var p plain
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	if p.Weight == 0 {
+		p.Weight = 1
+	}
+	*k = Keyword(p)
+	return nil
+}
+
+// KeywordPack holds the complexity keywords for one language
+type KeywordPack struct {
+	Complex    []Keyword            `yaml:"complex"`
+	Simple     []Keyword            `yaml:"simple"`
+	Categories map[string][]Keyword `yaml:"categories"` // Keywords per task category (bugfix, docs, ...)
+	Actions    []Keyword            `yaml:"actions"`    // Verbs asking for a change, which keep a question from running read-only
+	Stopwords  []string             `yaml:"stopwords"`  // Common words used to detect the language
+	Suffixes   []string             `yaml:"suffixes"`   // Suffixes stripped when stemming
+	Replace    bool                 `yaml:"replace"`    // Replace the built-in pack instead of extending it
+}
+
+// DefaultKeywordPacks returns the built-in English and Spanish packs
+func DefaultKeywordPacks() map[string]*KeywordPack {
+	return map[string]*KeywordPack{
+		"en": {
+			Complex: []Keyword{
+				{"refactor", 1}, {"architecture", 1}, {"migrate", 1}, {"redesign", 1},
+				{"implement", 1}, {"create new", 1}, {"build", 1}, {"design", 1},
+				{"multiple", 0.5}, {"entire", 0.5}, {"all", 0.5}, {"system", 0.5},
+			},
+			Simple: []Keyword{
+				{"fix typo", 1}, {"add comment", 1}, {"rename", 1}, {"delete", 1},
+				{"update text", 1}, {"change color", 1}, {"format", 1},
+			},
+			Stopwords: []string{
+				"the", "of", "to", "in", "for", "and", "is", "with", "on", "that",
+				"this", "it", "from", "by", "be", "an", "are", "should",
+			},
+			Suffixes: []string{
+				"ations", "ation", "ating", "ated", "ates", "ings", "ing",
+				"ate", "ed", "es", "s", "e",
+			},
+			Categories: enCategoryKeywords,
+			Actions:    enActionKeywords,
+		},
+		"es": {
+			Complex: []Keyword{
+				{"refactorizar", 1}, {"arquitectura", 1}, {"migrar", 1}, {"rediseñar", 1},
+				{"implementar", 1}, {"crear nuevo", 1}, {"construir", 1}, {"diseñar", 1},
+				{"múltiples", 0.5}, {"completo", 0.5}, {"entero", 0.5}, {"sistema", 0.5},
+			},
+			Simple: []Keyword{
+				{"corregir errata", 1}, {"corregir typo", 1}, {"añadir comentario", 1},
+				{"agregar comentario", 1}, {"renombrar", 1}, {"eliminar", 1}, {"borrar", 1},
+				{"actualizar texto", 1}, {"cambiar color", 1}, {"formatear", 1},
+			},
+			Stopwords: []string{
+				"el", "la", "los", "las", "de", "del", "que", "en", "un", "una",
+				"para", "con", "por", "es", "y", "se", "al", "lo", "como", "este", "esta",
+			},
+			Suffixes: []string{
+				"aciones", "amiento", "imiento", "acion", "iendo", "ando",
+				"ado", "ido", "ada", "ida", "ar", "er", "ir", "as", "os", "es", "a", "o", "e", "s",
+			},
+			Categories: esCategoryKeywords,
+			Actions:    esActionKeywords,
+		},
+	}
+}
+
+// MergeKeywordPacks overlays configured packs on the built-in ones. A
+// configured pack extends the built-in pack of the same language unless it
+// sets replace; packs for new languages are added as they are.
+func MergeKeywordPacks(base, extra map[string]*KeywordPack) map[string]*KeywordPack {
+	merged := make(map[string]*KeywordPack, len(base)+len(extra))
+	for lang, pack := range base {
+		merged[lang] = pack
+	}
+
+	for lang, pack := range extra {
+		lang = strings.ToLower(lang)
+		existing, ok := merged[lang]
+		if !ok || pack.Replace {
+			merged[lang] = pack
+			continue
+		}
+
+		categories := make(map[string][]Keyword, len(existing.Categories))
+		for category, keywords := range existing.Categories {
+			categories[category] = append([]Keyword{}, keywords...)
+		}
+		for category, keywords := range pack.Categories {
+			categories[category] = append(categories[category], keywords...)
+		}
+
+		merged[lang] = &KeywordPack{
+			Complex:    append(append([]Keyword{}, existing.Complex...), pack.Complex...),
+			Simple:     append(append([]Keyword{}, existing.Simple...), pack.Simple...),
+			Categories: categories,
+			Actions:    append(append([]Keyword{}, existing.Actions...), pack.Actions...),
+			Stopwords:  append(append([]string{}, existing.Stopwords...), pack.Stopwords...),
+			Suffixes:   append(append([]string{}, existing.Suffixes...), pack.Suffixes...),
+		}
+	}
+
+	return merged
+}
+
+// keywordMatcher matches stemmed keywords against a task
+type keywordMatcher struct {
+	languages []string // Sorted, so language detection ties are deterministic
+	packs     map[string]*compiledPack
+}
+
+// compiledPack is a keyword pack with its terms folded and stemmed
+type compiledPack struct {
+	complex    []compiledKeyword
+	simple     []compiledKeyword
+	categories map[TaskCategory][]compiledKeyword
+	actions    []compiledKeyword
+	stopwords  map[string]bool
+	suffixes   []string
+}
+
+// compiledKeyword is a keyword split into stemmed words
+type compiledKeyword struct {
+	term   string
+	stems  []string
+	weight float64
+}
+
+// keywordMatch is the result of matching a task against its language's pack
+type keywordMatch struct {
+	language     string
+	complexScore float64
+	simpleScore  float64
+	complexTerms []string
+	simpleTerms  []string
+	category     TaskCategory
+	categoryConf float64
+	actionTerms  []string // Verbs asking for a change
+	stems        []string // The task's words without stopwords, stemmed
+}
+
+// newKeywordMatcher compiles keyword packs for matching
+func newKeywordMatcher(packs map[string]*KeywordPack) *keywordMatcher {
+	km := &keywordMatcher{packs: make(map[string]*compiledPack, len(packs))}
+
+	for lang, pack := range packs {
+		cp := &compiledPack{stopwords: make(map[string]bool)}
+		for _, word := range pack.Stopwords {
+			cp.stopwords[foldAccents(strings.ToLower(word))] = true
+		}
+		for _, suffix := range pack.Suffixes {
+			cp.suffixes = append(cp.suffixes, foldAccents(strings.ToLower(suffix)))
+		}
+		// Strip the longest matching suffix first
+		sort.SliceStable(cp.suffixes, func(i, j int) bool {
+			return len(cp.suffixes[i]) > len(cp.suffixes[j])
+		})
+
+		cp.complex = cp.compile(pack.Complex)
+		cp.simple = cp.compile(pack.Simple)
+		cp.actions = cp.compile(pack.Actions)
+		cp.categories = make(map[TaskCategory][]compiledKeyword, len(pack.Categories))
+		for name, keywords := range pack.Categories {
+			// Unknown category names are ignored
+			if category, ok := ValidateCategory(name); ok {
+				cp.categories[category] = append(cp.categories[category], cp.compile(keywords)...)
+			}
+		}
+
+		km.packs[lang] = cp
+		km.languages = append(km.languages, lang)
+	}
+	sort.Strings(km.languages)
+
+	return km
+}
+
+// compile folds and stems keyword terms
+func (cp *compiledPack) compile(keywords []Keyword) []compiledKeyword {
+	compiled := make([]compiledKeyword, 0, len(keywords))
+	for _, k := range keywords {
+		stems := cp.stems(splitWords(k.Term))
+		if len(stems) == 0 {
+			continue
+		}
+		compiled = append(compiled, compiledKeyword{term: k.Term, stems: stems, weight: k.Weight})
+	}
+	return compiled
+}
+
+// stems drops stopwords and stems the remaining words
+func (cp *compiledPack) stems(words []string) []string {
+	stems := make([]string, 0, len(words))
+	for _, word := range words {
+		if cp.stopwords[word] {
+			continue
+		}
+		stems = append(stems, cp.stem(word))
+	}
+	return stems
+}
+
+// stem strips the first (longest) matching suffix
+func (cp *compiledPack) stem(word string) string {
+	for _, suffix := range cp.suffixes {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= minStemLength {
+			return word[:len(word)-len(suffix)]
+		}
+	}
+	return word
+}
+
+// detectLanguage picks the language whose stopwords appear most often
+func (km *keywordMatcher) detectLanguage(words []string) string {
+	best, bestHits := "", 0
+	for _, lang := range km.languages {
+		hits := 0
+		for _, word := range words {
+			if km.packs[lang].stopwords[word] {
+				hits++
+			}
+		}
+		if hits > bestHits {
+			best, bestHits = lang, hits
+		}
+	}
+
+	if best == "" {
+		if _, ok := km.packs[DefaultLanguage]; ok || len(km.languages) == 0 {
+			return DefaultLanguage
+		}
+		return km.languages[0]
+	}
+	return best
+}
+
+// match detects the task's language and scores it against that language's keywords
+func (km *keywordMatcher) match(task string) *keywordMatch {
+	words := splitWords(task)
+	result := &keywordMatch{language: km.detectLanguage(words)}
+
+	pack, ok := km.packs[result.language]
+	if !ok {
+		result.category, result.categoryConf = DefaultCategory, defaultCategoryConfidence
+		result.stems = words
+		return result
+	}
+
+	stems := pack.stems(words)
+	result.stems = stems
+	result.complexScore, result.complexTerms = scoreKeywords(pack.complex, stems)
+	result.simpleScore, result.simpleTerms = scoreKeywords(pack.simple, stems)
+	result.category, result.categoryConf = pack.classify(task, stems)
+	_, result.actionTerms = scoreKeywords(pack.actions, stems)
+	return result
+}
+
+// scoreKeywords sums the weights of the keywords found in the stemmed task
+func scoreKeywords(keywords []compiledKeyword, stems []string) (float64, []string) {
+	score := 0.0
+	var terms []string
+	for _, k := range keywords {
+		if containsSequence(stems, k.stems) {
+			score += k.weight
+			terms = append(terms, k.term)
+		}
+	}
+	return score, terms
+}
+
+// containsSequence reports whether seq appears consecutively in words
+func containsSequence(words, seq []string) bool {
+	for i := 0; i+len(seq) <= len(words); i++ {
+		matched := true
+		for j := range seq {
+			if words[i+j] != seq[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// splitWords lowercases text, folds accents and splits it into words
+func splitWords(text string) []string {
+	return strings.FieldsFunc(foldAccents(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// accentFolds maps accented Latin letters to their base letter
+var accentFolds = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	"à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u", "ç", "c",
+)
+
+// foldAccents removes accents so "migración" and "migracion" match
+func foldAccents(text string) string {
+	return accentFolds.Replace(text)
+}
+
+// String describes the match for the analysis reasoning
+func (m *keywordMatch) String() string {
+	return fmt.Sprintf("language: %s, complex score: %.1f%s, simple score: %.1f%s",
+		m.language, m.complexScore, formatTerms(m.complexTerms), m.simpleScore, formatTerms(m.simpleTerms))
+}
+
+// formatTerms lists matched terms in brackets
+func formatTerms(terms []string) string {
+	if len(terms) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(terms, ", ") + "]"
+}
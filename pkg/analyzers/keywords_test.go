@@ -0,0 +1,126 @@
+package analyzers
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestHeuristicAnalysisMultilingual(t *testing.T) {
+	analyzer := &ComplexityAnalyzer{}
+
+	tests := []struct {
+		name             string
+		task             string
+		expectedLevel    ComplexityLevel
+		expectedLanguage string
+	}{
+		{
+			name:             "spanish refactor with inflected verb",
+			task:             "refactoriza el módulo de autenticación para usar JWT",
+			expectedLevel:    Complex,
+			expectedLanguage: "es",
+		},
+		{
+			name:             "spanish migration noun matches migrar",
+			task:             "prepara la migración de la base de datos",
+			expectedLevel:    Complex,
+			expectedLanguage: "es",
+		},
+		{
+			name:             "spanish typo fix without accents",
+			task:             "corregir la errata en el archivo de configuracion principal",
+			expectedLevel:    Simple,
+			expectedLanguage: "es",
+		},
+		{
+			name:             "english stemmed keyword",
+			task:             "start refactoring the payment flow for the checkout",
+			expectedLevel:    Complex,
+			expectedLanguage: "en",
+		},
+		{
+			name:             "english stopwords between keyword words",
+			task:             "please fix the typo in the login page",
+			expectedLevel:    Simple,
+			expectedLanguage: "en",
+		},
+		{
+			name:             "low weight keyword alone is not complex",
+			task:             "update all imports in the handler package",
+			expectedLevel:    Medium,
+			expectedLanguage: "en",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := analyzer.heuristicAnalysis(tt.task)
+
+			if result.Level != tt.expectedLevel {
+				t.Errorf("heuristicAnalysis() level = %v, want %v (%s)", result.Level, tt.expectedLevel, result.Reasoning)
+			}
+			if result.Language != tt.expectedLanguage {
+				t.Errorf("heuristicAnalysis() language = %v, want %v", result.Language, tt.expectedLanguage)
+			}
+		})
+	}
+}
+
+func TestKeywordPacksFromConfig(t *testing.T) {
+	data := []byte(`
+es:
+  complex:
+    - desplegar
+    - term: optimizar
+      weight: 0.5
+fr:
+  stopwords: [le, la, les, des, pour]
+  suffixes: [er, e, s]
+  complex: [refactoriser]
+`)
+
+	var extra map[string]*KeywordPack
+	if err := yaml.Unmarshal(data, &extra); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+
+	if got := extra["es"].Complex[1].Weight; got != 0.5 {
+		t.Errorf("weighted keyword = %v, want 0.5", got)
+	}
+	if got := extra["es"].Complex[0].Weight; got != 1 {
+		t.Errorf("plain keyword weight = %v, want 1", got)
+	}
+
+	packs := MergeKeywordPacks(DefaultKeywordPacks(), extra)
+	analyzer := &ComplexityAnalyzer{}
+	analyzer.SetKeywordPacks(packs)
+
+	// Extended pack keeps the built-in keywords
+	if result := analyzer.heuristicAnalysis("hay que desplegar el servicio en el servidor nuevo"); result.Level != Complex {
+		t.Errorf("configured spanish keyword: level = %v, want complex", result.Level)
+	}
+	if result := analyzer.heuristicAnalysis("refactoriza el módulo de pagos ahora mismo"); result.Level != Complex {
+		t.Errorf("built-in spanish keyword: level = %v, want complex", result.Level)
+	}
+
+	// New language is detected and matched
+	result := analyzer.heuristicAnalysis("refactoriser le module des paiements pour la production")
+	if result.Language != "fr" || result.Level != Complex {
+		t.Errorf("french pack: language = %v, level = %v, want fr/complex", result.Language, result.Level)
+	}
+}
+
+func TestMergeKeywordPacksReplace(t *testing.T) {
+	extra := map[string]*KeywordPack{
+		"en": {Complex: []Keyword{{Term: "overhaul", Weight: 1}}, Replace: true},
+	}
+
+	packs := MergeKeywordPacks(DefaultKeywordPacks(), extra)
+	if len(packs["en"].Complex) != 1 {
+		t.Errorf("replaced pack has %d complex keywords, want 1", len(packs["en"].Complex))
+	}
+	if len(packs["es"].Complex) == 0 {
+		t.Error("other packs should be kept")
+	}
+}
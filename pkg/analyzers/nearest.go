@@ -0,0 +1,166 @@
+package analyzers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/crlian/ai-dispatcher/pkg/history"
+)
+
+// Limits for the history nearest-neighbor analyzer
+const (
+	neighborCount         = 5   // Past runs consulted per task
+	minSimilarity         = 0.3 // Runs less similar than this are ignored
+	maxNeighborConfidence = 0.9 // Past runs never make the vote certain
+)
+
+// queryMode is the mode of read-only runs in the history, whose usage covers
+// only the answer
+const queryMode = "query"
+
+// HistoryAnalyzer estimates a task from the most similar successful past runs
+type HistoryAnalyzer struct {
+	keywords *keywordMatcher
+	runs     []pastRun
+}
+
+// pastRun is a history record with its task reduced to stems
+type pastRun struct {
+	record *history.Record
+	stems  map[string]bool
+}
+
+// neighbor is a past run and its similarity to the task
+type neighbor struct {
+	run        pastRun
+	similarity float64
+}
+
+// NewHistoryAnalyzer creates an analyzer over the recorded runs (built-in
+// keyword packs when packs is nil). Only successful first attempts that
+// executed and have the token usage the tool reported are consulted, so their
+// tokens count the same as the estimates they're voted with.
+func NewHistoryAnalyzer(records []*history.Record, packs map[string]*KeywordPack) *HistoryAnalyzer {
+	ha := &HistoryAnalyzer{keywords: defaultMatcher}
+	if packs != nil {
+		ha.keywords = newKeywordMatcher(packs)
+	}
+
+	for _, record := range records {
+		if !record.Success || record.Retries > 0 || record.Mode == queryMode || record.UsageTokens <= 0 || levelRank(ComplexityLevel(record.Level)) < 0 {
+			continue
+		}
+		stems := ha.stemSet(record.Task)
+		if len(stems) == 0 {
+			continue
+		}
+		ha.runs = append(ha.runs, pastRun{record: record, stems: stems})
+	}
+
+	return ha
+}
+
+// Name returns "history"
+func (ha *HistoryAnalyzer) Name() string {
+	return "history"
+}
+
+// Analyze votes for the level most of the similar past runs had, with the
+// tokens the tools reported using. It abstains when no past run is similar
+// enough.
+func (ha *HistoryAnalyzer) Analyze(ctx context.Context, task string) (*ComplexityAnalysis, error) {
+	neighbors := ha.nearest(ha.stemSet(task))
+	if len(neighbors) == 0 {
+		return nil, ErrAbstain
+	}
+
+	scores := make(map[ComplexityLevel]float64)
+	for _, n := range neighbors {
+		scores[ComplexityLevel(n.run.record.Level)] += n.similarity
+	}
+
+	// The closest run breaks ties
+	level := ComplexityLevel(neighbors[0].run.record.Level)
+	for _, l := range []ComplexityLevel{Complex, Medium, Simple} {
+		if scores[l] > scores[level] {
+			level = l
+		}
+	}
+
+	tokenSum, weight, total := 0.0, 0.0, 0.0
+	agreeing := 0
+	for _, n := range neighbors {
+		total += n.similarity
+		if ComplexityLevel(n.run.record.Level) != level {
+			continue
+		}
+		tokenSum += n.similarity * float64(n.run.record.UsageTokens)
+		weight += n.similarity
+		agreeing++
+	}
+
+	// Mean similarity of the agreeing runs, scaled by how much they agree
+	confidence := math.Min(maxNeighborConfidence, (weight/float64(agreeing))*(weight/total))
+
+	return &ComplexityAnalysis{
+		Level:      level,
+		Tokens:     int(math.Round(tokenSum / weight)),
+		Reasoning:  fmt.Sprintf("%d of %d similar past runs were %s (closest: %q)", agreeing, len(neighbors), level, neighbors[0].run.record.Task),
+		Confidence: confidence,
+		Method:     "history",
+	}, nil
+}
+
+// nearest returns the most similar past runs, closest first
+func (ha *HistoryAnalyzer) nearest(stems map[string]bool) []neighbor {
+	if len(stems) == 0 {
+		return nil
+	}
+
+	var neighbors []neighbor
+	for _, run := range ha.runs {
+		if s := jaccard(stems, run.stems); s >= minSimilarity {
+			neighbors = append(neighbors, neighbor{run: run, similarity: s})
+		}
+	}
+
+	// Most similar first; among equals, the most recent run
+	sort.SliceStable(neighbors, func(i, j int) bool {
+		if neighbors[i].similarity != neighbors[j].similarity {
+			return neighbors[i].similarity > neighbors[j].similarity
+		}
+		return neighbors[i].run.record.Time.After(neighbors[j].run.record.Time)
+	})
+
+	if len(neighbors) > neighborCount {
+		neighbors = neighbors[:neighborCount]
+	}
+	return neighbors
+}
+
+// stemSet returns the distinct stems of a task
+func (ha *HistoryAnalyzer) stemSet(task string) map[string]bool {
+	stems := ha.keywords.match(task).stems
+	set := make(map[string]bool, len(stems))
+	for _, s := range stems {
+		set[s] = true
+	}
+	return set
+}
+
+// jaccard returns the share of stems two tasks have in common
+func jaccard(a, b map[string]bool) float64 {
+	shared := 0
+	for s := range a {
+		if b[s] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
@@ -0,0 +1,66 @@
+package analyzers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/history"
+)
+
+func TestHistoryAnalyzer(t *testing.T) {
+	records := []*history.Record{
+		{Task: "add pagination to the users endpoint", Level: "medium", UsageTokens: 4000, Success: true},
+		{Task: "add pagination to the orders endpoint", Level: "medium", UsageTokens: 6000, Success: true},
+		{Task: "add pagination to the invoices endpoint", Level: "complex", UsageTokens: 9000, Success: false},            // Failed runs are ignored
+		{Task: "add pagination to the carts endpoint", Level: "complex", UsageTokens: 20000, Success: true, Retries: 1},   // So are retried runs
+		{Task: "add pagination to the refunds endpoint", Level: "simple", UsageTokens: 100, Success: true, Mode: "query"}, // And answers
+		{Task: "add pagination to the coupons endpoint", Level: "complex", ActualTokens: 800, Success: true},              // And runs without usage
+		{Task: "fix typo in readme", Level: "simple", UsageTokens: 300, Success: true},
+	}
+	analyzer := NewHistoryAnalyzer(records, nil)
+
+	analysis, err := analyzer.Analyze(context.Background(), "add pagination to the products endpoint")
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if analysis.Level != Medium {
+		t.Errorf("Level = %v, want %v", analysis.Level, Medium)
+	}
+	if analysis.Tokens != 5000 {
+		t.Errorf("Tokens = %d, want 5000", analysis.Tokens)
+	}
+	if analysis.Confidence <= 0 || analysis.Confidence > maxNeighborConfidence {
+		t.Errorf("Confidence = %v, want in (0, %v]", analysis.Confidence, maxNeighborConfidence)
+	}
+
+	if _, err := analyzer.Analyze(context.Background(), "rewrite the billing service in rust"); !errors.Is(err, ErrAbstain) {
+		t.Errorf("Analyze() for an unrelated task error = %v, want ErrAbstain", err)
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	set := func(words ...string) map[string]bool {
+		m := make(map[string]bool)
+		for _, w := range words {
+			m[w] = true
+		}
+		return m
+	}
+
+	tests := []struct {
+		a, b map[string]bool
+		want float64
+	}{
+		{set("a", "b"), set("a", "b"), 1},
+		{set("a", "b"), set("b", "c"), 1.0 / 3},
+		{set("a"), set("b"), 0},
+		{set(), set(), 0},
+	}
+
+	for _, tt := range tests {
+		if got := jaccard(tt.a, tt.b); got != tt.want {
+			t.Errorf("jaccard(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
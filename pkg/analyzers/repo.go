@@ -0,0 +1,455 @@
+package analyzers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/tokenizer"
+)
+
+// Limits that keep repository inspection fast on large trees
+const (
+	maxWalkFiles          = 5000    // Files visited per directory or glob walk
+	maxSymbolFiles        = 20      // Files counted per mentioned symbol
+	maxContextTokens      = 200000  // Upper bound for the context-token estimate
+	maxTokenizedFileBytes = 1 << 20 // Larger mentioned files are estimated from their size
+	gitTimeout            = 3 * time.Second
+)
+
+// Thresholds used to raise the complexity level from repository signals
+const (
+	mediumFileCount     = 3
+	complexFileCount    = 10
+	mediumContextTokens = 4000
+	complexContextToken = 20000
+	mediumDiffLines     = 100
+	complexDiffLines    = 500
+)
+
+// skipDirs are never walked when matching globs or directories
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+}
+
+var (
+	// extensionPattern matches words that look like file names (main.go, README.md)
+	extensionPattern = regexp.MustCompile(`\.[A-Za-z0-9]{1,6}$`)
+
+	// backtickPattern matches `quoted` identifiers
+	backtickPattern = regexp.MustCompile("`([A-Za-z_][A-Za-z0-9_.]*)(?:\\(\\))?`")
+
+	// callPattern matches identifiers written as calls (parseConfig())
+	callPattern = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)\(\)`)
+
+	// camelPattern matches mixed-case identifiers (DecisionEngine, makeDecision)
+	camelPattern = regexp.MustCompile(`\b([a-z]+[A-Z][A-Za-z0-9]*|[A-Z][a-z0-9]+[A-Z][A-Za-z0-9]*)\b`)
+)
+
+// FileRef is a file or directory mentioned in the task that exists in the repository
+type FileRef struct {
+	Path   string `json:"path"`
+	Files  int    `json:"files"` // 1 for a file, number of files for a directory
+	Bytes  int64  `json:"bytes"`
+	Tokens int    `json:"tokens"`
+}
+
+// GlobRef is a glob pattern mentioned in the task and the files it matched
+type GlobRef struct {
+	Pattern string `json:"pattern"`
+	Files   int    `json:"files"`
+	Bytes   int64  `json:"bytes"`
+	Tokens  int    `json:"tokens"`
+}
+
+// SymbolRef is an identifier mentioned in the task and the files that contain it
+type SymbolRef struct {
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
+}
+
+// RepoSignals contains what the working directory reveals about a task
+type RepoSignals struct {
+	Files         []FileRef   `json:"files,omitempty"`
+	Globs         []GlobRef   `json:"globs,omitempty"`
+	Symbols       []SymbolRef `json:"symbols,omitempty"`
+	DiffFiles     int         `json:"diff_files"`
+	DiffLines     int         `json:"diff_lines"` // Insertions plus deletions in the uncommitted diff
+	FilesTouched  int         `json:"files_touched"`
+	ContextTokens int         `json:"context_tokens"`
+}
+
+// IsEmpty returns true if the inspection found nothing relevant
+func (rs *RepoSignals) IsEmpty() bool {
+	return len(rs.Files) == 0 && len(rs.Globs) == 0 && len(rs.Symbols) == 0 && rs.DiffLines == 0
+}
+
+// Level returns the complexity level implied by the signals alone
+func (rs *RepoSignals) Level() ComplexityLevel {
+	switch {
+	case rs.FilesTouched >= complexFileCount || rs.ContextTokens >= complexContextToken || rs.DiffLines >= complexDiffLines:
+		return Complex
+	case rs.FilesTouched >= mediumFileCount || rs.ContextTokens >= mediumContextTokens || rs.DiffLines >= mediumDiffLines:
+		return Medium
+	default:
+		return Simple
+	}
+}
+
+// Summary returns the signals as a short human-readable list
+func (rs *RepoSignals) Summary() string {
+	var parts []string
+	for _, f := range rs.Files {
+		if f.Files > 1 {
+			parts = append(parts, fmt.Sprintf("%s (%d files, ~%d tokens)", f.Path, f.Files, f.Tokens))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s (~%d tokens)", f.Path, f.Tokens))
+		}
+	}
+	for _, g := range rs.Globs {
+		parts = append(parts, fmt.Sprintf("%s matched %d files (~%d tokens)", g.Pattern, g.Files, g.Tokens))
+	}
+	for _, s := range rs.Symbols {
+		parts = append(parts, fmt.Sprintf("%s found in %d files", s.Name, len(s.Files)))
+	}
+	if rs.DiffLines > 0 {
+		parts = append(parts, fmt.Sprintf("uncommitted diff: %d lines in %d files", rs.DiffLines, rs.DiffFiles))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// RepoInspector resolves the paths, globs and symbols a task mentions
+type RepoInspector struct {
+	root string
+}
+
+// NewRepoInspector creates an inspector rooted at dir
+func NewRepoInspector(dir string) *RepoInspector {
+	return &RepoInspector{root: dir}
+}
+
+// Inspect gathers the repository signals for a task
+func (ri *RepoInspector) Inspect(task string) *RepoSignals {
+	signals := &RepoSignals{}
+	touched := make(map[string]bool)
+	var contextBytes int64
+	tokenized := 0 // Tokens counted for mentioned files beyond their size estimate
+
+	// touch adds the size of a mention's files that weren't counted yet,
+	// returning how many there were
+	touch := func(size int64, files []string) int {
+		var fresh []string
+		for _, f := range files {
+			if !touched[f] {
+				touched[f] = true
+				fresh = append(fresh, f)
+			}
+		}
+		if len(fresh) == len(files) {
+			contextBytes += size
+			return len(fresh)
+		}
+		for _, f := range fresh {
+			contextBytes += fileSize(filepath.Join(ri.root, f))
+		}
+		return len(fresh)
+	}
+
+	for _, word := range strings.Fields(task) {
+		candidate := trimWord(word)
+		if candidate == "" || strings.Contains(candidate, "://") {
+			continue
+		}
+
+		if strings.ContainsAny(candidate, "*?[") {
+			ref, files := ri.matchGlob(candidate)
+			if ref.Files > 0 {
+				signals.Globs = append(signals.Globs, ref)
+				touch(ref.Bytes, files)
+			}
+			continue
+		}
+
+		if !strings.Contains(candidate, "/") && !extensionPattern.MatchString(candidate) {
+			continue
+		}
+		ref, files, ok := ri.resolvePath(candidate)
+		if !ok {
+			continue
+		}
+		signals.Files = append(signals.Files, ref)
+		if touch(ref.Bytes, files) == 1 && ref.Files == 1 {
+			tokenized += ref.Tokens - int(ref.Bytes/4)
+		}
+	}
+
+	for _, name := range extractSymbols(task) {
+		files := ri.grepSymbol(name)
+		if len(files) == 0 {
+			continue
+		}
+		signals.Symbols = append(signals.Symbols, SymbolRef{Name: name, Files: files})
+		for _, f := range files {
+			if !touched[f] {
+				touched[f] = true
+				contextBytes += fileSize(filepath.Join(ri.root, f))
+			}
+		}
+	}
+
+	signals.DiffFiles, signals.DiffLines = ri.diffStat()
+	signals.FilesTouched = len(touched)
+
+	// Mentioned files are tokenized; walked files and diff lines (roughly 10
+	// tokens each) are estimated from their size
+	tokens := int(contextBytes/4) + tokenized + signals.DiffLines*10
+	if tokens > maxContextTokens {
+		tokens = maxContextTokens
+	}
+	signals.ContextTokens = tokens
+
+	return signals
+}
+
+// resolvePath stats a mentioned path, walking it if it is a directory.
+// Paths leading out of the repository (absolute, through "..", or through a
+// symlink) are ignored, so a task can't probe the rest of the filesystem.
+func (ri *RepoInspector) resolvePath(path string) (FileRef, []string, bool) {
+	rel := filepath.Clean(filepath.FromSlash(path))
+	if !filepath.IsLocal(rel) {
+		return FileRef{}, nil, false
+	}
+	full := filepath.Join(ri.root, rel)
+	if !ri.contains(full) {
+		return FileRef{}, nil, false
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return FileRef{}, nil, false
+	}
+
+	if !info.IsDir() {
+		rel := filepath.ToSlash(filepath.Clean(path))
+		return FileRef{Path: path, Files: 1, Bytes: info.Size(), Tokens: countFileTokens(full, info.Size())}, []string{rel}, true
+	}
+
+	ref := FileRef{Path: path}
+	var files []string
+	ri.walk(full, func(rel string, size int64) {
+		ref.Files++
+		ref.Bytes += size
+		files = append(files, rel)
+	})
+	ref.Tokens = int(ref.Bytes / 4)
+	return ref, files, ref.Files > 0
+}
+
+// contains reports whether path, with its symlinks resolved, is inside the
+// repository
+func (ri *RepoInspector) contains(path string) bool {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	root, err := filepath.EvalSymlinks(ri.root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, real)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// matchGlob counts the files matching a glob; ** matches any number of directories
+func (ri *RepoInspector) matchGlob(pattern string) (GlobRef, []string) {
+	ref := GlobRef{Pattern: pattern}
+	var files []string
+	patternParts := strings.Split(strings.TrimPrefix(pattern, "./"), "/")
+
+	ri.walk(ri.root, func(rel string, size int64) {
+		if matchSegments(patternParts, strings.Split(rel, "/")) {
+			ref.Files++
+			ref.Bytes += size
+			files = append(files, rel)
+		}
+	})
+	ref.Tokens = int(ref.Bytes / 4)
+	return ref, files
+}
+
+// walk visits regular files under dir, reporting paths relative to the root
+func (ri *RepoInspector) walk(dir string, visit func(rel string, size int64)) {
+	visited := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if skipDirs[d.Name()] && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		visited++
+		if visited > maxWalkFiles {
+			return filepath.SkipAll
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(ri.root, path)
+		if err != nil {
+			return nil
+		}
+		visit(filepath.ToSlash(rel), info.Size())
+		return nil
+	})
+}
+
+// grepSymbol lists the tracked files containing a symbol as a whole word
+func (ri *RepoInspector) grepSymbol(name string) []string {
+	output, err := ri.git("grep", "-l", "-w", "-F", "-I", "-e", name)
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() && len(files) < maxSymbolFiles {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files
+}
+
+// diffStat returns the files and lines changed in the uncommitted diff
+func (ri *RepoInspector) diffStat() (int, int) {
+	output, err := ri.git("diff", "HEAD", "--numstat")
+	if err != nil {
+		return 0, 0
+	}
+
+	files, lines := 0, 0
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		files++
+		// Binary files report "-" for both counts
+		added, _ := strconv.Atoi(fields[0])
+		deleted, _ := strconv.Atoi(fields[1])
+		lines += added + deleted
+	}
+	return files, lines
+}
+
+// git runs a git command in the inspected directory
+func (ri *RepoInspector) git(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = ri.root
+	return cmd.Output()
+}
+
+// extractSymbols returns the identifiers a task refers to, in order of appearance
+func extractSymbols(task string) []string {
+	seen := make(map[string]bool)
+	var symbols []string
+	add := func(name string) {
+		// Qualified names (router.MakeDecision) are searched by their last part
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			if extensionPattern.MatchString(name) && !strings.ContainsAny(name[i+1:], "ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+				return // file name, not a symbol
+			}
+			name = name[i+1:]
+		}
+		if len(name) < 3 || seen[name] {
+			return
+		}
+		seen[name] = true
+		symbols = append(symbols, name)
+	}
+
+	for _, m := range backtickPattern.FindAllStringSubmatch(task, -1) {
+		add(m[1])
+	}
+	for _, m := range callPattern.FindAllStringSubmatch(task, -1) {
+		add(m[1])
+	}
+	for _, m := range camelPattern.FindAllStringSubmatch(task, -1) {
+		add(m[1])
+	}
+	return symbols
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches zero or more directories
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, err := filepath.Match(pattern[0], path[0])
+	if err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+// trimWord strips quotes and punctuation around a word of the task
+func trimWord(word string) string {
+	word = strings.Trim(word, "\"'`,;:()<>{}")
+	return strings.TrimRight(word, ".!?")
+}
+
+// countFileTokens tokenizes a file, estimating from its size when it is too
+// large to read or can't be read
+func countFileTokens(path string, size int64) int {
+	if size > maxTokenizedFileBytes {
+		return int(size / 4)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return int(size / 4)
+	}
+	return tokenizer.Default().Count(string(data))
+}
+
+// fileSize returns the size of a file, or 0 if it can't be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,212 @@
+package analyzers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeRepo creates files with the given sizes under a temporary directory.
+// The content is " abc" repeated, which is one token per four bytes.
+func writeRepo(t *testing.T, files map[string]int) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, size := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat(" abc", size/4)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestRepoInspectorInspect(t *testing.T) {
+	root := writeRepo(t, map[string]int{
+		"README.md":              400,
+		"pkg/router/engine.go":   4000,
+		"pkg/router/wait.go":     2000,
+		"pkg/router/strategy.go": 2000,
+		"node_modules/x/y.go":    9000,
+	})
+
+	tests := []struct {
+		name        string
+		task        string
+		wantTouched int
+		wantTokens  int
+		wantFiles   int
+		wantGlobs   int
+	}{
+		{
+			name:        "mentioned file",
+			task:        "fix typo in README.md",
+			wantTouched: 1,
+			wantTokens:  100,
+			wantFiles:   1,
+		},
+		{
+			name:        "mentioned directory",
+			task:        "clean up pkg/router/",
+			wantTouched: 3,
+			wantTokens:  2000,
+			wantFiles:   1,
+		},
+		{
+			name:        "glob skips vendored directories",
+			task:        "add doc comments to **/*.go",
+			wantTouched: 3,
+			wantTokens:  2000,
+			wantGlobs:   1,
+		},
+		{
+			name:        "file mentioned twice is counted once",
+			task:        "fix typo in README.md, see ./README.md",
+			wantTouched: 1,
+			wantTokens:  100,
+			wantFiles:   2,
+		},
+		{
+			name:        "files under a glob and a path are counted once",
+			task:        "split pkg/router/engine.go like pkg/router/*.go",
+			wantTouched: 3,
+			wantTokens:  2000,
+			wantFiles:   1,
+			wantGlobs:   1,
+		},
+		{
+			name:        "missing paths are ignored",
+			task:        "update docs/missing.md",
+			wantTouched: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals := NewRepoInspector(root).Inspect(tt.task)
+
+			if signals.FilesTouched != tt.wantTouched {
+				t.Errorf("FilesTouched = %d, want %d", signals.FilesTouched, tt.wantTouched)
+			}
+			if signals.ContextTokens != tt.wantTokens {
+				t.Errorf("ContextTokens = %d, want %d", signals.ContextTokens, tt.wantTokens)
+			}
+			if len(signals.Files) != tt.wantFiles {
+				t.Errorf("len(Files) = %d, want %d", len(signals.Files), tt.wantFiles)
+			}
+			if len(signals.Globs) != tt.wantGlobs {
+				t.Errorf("len(Globs) = %d, want %d", len(signals.Globs), tt.wantGlobs)
+			}
+		})
+	}
+}
+
+func TestRepoInspectorStaysInRepo(t *testing.T) {
+	root := writeRepo(t, map[string]int{"main.go": 400})
+	outside := writeRepo(t, map[string]int{"secret.txt": 4000})
+	escape, err := filepath.Rel(root, filepath.Join(outside, "secret.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "linked")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		task string
+	}{
+		{"parent directory", "read " + filepath.ToSlash(escape)},
+		{"etc passwd", "explain ../../../../../../etc/passwd"},
+		{"absolute path", "explain " + filepath.ToSlash(filepath.Join(outside, "secret.txt"))},
+		{"symlink out of the repository", "explain linked/secret.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals := NewRepoInspector(root).Inspect(tt.task)
+			if len(signals.Files) != 0 || signals.FilesTouched != 0 {
+				t.Errorf("Inspect(%q) resolved %+v outside the repository", tt.task, signals.Files)
+			}
+		})
+	}
+}
+
+func TestRepoAnalyzer(t *testing.T) {
+	root := writeRepo(t, map[string]int{
+		"internal/big.go": 100000,
+	})
+
+	analysis, err := NewRepoAnalyzer(root).Analyze(context.Background(), "tidy internal/big.go")
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+
+	if analysis.Level != Complex {
+		t.Errorf("Level = %v, want %v", analysis.Level, Complex)
+	}
+	if analysis.Tokens != 1500+25000 {
+		t.Errorf("Tokens = %d, want %d", analysis.Tokens, 1500+25000)
+	}
+	if !strings.Contains(analysis.Reasoning, "internal/big.go") {
+		t.Errorf("Reasoning %q should list the mentioned file", analysis.Reasoning)
+	}
+	if analysis.Signals == nil {
+		t.Error("Signals should be set")
+	}
+
+	if _, err := NewRepoAnalyzer(root).Analyze(context.Background(), "tidy the code"); !errors.Is(err, ErrAbstain) {
+		t.Errorf("Analyze() without signals error = %v, want ErrAbstain", err)
+	}
+}
+
+func TestExtractSymbols(t *testing.T) {
+	tests := []struct {
+		task string
+		want []string
+	}{
+		{"rename `MakeDecision` in the engine", []string{"MakeDecision"}},
+		{"make parseConfig() return an error", []string{"parseConfig"}},
+		{"call router.NewDecisionEngine from status", []string{"NewDecisionEngine"}},
+		{"fix typo in `README.md`", nil},
+		{"fix typo in readme", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.task, func(t *testing.T) {
+			if got := extractSymbols(tt.task); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractSymbols() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "pkg/router/engine.go", true},
+		{"pkg/**/*_test.go", "pkg/router/engine_test.go", true},
+		{"pkg/**/*_test.go", "cmd/exec_test.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			got := matchSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.path, "/"))
+			if got != tt.want {
+				t.Errorf("matchSegments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DirName is the directory inside the state directory that holds the
+// artifacts of each run
+const DirName = "runs"
+
+// Files written to a run's artifacts directory
+const (
+	StreamFile     = "stream.log"    // Raw stdout of the tool, as it was streamed
+	StderrFile     = "stderr.log"    // Stderr of the tool
+	TranscriptFile = "transcript.md" // Output parsed from the stream
+	ResultFile     = "result.json"   // The final result, as printed by --json
+)
+
+// Dir returns the artifacts directory of a run
+func Dir(stateDir, runID string) string {
+	return filepath.Join(stateDir, DirName, runID)
+}
+
+// Create creates the artifacts directory of a run and returns its path
+func Create(stateDir, runID string) (string, error) {
+	dir := Dir(stateDir, runID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	return dir, nil
+}
+
+// WriteResult writes a run's final result to result.json
+func WriteResult(dir string, result any) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ResultFile), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
+
+// Read returns the content of an artifact. A run without the artifact (one
+// recorded before artifacts were kept, or whose tool printed nothing) is an
+// error wrapping os.ErrNotExist.
+func Read(dir, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%s not found in %s: %w", name, dir, os.ErrNotExist)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// OpenLog opens an artifact for appending, creating it if needed. Retries
+// of a run append to the logs of the first attempt.
+func OpenLog(dir, name string) (*os.File, error) {
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	return file, nil
+}
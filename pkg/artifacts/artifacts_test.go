@@ -0,0 +1,51 @@
+package artifacts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteResultRead(t *testing.T) {
+	dir, err := Create(t.TempDir(), "20250114-093012-a1b2c3")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if err := WriteResult(dir, map[string]any{"run_id": "20250114-093012-a1b2c3", "success": true}); err != nil {
+		t.Fatalf("WriteResult() error = %v", err)
+	}
+	got, err := Read(dir, ResultFile)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !strings.Contains(got, `"run_id": "20250114-093012-a1b2c3"`) {
+		t.Errorf("result.json = %s", got)
+	}
+
+	if _, err := Read(dir, TranscriptFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Read() of a missing artifact error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestOpenLogAppends(t *testing.T) {
+	dir := t.TempDir()
+	for _, attempt := range []string{"first\n", "retry\n"} {
+		file, err := OpenLog(dir, StreamFile)
+		if err != nil {
+			t.Fatalf("OpenLog() error = %v", err)
+		}
+		file.WriteString(attempt)
+		file.Close()
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, StreamFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first\nretry\n" {
+		t.Errorf("log = %q, want both attempts", data)
+	}
+}
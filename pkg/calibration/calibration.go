@@ -0,0 +1,281 @@
+package calibration
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileName is the calibration samples file inside the state directory
+const FileName = "calibration.jsonl"
+
+// MinSamples is the number of samples a group needs before its factor is used
+const MinSamples = 3
+
+// priorWeight shrinks factors toward 1.0 when there are few samples
+const priorWeight = 1.0
+
+// Ratios outside these bounds are clamped so one odd run can't dominate a factor
+const (
+	minRatio = 0.01
+	maxRatio = 100
+)
+
+// Sample is an (estimated, actual) token pair from one run
+type Sample struct {
+	Time      time.Time `json:"time"`
+	Tool      string    `json:"tool"`
+	Level     string    `json:"level"`
+	Category  string    `json:"category"`
+	Estimated int       `json:"estimated"` // Uncalibrated analyzer estimate
+	Actual    int       `json:"actual"`    // Total tokens the tool reported using
+}
+
+// Key identifies a calibration group. Empty fields aggregate over that field.
+type Key struct {
+	Tool     string `json:"tool"`
+	Level    string `json:"level"`
+	Category string `json:"category"`
+}
+
+// String formats the key for display, showing aggregated fields as "*"
+func (k Key) String() string {
+	return fmt.Sprintf("%s/%s/%s", orAny(k.Tool), orAny(k.Level), orAny(k.Category))
+}
+
+// Factor is the fitted correction for a calibration group
+type Factor struct {
+	Key         Key     `json:"key"`
+	Samples     int     `json:"samples"`
+	Value       float64 `json:"factor"`       // Multiply estimates by this
+	ErrorBefore float64 `json:"error_before"` // Mean absolute percentage error of raw estimates
+	ErrorAfter  float64 `json:"error_after"`  // Same error once the factor is applied
+}
+
+// Model holds the correction factors fitted from samples
+type Model struct {
+	factors map[Key]*Factor
+}
+
+// Fit computes correction factors for every group with samples. Each factor is
+// the geometric mean of actual/estimated, shrunk toward 1.0 for small groups.
+func Fit(samples []*Sample) *Model {
+	groups := make(map[Key][]*Sample)
+	for _, s := range samples {
+		if s.Estimated <= 0 || s.Actual <= 0 {
+			continue
+		}
+		for _, key := range groupKeys(s.Tool, s.Level, s.Category) {
+			groups[key] = append(groups[key], s)
+		}
+	}
+
+	model := &Model{factors: make(map[Key]*Factor, len(groups))}
+	for key, group := range groups {
+		logSum := 0.0
+		for _, s := range group {
+			logSum += math.Log(ratio(s))
+		}
+		value := math.Exp(logSum / (float64(len(group)) + priorWeight))
+
+		model.factors[key] = &Factor{
+			Key:         key,
+			Samples:     len(group),
+			Value:       value,
+			ErrorBefore: meanError(group, 1),
+			ErrorAfter:  meanError(group, value),
+		}
+	}
+
+	return model
+}
+
+// Factor returns the correction for a tool, level and category, falling back
+// to broader groups until one has enough samples (1.0 when none has)
+func (m *Model) Factor(tool, level, category string) float64 {
+	if f := m.Lookup(tool, level, category); f != nil {
+		return f.Value
+	}
+	return 1
+}
+
+// Lookup returns the most specific group with enough samples, or nil
+func (m *Model) Lookup(tool, level, category string) *Factor {
+	if m == nil {
+		return nil
+	}
+	for _, key := range groupKeys(tool, level, category) {
+		if f, ok := m.factors[key]; ok && f.Samples >= MinSamples {
+			return f
+		}
+	}
+	return nil
+}
+
+// Adjustment returns how much a tool's factor differs from the tool-agnostic
+// one, for estimates that already carry the tool-agnostic correction
+func (m *Model) Adjustment(tool, level, category string) float64 {
+	toolFactor := m.Lookup(tool, level, category)
+	if toolFactor == nil || toolFactor.Key.Tool == "" {
+		return 1
+	}
+	return toolFactor.Value / m.Factor("", level, category)
+}
+
+// Factors returns every fitted group, most specific first
+func (m *Model) Factors() []*Factor {
+	if m == nil {
+		return nil
+	}
+	factors := make([]*Factor, 0, len(m.factors))
+	for _, f := range m.factors {
+		factors = append(factors, f)
+	}
+	sort.Slice(factors, func(i, j int) bool {
+		a, b := factors[i].Key, factors[j].Key
+		if a.Tool != b.Tool {
+			return a.Tool > b.Tool // Named tools before the "*" aggregate
+		}
+		if a.Level != b.Level {
+			return a.Level > b.Level
+		}
+		return a.Category > b.Category
+	})
+	return factors
+}
+
+// Overall returns the factor fitted over every sample, or nil without samples
+func (m *Model) Overall() *Factor {
+	if m == nil {
+		return nil
+	}
+	return m.factors[Key{}]
+}
+
+// groupKeys lists the keys a sample belongs to, most specific first
+func groupKeys(tool, level, category string) []Key {
+	var keys []Key
+	if tool != "" {
+		keys = append(keys,
+			Key{Tool: tool, Level: level, Category: category},
+			Key{Tool: tool, Level: level},
+			Key{Tool: tool},
+		)
+	}
+	return append(keys,
+		Key{Level: level, Category: category},
+		Key{Level: level},
+		Key{},
+	)
+}
+
+// ratio returns actual/estimated, clamped
+func ratio(s *Sample) float64 {
+	r := float64(s.Actual) / float64(s.Estimated)
+	return math.Min(math.Max(r, minRatio), maxRatio)
+}
+
+// meanError returns the mean absolute percentage error of corrected estimates
+func meanError(samples []*Sample, factor float64) float64 {
+	total := 0.0
+	for _, s := range samples {
+		total += math.Abs(float64(s.Estimated)*factor-float64(s.Actual)) / float64(s.Actual)
+	}
+	return total / float64(len(samples))
+}
+
+// orAny shows an aggregated key field as "*"
+func orAny(field string) string {
+	if field == "" {
+		return "*"
+	}
+	return field
+}
+
+// Store appends calibration samples to a JSON Lines file
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewStore creates a store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Open returns the store in a state directory
+func Open(stateDir string) *Store {
+	return NewStore(filepath.Join(stateDir, FileName))
+}
+
+// Append adds a sample
+func (s *Store) Append(sample *Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create calibration directory: %w", err)
+	}
+
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return fmt.Errorf("failed to encode calibration sample: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open calibration samples: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write calibration sample: %w", err)
+	}
+	return nil
+}
+
+// Load reads every sample, skipping malformed lines
+func (s *Store) Load() ([]*Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open calibration samples: %w", err)
+	}
+	defer file.Close()
+
+	var samples []*Sample
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var sample Sample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			continue
+		}
+		samples = append(samples, &sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calibration samples: %w", err)
+	}
+
+	return samples, nil
+}
+
+// LoadModel reads the samples and fits a model
+func (s *Store) LoadModel() (*Model, error) {
+	samples, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return Fit(samples), nil
+}
@@ -0,0 +1,110 @@
+package calibration
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// samples returns n identical samples
+func samples(n int, tool, level, category string, estimated, actual int) []*Sample {
+	out := make([]*Sample, n)
+	for i := range out {
+		out[i] = &Sample{Tool: tool, Level: level, Category: category, Estimated: estimated, Actual: actual}
+	}
+	return out
+}
+
+func TestFitFactor(t *testing.T) {
+	var all []*Sample
+	all = append(all, samples(9, "claude-code", "medium", "bugfix", 500, 5000)...) // 10x under
+	all = append(all, samples(4, "codex", "medium", "bugfix", 500, 1000)...)       // 2x under
+	all = append(all, samples(2, "codex", "simple", "docs", 150, 150)...)          // Too few for their own group
+
+	model := Fit(all)
+
+	tests := []struct {
+		name     string
+		tool     string
+		level    string
+		category string
+		want     float64
+	}{
+		// 9 samples of ratio 10 shrunk by one prior sample: exp(9*ln10/10)
+		{"specific group", "claude-code", "medium", "bugfix", math.Exp(9 * math.Log(10) / 10)},
+		{"other tool", "codex", "medium", "bugfix", math.Exp(4 * math.Log(2) / 5)},
+		{"small group falls back to tool", "codex", "simple", "docs", math.Exp((4*math.Log(2) + 0) / 7)},
+		{"unknown tool uses level and category", "opencode", "medium", "bugfix", math.Exp((9*math.Log(10) + 4*math.Log(2)) / 14)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := model.Factor(tt.tool, tt.level, tt.category)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Factor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitReducesError(t *testing.T) {
+	model := Fit(samples(20, "claude-code", "complex", "refactor", 1500, 15000))
+
+	f := model.Lookup("claude-code", "complex", "refactor")
+	if f == nil {
+		t.Fatal("Lookup() = nil, want a factor")
+	}
+	if f.ErrorBefore < 0.89 {
+		t.Errorf("ErrorBefore = %v, want ~0.9", f.ErrorBefore)
+	}
+	if f.ErrorAfter >= f.ErrorBefore/5 {
+		t.Errorf("ErrorAfter = %v, want far below ErrorBefore %v", f.ErrorAfter, f.ErrorBefore)
+	}
+}
+
+func TestFactorWithoutSamples(t *testing.T) {
+	var model *Model
+	if got := model.Factor("codex", "simple", "docs"); got != 1 {
+		t.Errorf("nil model Factor() = %v, want 1", got)
+	}
+	if got := Fit(nil).Factor("codex", "simple", "docs"); got != 1 {
+		t.Errorf("empty model Factor() = %v, want 1", got)
+	}
+}
+
+func TestAdjustment(t *testing.T) {
+	var all []*Sample
+	all = append(all, samples(5, "claude-code", "medium", "bugfix", 100, 400)...)
+	all = append(all, samples(5, "codex", "medium", "bugfix", 100, 100)...)
+	model := Fit(all)
+
+	agnostic := model.Factor("", "medium", "bugfix")
+	for _, tool := range []string{"claude-code", "codex"} {
+		got := agnostic * model.Adjustment(tool, "medium", "bugfix")
+		want := model.Factor(tool, "medium", "bugfix")
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: agnostic x adjustment = %v, want %v", tool, got, want)
+		}
+	}
+
+	if got := model.Adjustment("opencode", "medium", "bugfix"); got != 1 {
+		t.Errorf("Adjustment() for a tool without samples = %v, want 1", got)
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	store := Open(filepath.Join(t.TempDir(), "state"))
+	for _, s := range samples(3, "codex", "simple", "docs", 150, 300) {
+		if err := store.Append(s); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	model, err := store.LoadModel()
+	if err != nil {
+		t.Fatalf("LoadModel() error = %v", err)
+	}
+	if f := model.Overall(); f == nil || f.Samples != 3 {
+		t.Errorf("Overall() = %+v, want 3 samples", f)
+	}
+}
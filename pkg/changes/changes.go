@@ -0,0 +1,454 @@
+package changes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits that keep snapshots cheap on large trees
+const (
+	MaxFileBytes  = 1 << 20   // Larger files are hashed but can't be diffed or reverted
+	MaxTotalBytes = 256 << 20 // Content kept per snapshot; later files are only hashed
+	maxFiles      = 20000     // Files listed outside a git repository
+	gitTimeout    = 10 * time.Second
+)
+
+// skipDirs are never walked outside a git repository
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// Status is how a file changed between two snapshots
+type Status string
+
+const (
+	Created  Status = "created"
+	Modified Status = "modified"
+	Deleted  Status = "deleted"
+)
+
+// FileState is a file as it was when a snapshot was taken
+type FileState struct {
+	Hash    string // Empty in a scan for files git has unchanged
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	Content []byte // Nil when the file was too large to keep, and in a scan for files git has unchanged
+
+	blob string // Git blob of a file that matched the index, in a scan
+}
+
+// Snapshot records the files under a directory. Inside a git repository it
+// covers tracked and untracked files that aren't ignored; elsewhere it walks
+// the directory, skipping .git, node_modules and vendor.
+type Snapshot struct {
+	Dir   string
+	Files map[string]*FileState // Keyed by slash-separated path relative to Dir
+
+	scan bool
+}
+
+// Take snapshots the files under dir with their content, so changes can be
+// diffed hunk by hunk and reverted
+func Take(dir string) (*Snapshot, error) {
+	return take(dir, false)
+}
+
+// Scan records the files under dir like Take, except that files matching the
+// git index are recorded by size, mode, modification time and blob without
+// being read. Comparing two scans reports which files changed, counting their
+// lines against the kept content or the blob.
+func Scan(dir string) (*Snapshot, error) {
+	return take(dir, true)
+}
+
+// take lists the files under dir and records them, reading their content
+// unless scan is set and git has them unchanged
+func take(dir string, scan bool) (*Snapshot, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	snapshot := &Snapshot{Dir: abs, scan: scan}
+	var clean map[string]string
+	paths, err := gitFiles(abs)
+	if err != nil {
+		paths, err = walkFiles(abs)
+		if err != nil {
+			return nil, err
+		}
+	} else if scan {
+		clean, _ = gitClean(abs) // Every file is read without it
+	}
+
+	snapshot.Files = make(map[string]*FileState, len(paths))
+	var kept int64
+	for _, path := range paths {
+		info, err := os.Lstat(filepath.Join(abs, filepath.FromSlash(path)))
+		if err != nil || !info.Mode().IsRegular() {
+			continue // Deleted but still in the index, or a symlink or directory
+		}
+		state := &FileState{Size: info.Size(), Mode: info.Mode().Perm(), ModTime: info.ModTime()}
+		if blob, ok := clean[path]; ok {
+			state.blob = blob
+			snapshot.Files[path] = state
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(abs, filepath.FromSlash(path)))
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		state.Hash = hex.EncodeToString(sum[:])
+		if len(data) <= MaxFileBytes && kept+int64(len(data)) <= MaxTotalBytes {
+			state.Content = data
+			kept += int64(len(data))
+		}
+		snapshot.Files[path] = state
+	}
+
+	return snapshot, nil
+}
+
+// FileChange is a file that differs between two snapshots
+type FileChange struct {
+	Path      string  `json:"path"`
+	Status    Status  `json:"status"`
+	Added     int     `json:"added"`
+	Removed   int     `json:"removed"`
+	Binary    bool    `json:"binary,omitempty"`    // Binary or too large to diff line by line
+	Uncounted bool    `json:"uncounted,omitempty"` // Lines not counted: the earlier content wasn't kept
+	Hunks     []*Hunk `json:"-"`
+
+	dir    string
+	before *FileState
+	after  *FileState
+}
+
+// Compare returns the files that differ between two snapshots of the same
+// directory, sorted by path. Files are compared by hash, or by blob when git
+// has them unchanged; in a scan, a file git has unchanged only on one side is
+// compared by size, mode and modification time, then by content.
+func Compare(before, after *Snapshot) []*FileChange {
+	var changes []*FileChange
+	for path, old := range before.Files {
+		cur, ok := after.Files[path]
+		switch {
+		case !ok:
+			changes = append(changes, before.change(after.Dir, path, Deleted, old, nil))
+		case changed(old, cur):
+			if c := before.change(after.Dir, path, Modified, old, cur); c != nil {
+				changes = append(changes, c)
+			}
+		}
+	}
+	for path, cur := range after.Files {
+		if _, ok := before.Files[path]; !ok {
+			changes = append(changes, before.change(after.Dir, path, Created, nil, cur))
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// changed reports whether a file differs between two snapshots
+func changed(old, cur *FileState) bool {
+	if cur.Mode != old.Mode {
+		return true
+	}
+	if old.Hash != "" && cur.Hash != "" {
+		return cur.Hash != old.Hash
+	}
+	if old.blob != "" && cur.blob != "" {
+		return cur.blob != old.blob
+	}
+	return cur.Size != old.Size || !cur.ModTime.Equal(old.ModTime)
+}
+
+// change describes a changed file, counting its lines from the content kept
+// by the snapshot, or for a scan from its git blob or the file now. A
+// modified file whose content and mode are as they were is nil.
+func (s *Snapshot) change(dir, path string, status Status, before, after *FileState) *FileChange {
+	c := &FileChange{Path: path, Status: status, dir: dir, before: before, after: after}
+	if !s.scan {
+		oldText, oldOK := textOf(before)
+		newText, newOK := textOf(after)
+		if !oldOK || !newOK {
+			c.Binary = true
+			return c
+		}
+		c.Hunks = buildHunks(diffLines(splitLines(oldText), splitLines(newText)))
+		c.count()
+		return c
+	}
+
+	if before != nil && before.Content == nil && before.blob == "" && before.Size > 0 && before.Size <= MaxFileBytes {
+		c.Uncounted = true // Past the content a scan keeps
+		return c
+	}
+	oldData, oldOK := scanned(dir, path, before)
+	newData, newOK := scanned(dir, path, after)
+	if !oldOK || !newOK {
+		c.Binary = true
+		return c
+	}
+	if status == Modified && before.Mode == after.Mode && bytes.Equal(oldData, newData) {
+		return nil // Touched, or rewritten as it was
+	}
+	oldText, oldOK := text(oldData)
+	newText, newOK := text(newData)
+	if !oldOK || !newOK {
+		c.Binary = true
+		return c
+	}
+	for _, e := range diffLines(splitLines(oldText), splitLines(newText)) {
+		switch e.kind {
+		case opInsert:
+			c.Added++
+		case opDelete:
+			c.Removed++
+		}
+	}
+	return c
+}
+
+// scanned returns the content of a file recorded by a scan: the content it
+// kept, the git blob, or the file in dir. A missing file is empty.
+func scanned(dir, path string, state *FileState) ([]byte, bool) {
+	if state == nil {
+		return nil, true
+	}
+	switch {
+	case state.Content != nil || state.Size == 0:
+		return state.Content, true
+	case state.Size > MaxFileBytes:
+		return nil, false
+	case state.blob != "":
+		return gitBlob(dir, state.blob)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	return data, err == nil
+}
+
+// count counts the added and removed lines of the hunks
+func (c *FileChange) count() {
+	for _, h := range c.Hunks {
+		for _, line := range h.Lines {
+			switch line[0] {
+			case '+':
+				c.Added++
+			case '-':
+				c.Removed++
+			}
+		}
+	}
+}
+
+// textOf returns a file's content as text; a missing file is empty text
+func textOf(state *FileState) (string, bool) {
+	if state == nil {
+		return "", true
+	}
+	if state.Content == nil && state.Size > 0 {
+		return "", false
+	}
+	return text(state.Content)
+}
+
+// text returns content as text, unless it's binary
+func text(content []byte) (string, bool) {
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return "", false
+	}
+	return string(content), true
+}
+
+// gitBlob reads a git blob
+func gitBlob(dir, blob string) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "blob", blob)
+	cmd.Dir = dir
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Diff formats the change as unified diff text
+func (c *FileChange) Diff() string {
+	var b strings.Builder
+	oldName, newName := "a/"+c.Path, "b/"+c.Path
+	if c.Status == Created {
+		oldName = "/dev/null"
+	}
+	if c.Status == Deleted {
+		newName = "/dev/null"
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	if c.Binary {
+		b.WriteString("Binary or large file changed\n")
+		return b.String()
+	}
+	for _, h := range c.Hunks {
+		b.WriteString(h.String())
+	}
+	return b.String()
+}
+
+// CanRevert reports whether the file's previous content is known
+func (c *FileChange) CanRevert() bool {
+	return c.before == nil || c.before.Content != nil || c.before.Size == 0
+}
+
+// Revert restores the file as it was in the first snapshot
+func (c *FileChange) Revert() error {
+	path := filepath.Join(c.dir, filepath.FromSlash(c.Path))
+	if c.before == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", c.Path, err)
+		}
+		return nil
+	}
+	if !c.CanRevert() {
+		return fmt.Errorf("can't revert %s: the file was too large to snapshot", c.Path)
+	}
+	return c.write(path, c.before.Content, c.before.Mode)
+}
+
+// ApplyHunks keeps the accepted hunks of a modified file and reverts the
+// rest. accepted has one entry per hunk.
+func (c *FileChange) ApplyHunks(accepted []bool) error {
+	if len(accepted) != len(c.Hunks) {
+		return fmt.Errorf("%s has %d hunks, got %d decisions", c.Path, len(c.Hunks), len(accepted))
+	}
+	if c.Binary || c.before == nil || c.after == nil {
+		return fmt.Errorf("%s can only be accepted or rejected as a whole", c.Path)
+	}
+
+	oldText, _ := textOf(c.before)
+	content := applyHunks(splitLines(oldText), c.Hunks, accepted)
+	return c.write(filepath.Join(c.dir, filepath.FromSlash(c.Path)), []byte(content), c.after.Mode)
+}
+
+// write replaces a file's content, recreating its directory if needed
+func (c *FileChange) write(path string, content []byte, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", c.Path, err)
+	}
+	if err := os.WriteFile(path, content, mode); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", c.Path, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", c.Path, err)
+	}
+	return nil
+}
+
+// gitFiles lists the tracked and untracked, non-ignored files under dir
+func gitFiles(dir string) ([]string, error) {
+	out, err := gitOutput(dir, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, path := range strings.Split(out, "\x00") {
+		// Paths in the index are listed once per stage during a merge
+		if path != "" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// gitClean returns the blob of each tracked file under dir whose content
+// matches the index, so its lines can be counted after it changes
+func gitClean(dir string) (map[string]string, error) {
+	staged, err := gitOutput(dir, "ls-files", "-z", "--stage")
+	if err != nil {
+		return nil, err
+	}
+	modified, err := gitOutput(dir, "diff", "--name-only", "--relative", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	clean := make(map[string]string)
+	for _, entry := range strings.Split(staged, "\x00") {
+		// <mode> <blob> <stage>\t<path>; conflicted files have stages 1-3
+		info, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if ok && len(fields) == 3 && fields[2] == "0" {
+			clean[path] = fields[1]
+		}
+	}
+	for _, path := range strings.Split(modified, "\x00") {
+		delete(clean, path)
+	}
+	return clean, nil
+}
+
+// gitOutput runs a git command in dir and returns its output
+func gitOutput(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// walkFiles lists the files under dir without git
+func walkFiles(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(paths) >= maxFiles {
+			return filepath.SkipAll
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %w", dir, err)
+	}
+	return paths, nil
+}
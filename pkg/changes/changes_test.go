@@ -0,0 +1,281 @@
+package changes
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyHunksRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomText := func() string {
+		var lines []string
+		for i := 0; i < rng.Intn(40); i++ {
+			lines = append(lines, fmt.Sprintf("line %d\n", rng.Intn(10)))
+		}
+		text := strings.Join(lines, "")
+		if text != "" && rng.Intn(4) == 0 {
+			text = strings.TrimSuffix(text, "\n")
+		}
+		return text
+	}
+
+	for i := 0; i < 500; i++ {
+		oldText, newText := randomText(), randomText()
+		old := splitLines(oldText)
+		hunks := buildHunks(diffLines(old, splitLines(newText)))
+
+		all := make([]bool, len(hunks))
+		none := make([]bool, len(hunks))
+		for j := range all {
+			all[j] = true
+		}
+
+		if got := applyHunks(old, hunks, all); got != newText {
+			t.Fatalf("accepting every hunk of %q -> %q gave %q", oldText, newText, got)
+		}
+		if got := applyHunks(old, hunks, none); got != oldText {
+			t.Fatalf("rejecting every hunk of %q -> %q gave %q", oldText, newText, got)
+		}
+	}
+}
+
+func TestBuildHunks(t *testing.T) {
+	var old []string
+	for i := 1; i <= 20; i++ {
+		old = append(old, fmt.Sprintf("%d\n", i))
+	}
+	cur := append([]string(nil), old...)
+	cur[1] = "two\n"       // Near the top
+	cur[17] = "eighteen\n" // Far enough away for a second hunk
+
+	hunks := buildHunks(diffLines(old, cur))
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+	if got := hunks[0].Header(); got != "@@ -1,5 +1,5 @@" {
+		t.Errorf("first hunk header = %q", got)
+	}
+	if got := hunks[1].Header(); got != "@@ -15,6 +15,6 @@" {
+		t.Errorf("second hunk header = %q", got)
+	}
+
+	// Keep only the second change
+	if got := applyHunks(old, hunks, []bool{false, true}); !strings.Contains(got, "eighteen") || strings.Contains(got, "two") {
+		t.Errorf("applyHunks() = %q, want only the second change", got)
+	}
+}
+
+func TestDiffLinesLargeFiles(t *testing.T) {
+	numbered := func(format string, n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = fmt.Sprintf(format, i)
+		}
+		return lines
+	}
+
+	// A few changes in a large file are still diffed line by line
+	old := numbered("line %d\n", 9000)
+	cur := append([]string(nil), old...)
+	cur[10] = "changed\n"
+	cur[8000] = "changed\n"
+	if hunks := buildHunks(diffLines(old, cur)); len(hunks) != 2 {
+		t.Errorf("got %d hunks, want 2", len(hunks))
+	}
+
+	// A full rewrite gives up early instead of tracing every step
+	old, cur = numbered("old %d\n", 4000), numbered("new %d\n", 4000)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := diffLines(old, cur)
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("diffLines() allocated %d MB, want at most 64 MB", allocated>>20)
+	}
+	hunks := buildHunks(edits)
+	all := make([]bool, len(hunks))
+	for i := range all {
+		all[i] = true
+	}
+	if got := applyHunks(old, hunks, all); got != strings.Join(cur, "") {
+		t.Error("applying the hunks of the rewrite didn't give the new file")
+	}
+}
+
+func TestCompareAndRevert(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		return string(data)
+	}
+
+	write("keep.txt", "same\n")
+	write("edit.txt", "a\nb\nc\n")
+	write("gone.txt", "bye\n")
+	write("node_modules/dep.js", "ignored\n")
+
+	before, err := Take(dir)
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+
+	write("edit.txt", "a\nB\nc\nd\n")
+	os.Remove(filepath.Join(dir, "gone.txt"))
+	write("sub/new.txt", "hello\n")
+	write("node_modules/dep.js", "changed\n")
+
+	after, err := Take(dir)
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+
+	changes := Compare(before, after)
+	var summary []string
+	for _, c := range changes {
+		summary = append(summary, fmt.Sprintf("%s %s +%d -%d", c.Status, c.Path, c.Added, c.Removed))
+	}
+	want := []string{"modified edit.txt +2 -1", "deleted gone.txt +0 -1", "created sub/new.txt +1 -0"}
+	if strings.Join(summary, "; ") != strings.Join(want, "; ") {
+		t.Fatalf("Compare() = %v, want %v", summary, want)
+	}
+
+	for _, c := range changes {
+		if err := c.Revert(); err != nil {
+			t.Fatalf("Revert(%s) error = %v", c.Path, err)
+		}
+	}
+	if read("edit.txt") != "a\nb\nc\n" || read("gone.txt") != "bye\n" {
+		t.Error("Revert() didn't restore the previous content")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub/new.txt")); !os.IsNotExist(err) {
+		t.Error("Revert() didn't remove the created file")
+	}
+}
+
+func TestScan(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("clean.txt", "a\nb\n")
+	write("committed.txt", "x\n")
+	write("dirty.txt", "1\n")
+	write("touched.txt", "t\n")
+	git("add", ".")
+	git("commit", "-qm", "initial")
+	write("dirty.txt", "1\n2\n") // Changed before the run
+
+	before, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	for path, f := range before.Files {
+		if read := f.Content != nil || f.Hash != ""; read != (path == "dirty.txt") {
+			t.Errorf("Scan() read %s = %v, want only the file git doesn't have", path, read)
+		}
+	}
+
+	// The file times must differ from the scan's
+	time.Sleep(10 * time.Millisecond)
+	write("clean.txt", "a\nB\nc\n")
+	write("committed.txt", "x\ny\n")
+	git("commit", "-qam", "by the tool") // The index moves on, the scanned blob doesn't
+	write("dirty.txt", "1\n2\n3\n")
+	write("touched.txt", "t\n") // Rewritten as it was
+	write("new.txt", "hello\n")
+
+	after, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	var summary []string
+	for _, c := range Compare(before, after) {
+		summary = append(summary, fmt.Sprintf("%s %s +%d -%d uncounted=%v", c.Status, c.Path, c.Added, c.Removed, c.Uncounted))
+		if c.CanRevert() != (c.Status == Created || c.Path == "dirty.txt") {
+			t.Errorf("%s CanRevert() = %v from a scan", c.Path, c.CanRevert())
+		}
+	}
+	want := []string{
+		"modified clean.txt +2 -1 uncounted=false",
+		"modified committed.txt +1 -0 uncounted=false",
+		"modified dirty.txt +1 -0 uncounted=false",
+		"created new.txt +1 -0 uncounted=false",
+	}
+	if strings.Join(summary, "; ") != strings.Join(want, "; ") {
+		t.Errorf("Compare() = %v, want %v", summary, want)
+	}
+}
+
+func TestScanWithoutGit(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("edit.txt", "a\nb\n")
+	write("touched.txt", "t\n")
+	write("gone.txt", "bye\n")
+
+	before, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	// The file times must differ from the scan's
+	time.Sleep(10 * time.Millisecond)
+	write("edit.txt", "a\nB\nc\n")
+	write("touched.txt", "t\n")
+	os.Remove(filepath.Join(dir, "gone.txt"))
+
+	after, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	var summary []string
+	for _, c := range Compare(before, after) {
+		summary = append(summary, fmt.Sprintf("%s %s +%d -%d uncounted=%v", c.Status, c.Path, c.Added, c.Removed, c.Uncounted))
+	}
+	want := []string{
+		"modified edit.txt +2 -1 uncounted=false",
+		"deleted gone.txt +0 -1 uncounted=false",
+	}
+	if strings.Join(summary, "; ") != strings.Join(want, "; ") {
+		t.Errorf("Compare() = %v, want %v", summary, want)
+	}
+}
@@ -0,0 +1,314 @@
+package changes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each hunk
+const contextLines = 3
+
+// Bounds of the Myers diff: larger files, and files with more changed lines,
+// become a single hunk. The trace kept for the edit script grows with the
+// square of the changed lines.
+const (
+	maxDiffLines    = 20000
+	maxEditDistance = 2000
+)
+
+// opKind is the kind of a line in an edit script
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// edit is one line of an edit script
+type edit struct {
+	kind opKind
+	text string
+}
+
+// Hunk is a group of nearby changed lines with their context
+type Hunk struct {
+	OldStart int      `json:"old_start"` // 1-based first line in the old file
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"-"` // Lines prefixed with ' ', '-' or '+', including their newline
+}
+
+// Header returns the hunk's @@ line
+func (h *Hunk) Header() string {
+	// An empty side is numbered after the line it follows, as diff does
+	oldStart, newStart := h.OldStart, h.NewStart
+	if h.OldLines == 0 {
+		oldStart--
+	}
+	if h.NewLines == 0 {
+		newStart--
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, h.OldLines, newStart, h.NewLines)
+}
+
+// String formats the hunk as unified diff text
+func (h *Hunk) String() string {
+	var b strings.Builder
+	b.WriteString(h.Header())
+	b.WriteString("\n")
+	for _, line := range h.Lines {
+		b.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return b.String()
+}
+
+// oldText returns the hunk's lines as they were before the change
+func (h *Hunk) oldText() []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line[0] != '+' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+// newText returns the hunk's lines as they are after the change
+func (h *Hunk) newText() []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line[0] != '-' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+// splitLines splits text into lines that keep their newline, so joining
+// them restores the text exactly
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the edit script turning a into b
+func diffLines(a, b []string) []edit {
+	if len(a)+len(b) > maxDiffLines {
+		return replaceAll(a, b)
+	}
+	if edits, ok := myers(a, b, maxEditDistance); ok {
+		return edits
+	}
+	return replaceAll(a, b)
+}
+
+// replaceAll is the edit script deleting every line of a and inserting b
+func replaceAll(a, b []string) []edit {
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, edit{opDelete, line})
+	}
+	for _, line := range b {
+		edits = append(edits, edit{opInsert, line})
+	}
+	return edits
+}
+
+// myers computes a shortest edit script with Myers' O(ND) algorithm, or
+// reports false when it takes more than limit deleted and inserted lines
+func myers(a, b []string, limit int) ([]edit, bool) {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil, true
+	}
+	max := n + m
+	if max > limit {
+		max = limit
+	}
+	offset := max + 1
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		// Step d only reads diagonals -d..d of the previous one
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Move down: insert from b
+			} else {
+				x = v[offset+k-1] + 1 // Move right: delete from a
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks the Myers trace back from the end to build the edit script.
+// trace[d] holds diagonals -d..d as they were before step d.
+func backtrack(trace [][]int, a, b []string, d int) []edit {
+	x, y := len(a), len(b)
+	var edits []edit
+
+	for ; d > 0; d-- {
+		v, offset := trace[d], d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{opEqual, a[x]})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{opInsert, b[y]})
+		} else {
+			x--
+			edits = append(edits, edit{opDelete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{opEqual, a[x]})
+	}
+
+	// Reverse into forward order
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// buildHunks groups an edit script into hunks with surrounding context
+func buildHunks(edits []edit) []*Hunk {
+	var hunks []*Hunk
+	var current *Hunk
+	oldLine, newLine := 1, 1
+	lastChange := -1 // Index in edits of the last changed line in current
+
+	for i, e := range edits {
+		if e.kind != opEqual {
+			if current == nil || i-lastChange > 2*contextLines {
+				// Start a new hunk with up to contextLines of leading context
+				start := i
+				for start > 0 && i-start < contextLines && edits[start-1].kind == opEqual {
+					start--
+				}
+				current = &Hunk{OldStart: oldLine - (i - start), NewStart: newLine - (i - start)}
+				for _, c := range edits[start:i] {
+					current.Lines = append(current.Lines, " "+c.text)
+				}
+				hunks = append(hunks, current)
+			} else {
+				// Close the gap since the last change with context lines
+				for _, c := range edits[lastChange+1 : i] {
+					current.Lines = append(current.Lines, " "+c.text)
+				}
+			}
+			if e.kind == opDelete {
+				current.Lines = append(current.Lines, "-"+e.text)
+			} else {
+				current.Lines = append(current.Lines, "+"+e.text)
+			}
+			lastChange = i
+		} else if current != nil && i-lastChange > 2*contextLines {
+			current = nil
+		}
+
+		switch e.kind {
+		case opEqual:
+			oldLine++
+			newLine++
+		case opDelete:
+			oldLine++
+		case opInsert:
+			newLine++
+		}
+	}
+
+	// Trailing context and line counts
+	for _, h := range hunks {
+		h.OldLines = len(h.oldText())
+		h.NewLines = len(h.newText())
+	}
+	return addTrailingContext(hunks, edits)
+}
+
+// addTrailingContext appends up to contextLines of unchanged lines after
+// each hunk's last change
+func addTrailingContext(hunks []*Hunk, edits []edit) []*Hunk {
+	oldLines := make([]string, 0, len(edits))
+	for _, e := range edits {
+		if e.kind != opInsert {
+			oldLines = append(oldLines, e.text)
+		}
+	}
+
+	for i, h := range hunks {
+		end := h.OldStart - 1 + h.OldLines // Index of the first old line after the hunk
+		limit := len(oldLines)
+		if i+1 < len(hunks) {
+			limit = hunks[i+1].OldStart - 1
+		}
+		for j := end; j < limit && j < end+contextLines; j++ {
+			h.Lines = append(h.Lines, " "+oldLines[j])
+			h.OldLines++
+			h.NewLines++
+		}
+	}
+	return hunks
+}
+
+// applyHunks rebuilds the new text from the old one, applying only the
+// accepted hunks
+func applyHunks(old []string, hunks []*Hunk, accepted []bool) string {
+	var b strings.Builder
+	pos := 0 // Next old line to copy
+	for i, h := range hunks {
+		start := h.OldStart - 1
+		for ; pos < start && pos < len(old); pos++ {
+			b.WriteString(old[pos])
+		}
+		lines := h.oldText()
+		if accepted[i] {
+			lines = h.newText()
+		}
+		for _, line := range lines {
+			b.WriteString(line)
+		}
+		pos = start + h.OldLines
+	}
+	for ; pos < len(old); pos++ {
+		b.WriteString(old[pos])
+	}
+	return b.String()
+}
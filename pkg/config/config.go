@@ -0,0 +1,247 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
+)
+
+// ProjectFileName is the per-repository configuration file
+const ProjectFileName = ".ai-dispatcher.yml"
+
+// GlobalFileName is the per-user configuration file inside the state directory
+const GlobalFileName = "config.yml"
+
+// Config holds the user and project settings for the dispatcher
+type Config struct {
+	// Strategy is the default routing strategy (cheapest, best-quality, ...)
+	Strategy string `yaml:"strategy"`
+
+	// Keywords adds or replaces complexity keyword packs per language (en, es, ...)
+	Keywords map[string]*analyzers.KeywordPack `yaml:"keywords"`
+
+	// TestCommand runs the project's tests (detected from the project files when
+	// empty). Only the global config can set it.
+	TestCommand string `yaml:"test_command"`
+
+	// Verify lists commands that must pass after a task runs (--verify overrides
+	// it). A project file can only set it in a trusted repository.
+	Verify []string `yaml:"verify"`
+
+	// TrustedRepos lists the directories whose project file may set Verify.
+	// Only the global config can set it.
+	TrustedRepos []string `yaml:"trusted_repos"`
+
+	// VerifyRetries is how many times a task is re-sent after failing verification
+	VerifyRetries int `yaml:"verify_retries"`
+
+	// RetryTool is the tool retries go to: same (default), alternative, or a tool name
+	RetryTool string `yaml:"retry_tool"`
+
+	// Permissions is what tools may do during a run (--permissions,
+	// --allow-command and --deny-command override it). A project file can
+	// only restrict it.
+	Permissions *permissions.Profile `yaml:"permissions"`
+
+	// Env, EnvAllow and EnvDeny control the environment of every tool. A
+	// project file can only add to EnvDeny.
+	Env      map[string]string `yaml:"env"`       // Variables set for the tools
+	EnvAllow []string          `yaml:"env_allow"` // Only matching variables are passed on (all when empty)
+	EnvDeny  []string          `yaml:"env_deny"`  // Matching variables are never passed on
+
+	// Tools holds settings for one tool, by tool name (claude-code, codex, opencode)
+	Tools map[string]*ToolConfig `yaml:"tools"`
+
+	// Ignored lists the keys of the project file that were left out, since
+	// only the global config can set them
+	Ignored []string `yaml:"-"`
+}
+
+// ToolConfig holds the settings of one tool, added to those for every tool. A
+// project file can only add to EnvDeny.
+type ToolConfig struct {
+	Args     []string          `yaml:"args"` // Passed through after the dispatcher's own
+	Env      map[string]string `yaml:"env"`
+	EnvAllow []string          `yaml:"env_allow"`
+	EnvDeny  []string          `yaml:"env_deny"`
+}
+
+// StateDir returns the directory where the dispatcher keeps its state
+// ($AI_DISPATCHER_HOME, or ~/.ai-dispatcher)
+func StateDir() string {
+	if dir := os.Getenv("AI_DISPATCHER_HOME"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".ai-dispatcher"
+	}
+	return filepath.Join(home, ".ai-dispatcher")
+}
+
+// Load reads the global config and then the project config from the working
+// directory. Settings in the project file override the global ones, except
+// where the project file comes with a repository that can't be trusted: see
+// overlay.
+func Load() (*Config, error) {
+	cfg := &Config{}
+	if _, err := cfg.read(filepath.Join(StateDir(), GlobalFileName)); err != nil {
+		return nil, err
+	}
+
+	project := &Config{}
+	keys, err := project.read(ProjectFileName)
+	if err != nil {
+		return nil, err
+	}
+	cfg.overlay(project, keys, cfg.trusts("."))
+
+	return cfg, nil
+}
+
+// trusts reports whether dir is one of the trusted repositories. Entries must
+// be absolute or start with ~/, so a project can't be trusted by accident.
+func (c *Config) trusts(dir string) bool {
+	dir, err := canonical(dir)
+	if err != nil {
+		return false
+	}
+	for _, repo := range c.TrustedRepos {
+		if rest, ok := strings.CutPrefix(repo, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				continue
+			}
+			repo = filepath.Join(home, rest)
+		}
+		if !filepath.IsAbs(repo) {
+			continue
+		}
+		if path, err := canonical(repo); err == nil && path == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// canonical returns the absolute path with its symlinks resolved
+func canonical(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// read loads the settings from a file, ignoring files that don't exist, and
+// returns the top-level keys it sets
+func (c *Config) read(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	keys := make(map[string]bool, len(values))
+	for key := range values {
+		keys[key] = true
+	}
+
+	return keys, nil
+}
+
+// overlay applies the settings of the project file. It can't set the tools'
+// environment or arguments, which could hand secrets or flags to the tools
+// of any repository the dispatcher runs in; it can only deny more variables.
+// Nor can it set the test command or, outside a trusted repository, the
+// verification commands, which run in a shell. Likewise it can lower the
+// permission level and deny commands, but not raise the level or allow
+// commands.
+func (c *Config) overlay(project *Config, keys map[string]bool, trusted bool) {
+	if keys["strategy"] {
+		c.Strategy = project.Strategy
+	}
+	for language, pack := range project.Keywords {
+		if c.Keywords == nil {
+			c.Keywords = make(map[string]*analyzers.KeywordPack)
+		}
+		c.Keywords[language] = pack
+	}
+	if keys["test_command"] {
+		c.Ignored = append(c.Ignored, "test_command")
+	}
+	if keys["verify"] && trusted {
+		c.Verify = project.Verify
+	} else if keys["verify"] {
+		c.Ignored = append(c.Ignored, "verify")
+	}
+	if keys["trusted_repos"] {
+		c.Ignored = append(c.Ignored, "trusted_repos")
+	}
+	if keys["verify_retries"] {
+		c.VerifyRetries = project.VerifyRetries
+	}
+	if keys["retry_tool"] {
+		c.RetryTool = project.RetryTool
+	}
+	if project.Permissions != nil {
+		var ignored []string
+		c.Permissions, ignored = c.Permissions.Restrict(project.Permissions)
+		for _, field := range ignored {
+			c.Ignored = append(c.Ignored, "permissions."+field)
+		}
+	}
+
+	c.EnvDeny = append(c.EnvDeny, project.EnvDeny...)
+	c.ignore("", &ToolConfig{Env: project.Env, EnvAllow: project.EnvAllow})
+	names := make([]string, 0, len(project.Tools))
+	for name := range project.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		tool := project.Tools[name]
+		if tool == nil {
+			continue
+		}
+		if c.Tools == nil {
+			c.Tools = make(map[string]*ToolConfig)
+		}
+		if c.Tools[name] == nil {
+			c.Tools[name] = &ToolConfig{}
+		}
+		c.Tools[name].EnvDeny = append(c.Tools[name].EnvDeny, tool.EnvDeny...)
+		c.ignore("tools."+name+".", tool)
+	}
+}
+
+// ignore records the settings of a project layer that only the global
+// config can set
+func (c *Config) ignore(prefix string, layer *ToolConfig) {
+	if len(layer.Env) > 0 {
+		c.Ignored = append(c.Ignored, prefix+"env")
+	}
+	if len(layer.EnvAllow) > 0 {
+		c.Ignored = append(c.Ignored, prefix+"env_allow")
+	}
+	if len(layer.Args) > 0 {
+		c.Ignored = append(c.Ignored, prefix+"args")
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
 	"github.com/crlian/ai-dispatcher/pkg/delegators"
+	"github.com/crlian/ai-dispatcher/pkg/progress"
+	"github.com/crlian/ai-dispatcher/pkg/router"
 	"github.com/crlian/ai-dispatcher/pkg/trackers"
 )
 
@@ -39,7 +42,9 @@ type Orchestrator struct {
 	delegators     map[string]delegators.Delegator
 	availableTools map[string]bool // Track which tools are available
 	timeout        time.Duration
-	useMocks       bool // For testing without real tools
+	useMocks       bool                          // For testing without real tools
+	engine         *router.DecisionEngine        // Picks a tool when none was mentioned
+	analyzer       *analyzers.ComplexityAnalyzer // Analyzes the task for the engine
 }
 
 // NewOrchestrator creates a new council orchestrator
@@ -50,21 +55,7 @@ func NewOrchestrator() *Orchestrator {
 
 	for _, d := range allDelegators {
 		// Map to standard keys used throughout the codebase
-		toolType := d.GetToolType()
-		var key string
-		switch toolType {
-		case trackers.ClaudeCodeTool:
-			key = "claude"
-		case trackers.CodexTool:
-			key = "codex"
-		case trackers.OpenCodeTool:
-			key = "opencode"
-		default:
-			key = strings.ToLower(d.GetToolName())
-			key = strings.ReplaceAll(key, " ", "")
-			key = strings.ReplaceAll(key, "-", "")
-		}
-		delegatorMap[key] = d
+		delegatorMap[toolKey(d.GetToolType(), d.GetToolName())] = d
 	}
 
 	return &Orchestrator{
@@ -98,6 +89,61 @@ func (o *Orchestrator) SetAvailableTools(available map[string]bool) {
 	o.availableTools = available
 }
 
+// SetRouting sets the analyzer and engine used to pick a tool when none was mentioned
+func (o *Orchestrator) SetRouting(analyzer *analyzers.ComplexityAnalyzer, engine *router.DecisionEngine) {
+	o.analyzer = analyzer
+	o.engine = engine
+}
+
+// SetProgress sets how the tools show they are still working during Execute
+func (o *Orchestrator) SetProgress(mode progress.Mode) {
+	for _, delegator := range o.delegators {
+		delegator.SetProgress(mode)
+	}
+}
+
+// toolKey maps a tool type to the short key used in council mode
+func toolKey(toolType trackers.ToolType, toolName string) string {
+	switch toolType {
+	case trackers.ClaudeCodeTool:
+		return "claude"
+	case trackers.CodexTool:
+		return "codex"
+	case trackers.OpenCodeTool:
+		return "opencode"
+	default:
+		key := strings.ToLower(toolName)
+		key = strings.ReplaceAll(key, " ", "")
+		return strings.ReplaceAll(key, "-", "")
+	}
+}
+
+// routeTool picks a tool for the task with the decision engine's strategy
+// Returns an empty string if no engine is set or no available tool was found
+func (o *Orchestrator) routeTool(task string) string {
+	if o.engine == nil || o.analyzer == nil || task == "" {
+		return ""
+	}
+
+	analysis, err := o.analyzer.AnalyzeComplexity(task)
+	if err != nil {
+		return ""
+	}
+
+	decision, err := o.engine.MakeDecision(analysis, "")
+	if err != nil {
+		return ""
+	}
+
+	key := toolKey(decision.SelectedTool, decision.SelectedName)
+	if available, ok := o.availableTools[key]; !ok || !available {
+		return ""
+	}
+
+	o.engine.CommitDecision(decision)
+	return key
+}
+
 // buildCouncilPrompt constructs a rich prompt with conversation history
 func (o *Orchestrator) buildCouncilPrompt(currentMessage string) string {
 	var prompt strings.Builder
@@ -267,10 +313,13 @@ func (o *Orchestrator) Query(tool, message string) Response {
 
 // Execute runs a task with a tool (real execution)
 func (o *Orchestrator) Execute(tool string) string {
-	// If no tool specified, use last mentioned
+	// If no tool specified, use last mentioned, then the routing strategy
 	if tool == "" {
 		tool = o.session.GetLastTool()
 	}
+	if tool == "" {
+		tool = o.routeTool(o.findOriginalTask())
+	}
 
 	if tool == "" {
 		return "No hay herramienta seleccionada. Menciona una herramienta primero."
@@ -405,10 +454,13 @@ func DetectFile(message string) string {
 
 // Plan generates an execution plan using the specified tool
 func (o *Orchestrator) Plan(tool string) (*Plan, error) {
-	// Resolve tool (use LastTool if not specified)
+	// Resolve tool (use LastTool if not specified, then the routing strategy)
 	if tool == "" {
 		tool = o.session.GetLastTool()
 	}
+	if tool == "" {
+		tool = o.routeTool(o.findOriginalTask())
+	}
 	if tool == "" {
 		return nil, fmt.Errorf("no hay herramienta seleccionada")
 	}
@@ -0,0 +1,29 @@
+package delegators
+
+// Activity is what a tool reported doing during a run. Only tools whose
+// output is a structured event stream report it.
+type Activity struct {
+	Commands     []*CommandRun `json:"commands,omitempty"`
+	FileEdits    []*FileEdit   `json:"file_edits,omitempty"`
+	Reasoning    []string      `json:"reasoning,omitempty"`
+	FinalMessage string        `json:"final_message,omitempty"` // The tool's last message to the user
+	Errors       []string      `json:"errors,omitempty"`
+}
+
+// CommandRun is a shell command the tool ran
+type CommandRun struct {
+	Command  string `json:"command"`
+	ExitCode *int   `json:"exit_code,omitempty"` // Nil when the command didn't finish
+	Output   string `json:"output,omitempty"`
+}
+
+// Failed reports whether the command finished with a non-zero exit code
+func (c *CommandRun) Failed() bool {
+	return c.ExitCode != nil && *c.ExitCode != 0
+}
+
+// FileEdit is a file the tool reported changing
+type FileEdit struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"` // add, update or delete
+}
@@ -0,0 +1,152 @@
+package delegators
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/crlian/ai-dispatcher/pkg/tokenizer"
+)
+
+// Size limits for attachments, so a stray path doesn't blow up the prompt
+const (
+	MaxAttachmentBytes = 512 << 10 // Per attachment
+	MaxAttachmentTotal = 2 << 20   // All attachments of a task together
+)
+
+// ImageTokenEstimate is the token estimate for an attached image
+const ImageTokenEstimate = 1500
+
+// StdinName is the name of the attachment read from standard input
+const StdinName = "stdin"
+
+// imageExtensions are the attachments sent as images instead of text
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// Attachment is a file (or piped input) added to a task's prompt
+type Attachment struct {
+	Name    string `json:"name"`           // Path as given, or "stdin"
+	Path    string `json:"path,omitempty"` // Absolute path (empty for piped input)
+	Size    int64  `json:"size"`
+	Image   bool   `json:"image,omitempty"`
+	Content string `json:"-"` // Text content (empty for images)
+}
+
+// LoadAttachment reads a text file or checks an image for attaching
+func LoadAttachment(path string) (*Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("attachment %s is a directory", path)
+	}
+	if info.Size() > MaxAttachmentBytes {
+		return nil, fmt.Errorf("attachment %s is %d bytes, over the %d byte limit", path, info.Size(), MaxAttachmentBytes)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve attachment %s: %w", path, err)
+	}
+	attachment := &Attachment{Name: path, Path: abs, Size: info.Size()}
+
+	if imageExtensions[strings.ToLower(filepath.Ext(path))] {
+		attachment.Image = true
+		return attachment, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if !isText(data) {
+		return nil, fmt.Errorf("attachment %s is a binary file", path)
+	}
+	attachment.Content = string(data)
+	return attachment, nil
+}
+
+// NewTextAttachment creates an attachment from text that isn't a file, such as piped input
+func NewTextAttachment(name, content string) (*Attachment, error) {
+	if len(content) > MaxAttachmentBytes {
+		return nil, fmt.Errorf("%s is %d bytes, over the %d byte limit", name, len(content), MaxAttachmentBytes)
+	}
+	return &Attachment{Name: name, Size: int64(len(content)), Content: content}, nil
+}
+
+// CheckAttachments enforces the total size limit
+func CheckAttachments(attachments []*Attachment) error {
+	var total int64
+	for _, a := range attachments {
+		total += a.Size
+	}
+	if total > MaxAttachmentTotal {
+		return fmt.Errorf("attachments total %d bytes, over the %d byte limit", total, MaxAttachmentTotal)
+	}
+	return nil
+}
+
+// AttachmentTokens estimates the tokens the attachments add to a prompt
+func AttachmentTokens(attachments []*Attachment, tok tokenizer.Tokenizer) int {
+	tokens := 0
+	for _, a := range attachments {
+		if a.Image {
+			tokens += ImageTokenEstimate
+		} else {
+			tokens += tok.Count(a.Inline())
+		}
+	}
+	return tokens
+}
+
+// IsFile reports whether the attachment can be passed to a tool by path
+func (a *Attachment) IsFile() bool {
+	return a.Path != ""
+}
+
+// Inline formats a text attachment for embedding in a prompt
+func (a *Attachment) Inline() string {
+	content := a.Content
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return fmt.Sprintf("<attachment name=%q>\n%s</attachment>", a.Name, content)
+}
+
+// withAttachments appends the attachments to the task: inline ones with their
+// content, the rest as a list of paths the tool was given
+func withAttachments(task string, inline, referenced []*Attachment) string {
+	if len(inline) == 0 && len(referenced) == 0 {
+		return task
+	}
+
+	var b strings.Builder
+	b.WriteString(task)
+	for _, a := range inline {
+		b.WriteString("\n\n")
+		b.WriteString(a.Inline())
+	}
+	if len(referenced) > 0 {
+		b.WriteString("\n\nAttached files:")
+		for _, a := range referenced {
+			b.WriteString("\n- ")
+			b.WriteString(a.Path)
+		}
+	}
+	return b.String()
+}
+
+// isText reports whether data looks like text (valid UTF-8 without NUL bytes)
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
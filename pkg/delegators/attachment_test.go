@@ -0,0 +1,109 @@
+package delegators
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadAttachment(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		wantImage bool
+		wantErr   string
+	}{
+		{"text file", write("spec.md", []byte("# Spec\n")), false, ""},
+		{"image", write("mockup.PNG", []byte{0x89, 'P', 'N', 'G', 0}), true, ""},
+		{"binary file", write("app.bin", []byte{0x7f, 'E', 'L', 'F', 0}), false, "binary"},
+		{"too large", write("big.txt", []byte(strings.Repeat("a", MaxAttachmentBytes+1))), false, "limit"},
+		{"directory", dir, false, "directory"},
+		{"missing", filepath.Join(dir, "missing.txt"), false, "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachment, err := LoadAttachment(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadAttachment() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadAttachment() error = %v", err)
+			}
+			if attachment.Image != tt.wantImage {
+				t.Errorf("Image = %v, want %v", attachment.Image, tt.wantImage)
+			}
+			if !filepath.IsAbs(attachment.Path) {
+				t.Errorf("Path = %q, want an absolute path", attachment.Path)
+			}
+		})
+	}
+}
+
+func TestCheckAttachments(t *testing.T) {
+	small := &Attachment{Name: "a", Size: MaxAttachmentBytes}
+	if err := CheckAttachments([]*Attachment{small, small}); err != nil {
+		t.Errorf("CheckAttachments() error = %v, want nil", err)
+	}
+
+	many := make([]*Attachment, MaxAttachmentTotal/MaxAttachmentBytes+1)
+	for i := range many {
+		many[i] = small
+	}
+	if err := CheckAttachments(many); err == nil {
+		t.Error("CheckAttachments() error = nil, want the total limit exceeded")
+	}
+}
+
+func TestAttachmentArgs(t *testing.T) {
+	stdin, _ := NewTextAttachment(StdinName, "diff --git a/x b/x")
+	spec := &Attachment{Name: "spec.md", Path: "/repo/spec.md", Content: "the spec"}
+	image := &Attachment{Name: "mockup.png", Path: "/repo/mockup.png", Image: true}
+	attachments := []*Attachment{stdin, spec, image}
+
+	t.Run("codex passes images as flags", func(t *testing.T) {
+		cd := NewCodexDelegator()
+		cd.SetAttachments(attachments)
+		inline, args := cd.attachmentArgs()
+		if len(inline) != 2 || strings.Join(args, " ") != "--image /repo/mockup.png" {
+			t.Errorf("inline = %d, args = %v", len(inline), args)
+		}
+	})
+
+	t.Run("opencode passes files as flags", func(t *testing.T) {
+		ocd := NewOpenCodeDelegator()
+		ocd.SetAttachments(attachments)
+		inline, args := ocd.attachmentArgs()
+		if len(inline) != 1 || inline[0] != stdin {
+			t.Errorf("inline = %v, want only stdin", inline)
+		}
+		if strings.Join(args, " ") != "--file /repo/spec.md --file /repo/mockup.png" {
+			t.Errorf("args = %v", args)
+		}
+	})
+
+	t.Run("claude embeds text and references images", func(t *testing.T) {
+		ccd := NewClaudeCodeDelegator()
+		ccd.SetAttachments(attachments)
+		inline, images := ccd.splitAttachments(func(a *Attachment) bool { return a.Image })
+		prompt := withAttachments("review this", inline, images)
+		for _, want := range []string{`<attachment name="stdin">`, "the spec", "Attached files:\n- /repo/mockup.png"} {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("prompt %q should contain %q", prompt, want)
+			}
+		}
+	})
+}
@@ -4,9 +4,26 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
 	"github.com/crlian/ai-dispatcher/pkg/trackers"
 )
 
+// claudePermissionModes maps permission levels to Claude Code permission
+// modes. Plan mode only reads; acceptEdits edits files in the working
+// directory but asks before running commands, which -p denies.
+var claudePermissionModes = map[permissions.Level]string{
+	permissions.ReadOnly:       "plan",
+	permissions.WorkspaceWrite: "acceptEdits",
+	permissions.FullAccess:     "bypassPermissions",
+}
+
+// claudePermissionFlags are the Claude Code flags that change what it may do
+var claudePermissionFlags = []string{
+	"--permission-mode", "--dangerously-skip-permissions",
+	"--allowedTools", "--allowed-tools", "--disallowedTools", "--disallowed-tools",
+	"--add-dir", "--settings",
+}
+
 // ClaudeCodeDelegator executes tasks using Claude Code
 type ClaudeCodeDelegator struct {
 	*BaseDelegator
@@ -25,6 +42,15 @@ func NewClaudeCodeDelegator() *ClaudeCodeDelegator {
 
 // Execute runs a task using Claude Code
 func (ccd *ClaudeCodeDelegator) Execute(ctx context.Context, task string) (*DelegationResult, error) {
+	profile, err := ccd.permissionProfile()
+	if err != nil {
+		return nil, fmt.Errorf("claude-code execution failed: %w", err)
+	}
+
+	// Text is embedded in the prompt; images are left for Claude to read by path
+	inline, images := ccd.splitAttachments(func(a *Attachment) bool { return a.Image })
+	task = withAttachments(task, inline, images)
+
 	// Build command arguments
 	// Using print mode (-p) for non-interactive execution with Haiku model
 	// Stream JSON for real-time progress display (requires --verbose)
@@ -38,6 +64,11 @@ func (ccd *ClaudeCodeDelegator) Execute(ctx context.Context, task string) (*Dele
 		"--include-partial-messages",
 		"--verbose",
 	}
+	args = append(args, claudePermissionArgs(profile)...)
+	if ccd.session != "" {
+		args = append(args, "--resume", ccd.session)
+	}
+	args = append(args, ccd.extraArgs...)
 
 	// Execute command
 	result, err := ccd.ExecuteCommand(ctx, args)
@@ -50,6 +81,13 @@ func (ccd *ClaudeCodeDelegator) Execute(ctx context.Context, task string) (*Dele
 
 // Query asks Claude for input in council mode (without executing)
 func (ccd *ClaudeCodeDelegator) Query(ctx context.Context, prompt string) (string, error) {
+	if err := ccd.checkExtraArgs(); err != nil {
+		return "", fmt.Errorf("claude-code query failed: %w", err)
+	}
+
+	inline, images := ccd.splitAttachments(func(a *Attachment) bool { return a.Image })
+	prompt = withAttachments(prompt, inline, images)
+
 	// Add strict prefix for language and behavior
 	strictPrompt := "⚠️  CRITICAL: Respond in the SAME LANGUAGE as the user. " +
 		"Maximum 2-3 short sentences. Do not explain who you are. Just answer directly.\n\n" + prompt
@@ -63,6 +101,8 @@ func (ccd *ClaudeCodeDelegator) Query(ctx context.Context, prompt string) (strin
 		"haiku",
 		// Use plain output for faster responses in chat mode
 	}
+	args = append(args, claudePermissionArgs(ccd.queryProfile())...)
+	args = append(args, ccd.extraArgs...)
 
 	// Execute command WITHOUT streaming (clean output for council chat)
 	result, err := ccd.ExecuteCommandSimple(ctx, args)
@@ -72,3 +112,16 @@ func (ccd *ClaudeCodeDelegator) Query(ctx context.Context, prompt string) (strin
 
 	return result.Output, nil
 }
+
+// claudePermissionArgs translates a permission profile into Claude Code
+// flags. Commands become Bash rules matching them and their arguments.
+func claudePermissionArgs(profile *permissions.Profile) []string {
+	args := []string{"--permission-mode", claudePermissionModes[profile.Level]}
+	for _, command := range profile.AllowedCommands {
+		args = append(args, "--allowedTools", "Bash("+command+":*)")
+	}
+	for _, command := range profile.DeniedCommands {
+		args = append(args, "--disallowedTools", "Bash("+command+":*)")
+	}
+	return args
+}
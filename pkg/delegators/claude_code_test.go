@@ -0,0 +1,44 @@
+package delegators
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
+)
+
+func TestClaudePermissionArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile *permissions.Profile
+		want    string
+	}{
+		{
+			name:    "read-only",
+			profile: &permissions.Profile{Level: permissions.ReadOnly},
+			want:    "--permission-mode plan",
+		},
+		{
+			name:    "workspace-write",
+			profile: permissions.Default(),
+			want:    "--permission-mode acceptEdits",
+		},
+		{
+			name: "command rules",
+			profile: &permissions.Profile{
+				Level:           permissions.FullAccess,
+				AllowedCommands: []string{"go test"},
+				DeniedCommands:  []string{"git push", "rm"},
+			},
+			want: "--permission-mode bypassPermissions --allowedTools Bash(go test:*) --disallowedTools Bash(git push:*) --disallowedTools Bash(rm:*)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(claudePermissionArgs(tt.profile), " "); got != tt.want {
+				t.Errorf("claudePermissionArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
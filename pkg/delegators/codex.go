@@ -5,9 +5,27 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
 	"github.com/crlian/ai-dispatcher/pkg/trackers"
 )
 
+// codexSandboxModes maps permission levels to Codex sandbox modes
+var codexSandboxModes = map[permissions.Level]string{
+	permissions.ReadOnly:       "read-only",
+	permissions.WorkspaceWrite: "workspace-write",
+	permissions.FullAccess:     "danger-full-access",
+}
+
+// codexPermissionFlags are the Codex flags that change what it may do
+var codexPermissionFlags = []string{
+	"--sandbox", "-s", "--ask-for-approval", "-a", "--full-auto",
+	"--dangerously-bypass-approvals-and-sandbox", "--add-dir",
+}
+
+// codexPermissionSettings are the config keys, set with -c, that change
+// what Codex may do
+var codexPermissionSettings = []string{"sandbox_mode", "sandbox_workspace_write", "approval_policy"}
+
 // CodexDelegator executes tasks using Codex
 type CodexDelegator struct {
 	*BaseDelegator
@@ -20,8 +38,8 @@ func NewCodexDelegator() *CodexDelegator {
 		trackers.CodexTool,
 		"codex",
 	)
-	// Use default parser (passes output directly without NDJSON parsing)
-	bd.parserType = ParserTypeDefault
+	// Codex runs with --json, so its JSONL events are parsed
+	bd.parserType = ParserTypeCodex
 	return &CodexDelegator{
 		BaseDelegator: bd,
 	}
@@ -29,19 +47,29 @@ func NewCodexDelegator() *CodexDelegator {
 
 // Execute runs a task using Codex
 func (cd *CodexDelegator) Execute(ctx context.Context, task string) (*DelegationResult, error) {
+	profile, err := cd.permissionProfile()
+	if err != nil {
+		return nil, fmt.Errorf("codex execution failed: %w", err)
+	}
+
 	// Build command arguments
-	// Using default output format (clean, structured display)
-	// Full-auto to avoid approval prompts
+	// JSONL events instead of the human-readable output, whose format changes
+	// exec never asks for approval, so the sandbox is what limits Codex
 	args := []string{
 		"exec",
-		"--full-auto",
+		"--json",
 		"--model", "gpt-5.2-codex",
 		"-c", "model_reasoning_effort=low", // Use low reasoning to save tokens
-		"--sandbox", "read-only",
+		"--sandbox", codexSandboxModes[profile.Level],
 		"--skip-git-repo-check",
-		"--",
-		task,
 	}
+	inline, images := cd.attachmentArgs()
+	args = append(args, images...)
+	args = append(args, cd.extraArgs...) // Options of exec go before resume
+	if cd.session != "" {
+		args = append(args, "resume", cd.session)
+	}
+	args = append(args, "--", withAttachments(task, inline, nil))
 
 	// Execute command
 	result, err := cd.ExecuteCommand(ctx, args)
@@ -54,22 +82,30 @@ func (cd *CodexDelegator) Execute(ctx context.Context, task string) (*Delegation
 
 // Query asks Codex for input in council mode (without executing)
 func (cd *CodexDelegator) Query(ctx context.Context, prompt string) (string, error) {
+	if err := cd.checkExtraArgs(); err != nil {
+		return "", fmt.Errorf("codex query failed: %w", err)
+	}
+
+	inline, images := cd.attachmentArgs()
+	prompt = withAttachments(prompt, inline, nil)
+
 	// Add strict prefix to force concise responses and match user's language
 	strictPrompt := "EXTREMELY IMPORTANT: Respond in the SAME LANGUAGE as the user. " +
 		"Maximum 2 short sentences. NO markdown, NO lists, NO headers. " +
 		"Just plain direct text.\n\n" + prompt
 
-	// For council mode, we use exec command WITHOUT --full-auto
-	// so Codex can analyze the project context
-	// Sandbox allows read access to workspace so it can see the code
+	// For council mode, Codex can read the project for context but not
+	// change it
 	args := []string{
 		"exec",
+		"--json",
 		"--model", "gpt-5.2-codex",
 		"-c", "model_reasoning_effort=low", // Use low reasoning to save tokens
-		"--sandbox", "workspace-write", // Allow access to workspace for context
-		"--",
-		strictPrompt,
+		"--sandbox", codexSandboxModes[cd.queryProfile().Level],
 	}
+	args = append(args, images...)
+	args = append(args, cd.extraArgs...)
+	args = append(args, "--", strictPrompt)
 
 	// Execute command WITHOUT streaming (clean output for council chat)
 	result, err := cd.ExecuteCommandSimple(ctx, args)
@@ -77,85 +113,30 @@ func (cd *CodexDelegator) Query(ctx context.Context, prompt string) (string, err
 		return "", fmt.Errorf("codex query failed: %w", err)
 	}
 
-	// Parse Codex output to extract only the useful response
-	// Codex outputs metadata, then "thinking", then "codex" section with the actual response
-	output := cd.parseCodexOutput(result.Output)
-
-	return output, nil
-}
-
-// parseCodexOutput extracts the actual response from Codex verbose output
-func (cd *CodexDelegator) parseCodexOutput(output string) string {
-	// Find the "codex" section which contains the actual response
-	lines := strings.Split(output, "\n")
-	var result []string
-	inResponse := false
-
-	for i, line := range lines {
-		// Skip metadata headers at the start
-		if !inResponse {
-			if strings.HasPrefix(line, "OpenAI Codex") ||
-				strings.HasPrefix(line, "workdir:") ||
-				strings.HasPrefix(line, "model:") ||
-				strings.HasPrefix(line, "provider:") ||
-				strings.HasPrefix(line, "approval:") ||
-				strings.HasPrefix(line, "sandbox:") ||
-				strings.HasPrefix(line, "reasoning") ||
-				strings.HasPrefix(line, "session id:") ||
-				strings.HasPrefix(line, "mcp startup:") ||
-				line == "--------" ||
-				strings.HasPrefix(line, "user") {
-				continue
-			}
-
-			// Look for "thinking" section - skip it
-			if strings.HasPrefix(line, "thinking") {
-				continue
-			}
-
-			// Look for the actual response after "codex" marker
-			if line == "codex" && i+1 < len(lines) {
-				inResponse = true
-				continue
-			}
-		}
-
-		// Once we're in the response, include everything except trailing metadata
-		if inResponse {
-			// Stop if we hit tokens used line
-			if strings.HasPrefix(line, "tokens used") ||
-				isOnlyNumbersAndCommas(strings.TrimSpace(line)) {
-				break
-			}
-			result = append(result, line)
-		}
-	}
-
-	// Clean up
-	for len(result) > 0 && strings.TrimSpace(result[len(result)-1]) == "" {
-		result = result[:len(result)-1]
+	// The answer is the final agent message among the events
+	transcript := NewTranscript(nil)
+	if err := NewCodexStreamParser(strings.NewReader(result.Output), transcript.Handle).Parse(); err != nil {
+		return "", fmt.Errorf("codex query failed: %w", err)
 	}
-
-	if len(result) == 0 {
-		// Try to return everything after "codex" line
-		if idx := strings.Index(output, "\ncodex\n"); idx != -1 {
-			return strings.TrimSpace(output[idx+8:])
-		}
-		return output
+	activity := transcript.Activity()
+	switch {
+	case activity != nil && activity.FinalMessage != "":
+		return activity.FinalMessage, nil
+	case activity != nil && len(activity.Errors) > 0:
+		return "", fmt.Errorf("codex query failed: %s", activity.Errors[len(activity.Errors)-1])
+	case !result.Success:
+		return "", fmt.Errorf("codex query failed: %s", result.Error)
 	}
-
-	return strings.Join(result, "\n")
+	return "", nil
 }
 
-// isOnlyNumbersAndCommas checks if a string contains only digits, commas, or dots
-func isOnlyNumbersAndCommas(s string) bool {
-	if s == "" {
-		return false
-	}
-	for _, r := range s {
-		if !((r >= '0' && r <= '9') || r == ',' || r == '.') {
-			return false
-		}
+// attachmentArgs returns the attachments embedded in the prompt and the
+// --image flags for attached images
+func (cd *CodexDelegator) attachmentArgs() ([]*Attachment, []string) {
+	inline, images := cd.splitAttachments(func(a *Attachment) bool { return a.Image })
+	var args []string
+	for _, a := range images {
+		args = append(args, "--image", a.Path)
 	}
-	return true
+	return inline, args
 }
@@ -5,30 +5,61 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"strings"
 )
 
-type CodexLineHandler func(line string)
-
+// CodexStreamParser parses the JSONL events of `codex exec --json` into
+// events
 type CodexStreamParser struct {
-	reader     io.Reader
-	onLine     CodexLineHandler
-	lineBuffer strings.Builder
+	reader       io.Reader
+	onEvent      EventHandler
+	finalMessage string
+}
+
+// codexEvent is one line of `codex exec --json` output
+type codexEvent struct {
+	Type      string          `json:"type"`
+	ThreadID  string          `json:"thread_id"`
+	SessionID string          `json:"session_id"` // session.created, from older versions
+	Item      *codexItem      `json:"item"`
+	Message   string          `json:"message"` // error
+	Error     json.RawMessage `json:"error"`   // turn.failed: {"message": ...}
+	Usage     *Usage          `json:"usage"`   // turn.completed
+}
+
+// codexItem is the item of an item.started or item.completed event
+type codexItem struct {
+	ID               string             `json:"id"`
+	Type             string             `json:"type"`
+	Text             string             `json:"text"`
+	Command          string             `json:"command"`
+	AggregatedOutput string             `json:"aggregated_output"`
+	ExitCode         *int               `json:"exit_code"`
+	Message          string             `json:"message"`
+	Changes          []*codexFileChange `json:"changes"`
+}
+
+// codexFileChange is a file in a file_change item
+type codexFileChange struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
 }
 
-func NewCodexStreamParser(reader io.Reader, onLine CodexLineHandler) *CodexStreamParser {
-	if onLine == nil {
-		onLine = func(line string) {}
+func NewCodexStreamParser(reader io.Reader, onEvent EventHandler) *CodexStreamParser {
+	if onEvent == nil {
+		onEvent = func(event *Event) {}
 	}
 	return &CodexStreamParser{
-		reader: reader,
-		onLine: onLine,
+		reader:  reader,
+		onEvent: onEvent,
 	}
 }
 
-func (sp *CodexStreamParser) Parse() (string, error) {
+// Parse reads the stream to its end, passing each event to the handler. The
+// last agent message is passed on as the result.
+func (sp *CodexStreamParser) Parse() error {
 	scanner := bufio.NewScanner(sp.reader)
-	var fullOutput strings.Builder
+	// Completed commands carry their whole output on one line
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
@@ -36,122 +67,91 @@ func (sp *CodexStreamParser) Parse() (string, error) {
 			continue
 		}
 
-		var event map[string]interface{}
-		if err := json.Unmarshal(line, &event); err != nil {
+		var event codexEvent
+		if err := json.Unmarshal(line, &event); err != nil || event.Type == "" {
 			continue
 		}
 
-		eventType, ok := event["type"].(string)
-		if !ok {
-			continue
-		}
-
-		switch eventType {
-		case "delta":
-			if delta, ok := event["delta"].(map[string]interface{}); ok {
-				if content, ok := delta["content"].(string); ok && content != "" {
-					sp.processText(content, &fullOutput)
-				}
+		switch event.Type {
+		case "thread.started", "session.created":
+			if event.ThreadID != "" {
+				sp.onEvent(&Event{Type: EventSession, SessionID: event.ThreadID})
+			} else if event.SessionID != "" {
+				sp.onEvent(&Event{Type: EventSession, SessionID: event.SessionID})
 			}
 
-		case "message":
-			if contentRaw, ok := event["content"].(string); ok && contentRaw != "" {
-				if sp.lineBuffer.Len() == 0 {
-					sp.processText(contentRaw, &fullOutput)
-				}
-			} else if contentArray, ok := event["content"].([]interface{}); ok {
-				for _, item := range contentArray {
-					if itemStr, ok := item.(string); ok && itemStr != "" {
-						if sp.lineBuffer.Len() == 0 {
-							sp.processText(itemStr, &fullOutput)
-						}
-					}
-				}
+		case "item.started":
+			if item := event.Item; item != nil && item.Type == "command_execution" {
+				sp.onEvent(&Event{Type: EventCommand, ID: item.ID, Command: item.Command, Status: CommandStarted})
 			}
 
 		case "item.completed":
-			if item, ok := event["item"].(map[string]interface{}); ok {
-				sp.extractItemContent(item, &fullOutput)
+			if event.Item != nil {
+				sp.completeItem(event.Item)
 			}
 
-		case "item.started":
-			if item, ok := event["item"].(map[string]interface{}); ok {
-				if cmd, ok := item["command"].(string); ok && cmd != "" {
-					sp.processText("$ "+cmd, &fullOutput)
-				}
+		case "turn.completed":
+			if event.Usage != nil {
+				sp.onEvent(&Event{Type: EventUsage, Usage: event.Usage})
 			}
 
-		case "result":
-			if result, ok := event["result"].(string); ok && result != "" {
-				if sp.lineBuffer.Len() == 0 {
-					sp.processText(result, &fullOutput)
-				}
+		case "turn.failed":
+			var failure struct {
+				Message string `json:"message"`
+			}
+			if json.Unmarshal(event.Error, &failure) == nil && failure.Message != "" {
+				sp.onEvent(&Event{Type: EventError, Text: failure.Message})
 			}
 
 		case "error":
-			if errorMsg, ok := event["error"].(string); ok && errorMsg != "" {
-				sp.processText("[Error] "+errorMsg, &fullOutput)
+			if event.Message != "" {
+				sp.onEvent(&Event{Type: EventError, Text: event.Message})
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("codex stream parsing error: %w", err)
+		return fmt.Errorf("codex stream parsing error: %w", err)
 	}
 
-	if sp.lineBuffer.Len() > 0 {
-		line := sp.lineBuffer.String()
-		sp.onLine(line)
-		fullOutput.WriteString(line)
-		sp.lineBuffer.Reset()
+	if sp.finalMessage != "" {
+		sp.onEvent(&Event{Type: EventResult, Text: sp.finalMessage})
 	}
-
-	return fullOutput.String(), nil
+	return nil
 }
 
-func (sp *CodexStreamParser) processText(text string, output *strings.Builder) {
-	sp.lineBuffer.WriteString(text)
-
-	for {
-		content := sp.lineBuffer.String()
-		newlineIdx := strings.Index(content, "\n")
-		if newlineIdx == -1 {
-			break
+// completeItem passes on a finished item
+func (sp *CodexStreamParser) completeItem(item *codexItem) {
+	switch item.Type {
+	case "command_execution":
+		sp.onEvent(&Event{
+			Type:     EventCommand,
+			ID:       item.ID,
+			Command:  item.Command,
+			Status:   CommandCompleted,
+			ExitCode: item.ExitCode,
+			Output:   item.AggregatedOutput,
+		})
+
+	case "file_change":
+		for _, change := range item.Changes {
+			sp.onEvent(&Event{Type: EventFileEdit, Path: change.Path, Kind: change.Kind})
 		}
 
-		line := content[:newlineIdx]
-		remaining := content[newlineIdx+1:]
-
-		sp.onLine(line)
-		output.WriteString(line)
-		output.WriteString("\n")
-
-		sp.lineBuffer.Reset()
-		sp.lineBuffer.WriteString(remaining)
-	}
-}
-
-func (sp *CodexStreamParser) extractItemContent(item map[string]interface{}, output *strings.Builder) {
-	text, hasText := item["text"].(string)
-	if hasText && text != "" {
-		// Solo mostrar reasoning sustancial (>200 chars), filtrar planificación intermedia
-		if len(text) > 200 {
-			sp.processText(text+"\n", output)
+	case "reasoning":
+		if item.Text != "" {
+			sp.onEvent(&Event{Type: EventReasoning, Text: item.Text})
 		}
-	}
 
-	if itemType, ok := item["type"].(string); ok {
-		if itemType == "command_execution" {
-			if aggregatedOutput, ok := item["aggregated_output"].(string); ok && aggregatedOutput != "" {
-				sp.processText(aggregatedOutput, output)
-			}
+	case "agent_message":
+		if item.Text != "" {
+			sp.finalMessage = item.Text
+			sp.onEvent(&Event{Type: EventText, Text: ensureNewline(item.Text)})
 		}
-	}
-}
 
-func (sp *CodexStreamParser) GetAccumulated() string {
-	if sp.lineBuffer.Len() > 0 {
-		return sp.lineBuffer.String()
+	case "error":
+		if item.Message != "" {
+			sp.onEvent(&Event{Type: EventError, Text: item.Message})
+		}
 	}
-	return ""
 }
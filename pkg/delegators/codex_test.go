@@ -0,0 +1,224 @@
+package delegators
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// fakeCodex puts a codex on PATH that records its arguments and prints stream
+func fakeCodex(t *testing.T, stream string) (argsFile string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake codex is a shell script")
+	}
+	bin := t.TempDir()
+	argsFile = filepath.Join(bin, "args")
+	writeFile(t, bin, "stream.jsonl", stream)
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\ncat " + filepath.Join(bin, "stream.jsonl") + "\n"
+	writeFile(t, bin, "codex", script)
+	if err := os.Chmod(filepath.Join(bin, "codex"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsFile
+}
+
+func TestCodexQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "final message",
+			stream: `{"type":"thread.started","thread_id":"t1"}
+{"type":"item.completed","item":{"id":"item_0","type":"reasoning","text":"Looking at the router"}}
+{"type":"item.completed","item":{"id":"item_1","type":"agent_message","text":"Use the cheapest strategy."}}
+{"type":"turn.completed","usage":{"input_tokens":10,"output_tokens":5}}`,
+			want: "Use the cheapest strategy.",
+		},
+		{
+			name: "turn failed",
+			stream: `{"type":"thread.started","thread_id":"t1"}
+{"type":"turn.failed","error":{"message":"usage limit reached"}}`,
+			wantErr: "usage limit reached",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argsFile := fakeCodex(t, tt.stream)
+
+			answer, err := NewCodexDelegator().Query(context.Background(), "which strategy?")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Query() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if answer != tt.want {
+				t.Errorf("Query() = %q, want %q", answer, tt.want)
+			}
+
+			args, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(args), "--json\n") {
+				t.Errorf("codex ran without --json: %q", args)
+			}
+		})
+	}
+}
+
+func TestCodexExecuteActivity(t *testing.T) {
+	fakeCodex(t, `{"type":"thread.started","thread_id":"0199-thread"}
+{"type":"item.started","item":{"id":"item_1","type":"command_execution","command":"ls","status":"in_progress"}}
+{"type":"item.completed","item":{"id":"item_1","type":"command_execution","command":"ls","aggregated_output":"main.go\n","exit_code":0,"status":"completed"}}
+{"type":"item.completed","item":{"id":"item_2","type":"agent_message","text":"Done."}}`)
+
+	cd := NewCodexDelegator()
+	cd.SetOutput(&strings.Builder{})
+	cd.SetWorkDir(t.TempDir())
+	result, err := cd.Execute(context.Background(), "list files")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("Execute() failed: %s", result.Error)
+	}
+	if result.SessionID != "0199-thread" {
+		t.Errorf("SessionID = %q", result.SessionID)
+	}
+	if result.Activity == nil || len(result.Activity.Commands) != 1 || result.Activity.FinalMessage != "Done." {
+		t.Fatalf("Activity = %+v", result.Activity)
+	}
+	if result.Output != "$ ls\nmain.go\nDone.\n" {
+		t.Errorf("Output = %q", result.Output)
+	}
+}
+
+func TestCodexExecuteEvents(t *testing.T) {
+	fakeCodex(t, `{"type":"thread.started","thread_id":"0199-thread"}
+{"type":"item.completed","item":{"id":"item_1","type":"agent_message","text":"Done."}}
+{"type":"turn.completed","usage":{"input_tokens":1200,"cached_input_tokens":1000,"output_tokens":30}}`)
+
+	events := make(chan *Event)
+	var got []*Event
+	done := make(chan struct{})
+	go func() {
+		for event := range events {
+			got = append(got, event)
+		}
+		close(done)
+	}()
+
+	cd := NewCodexDelegator()
+	cd.SetOutput(&strings.Builder{})
+	cd.SetWorkDir(t.TempDir())
+	cd.SetEvents(events)
+	result, err := cd.Execute(context.Background(), "say done")
+	close(events)
+	<-done
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	var types []EventType
+	for _, event := range got {
+		if event.Tool != trackers.CodexTool || event.Time.IsZero() {
+			t.Errorf("event %+v isn't stamped with the tool and time", event)
+		}
+		types = append(types, event.Type)
+	}
+	want := []EventType{EventSession, EventText, EventUsage, EventResult}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("event types = %v, want %v", types, want)
+	}
+	if result.Usage == nil || result.Usage.InputTokens != 1200 || result.Usage.CachedInputTokens != 1000 {
+		t.Errorf("Usage = %+v", result.Usage)
+	}
+}
+
+func TestCodexPermissions(t *testing.T) {
+	stream := `{"type":"item.completed","item":{"id":"item_1","type":"agent_message","text":"ok"}}`
+	tests := []struct {
+		name    string
+		profile *permissions.Profile
+		query   bool
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: "--sandbox\nworkspace-write\n"},
+		{name: "read-only", profile: &permissions.Profile{Level: permissions.ReadOnly}, want: "--sandbox\nread-only\n"},
+		{name: "full access", profile: &permissions.Profile{Level: permissions.FullAccess}, want: "--sandbox\ndanger-full-access\n"},
+		{name: "query is read-only", profile: &permissions.Profile{Level: permissions.FullAccess}, query: true, want: "--sandbox\nread-only\n"},
+		{name: "command rules", profile: &permissions.Profile{Level: permissions.WorkspaceWrite, DeniedCommands: []string{"rm"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argsFile := fakeCodex(t, stream)
+			cd := NewCodexDelegator()
+			cd.SetOutput(&strings.Builder{})
+			cd.SetWorkDir(t.TempDir())
+			cd.SetPermissions(tt.profile)
+
+			var err error
+			if tt.query {
+				_, err = cd.Query(context.Background(), "which file?")
+			} else {
+				_, err = cd.Execute(context.Background(), "fix it")
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			args, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(args), tt.want) {
+				t.Errorf("codex args = %q, want %q", args, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodexExtraArgs(t *testing.T) {
+	argsFile := fakeCodex(t, `{"type":"item.completed","item":{"id":"item_1","type":"agent_message","text":"ok"}}`)
+	cd := NewCodexDelegator()
+	cd.SetOutput(&strings.Builder{})
+	cd.SetWorkDir(t.TempDir())
+	cd.SetSession("0199-thread")
+	cd.SetExtraArgs([]string{"--model", "gpt-5"})
+	if _, err := cd.Execute(context.Background(), "fix it"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(args), "--model\ngpt-5\nresume\n0199-thread\n--\nfix it\n") {
+		t.Errorf("codex args = %q, want the extra args before resume", args)
+	}
+}
@@ -3,15 +3,21 @@ package delegators
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/glamour"
+	"github.com/crlian/ai-dispatcher/pkg/changes"
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
+	"github.com/crlian/ai-dispatcher/pkg/progress"
+	"github.com/crlian/ai-dispatcher/pkg/tokenizer"
 	"github.com/crlian/ai-dispatcher/pkg/trackers"
 	"github.com/mattn/go-isatty"
 )
@@ -19,6 +25,12 @@ import (
 // DefaultTimeout is the default timeout for task execution
 const DefaultTimeout = 5 * time.Minute
 
+// idleProgressDelay is how long a tool prints nothing before the spinner shows
+const idleProgressDelay = 2 * time.Second
+
+// KillGracePeriod is how long a cancelled tool gets to exit before it is killed
+const KillGracePeriod = 5 * time.Second
+
 // DelegationResult represents the result of executing a task
 type DelegationResult struct {
 	Success    bool          `json:"success"`
@@ -28,6 +40,17 @@ type DelegationResult struct {
 	Duration   time.Duration `json:"duration"`
 	ToolName   string        `json:"tool_name"`
 	ExitCode   int           `json:"exit_code"`
+	SessionID  string        `json:"session_id,omitempty"` // Tool session a follow-up can resume
+	Activity   *Activity     `json:"activity,omitempty"`   // Commands, edits and messages the tool reported
+	Usage      *Usage        `json:"usage,omitempty"`      // Tokens used, when the tool reports them
+
+	// Cancelled is set when the run was interrupted or timed out; Output
+	// holds what the tool printed until then
+	Cancelled    bool   `json:"cancelled,omitempty"`
+	CancelReason string `json:"cancel_reason,omitempty"`
+
+	// FilesChanged lists the files the tool created, modified or deleted
+	FilesChanged []*changes.FileChange `json:"files_changed,omitempty"`
 }
 
 // Delegator defines the interface for executing tasks with AI tools
@@ -47,20 +70,78 @@ type Delegator interface {
 
 	// SetTimeout sets the execution timeout
 	SetTimeout(timeout time.Duration)
+
+	// SetAttachments sets the files added to the prompt of Execute and Query
+	SetAttachments(attachments []*Attachment)
+
+	// SetWorkDir sets the directory the tool runs in (the caller's when empty)
+	SetWorkDir(dir string)
+
+	// SetSession sets the tool session Execute resumes (a new one when empty)
+	SetSession(sessionID string)
+
+	// SetOutput sets where Execute streams the tool's output (stderr, with
+	// progress and markdown rendering, when nil)
+	SetOutput(w io.Writer)
+
+	// SetLogDir sets the artifacts directory Execute and Query write the raw
+	// stream, stderr and transcript to (nothing is written when empty)
+	SetLogDir(dir string)
+
+	// SetPermissions sets what the tool may do during Execute (the default
+	// profile when nil). Query always runs read-only.
+	SetPermissions(profile *permissions.Profile)
+
+	// SetEnvironment sets the variables the tool runs with (the dispatcher's
+	// when nil)
+	SetEnvironment(env *Environment)
+
+	// SetExtraArgs sets arguments passed through to the tool after the ones
+	// the delegator builds, so they can override them; Execute refuses those
+	// that change what the tool may do
+	SetExtraArgs(args []string)
+
+	// SetProgress sets how Execute shows that the tool is still working
+	// while it prints nothing (detected from stderr when empty or auto)
+	SetProgress(mode progress.Mode)
+
+	// SetRecording sets a file Execute and Query append the tool's raw stdout
+	// and stderr to, with their timing, for replay (nothing is recorded when
+	// empty)
+	SetRecording(path string)
+
+	// SetCommand sets the executable run for the tool, in place of its own
+	// (such as a wrapper script, or a fake agent in tests)
+	SetCommand(command string)
+
+	// SetEvents sets a channel Execute sends the events of the run to, as
+	// they happen. The caller must keep receiving until Execute returns.
+	SetEvents(events chan<- *Event)
 }
 
+// Parser parses a tool's output stream into events
 type Parser interface {
-	Parse() (string, error)
-	GetAccumulated() string
+	Parse() error
 }
 
 // BaseDelegator provides common functionality for all delegators
 type BaseDelegator struct {
-	toolName   string
-	toolType   trackers.ToolType
-	command    string
-	timeout    time.Duration
-	parserType string
+	toolName     string
+	toolType     trackers.ToolType
+	command      string
+	timeout      time.Duration
+	parserType   string
+	attachments  []*Attachment
+	workDir      string
+	session      string
+	output       io.Writer
+	logDir       string
+	events       chan<- *Event
+	permissions  *permissions.Profile
+	env          *Environment
+	extraArgs    []string
+	recording    string
+	progressMode progress.Mode
 }
 
 const (
@@ -95,6 +176,159 @@ func (bd *BaseDelegator) SetTimeout(timeout time.Duration) {
 	bd.timeout = timeout
 }
 
+// SetAttachments sets the files added to the prompt
+func (bd *BaseDelegator) SetAttachments(attachments []*Attachment) {
+	bd.attachments = attachments
+}
+
+// SetWorkDir sets the directory the tool runs in
+func (bd *BaseDelegator) SetWorkDir(dir string) {
+	bd.workDir = dir
+}
+
+// SetSession sets the tool session to resume
+func (bd *BaseDelegator) SetSession(sessionID string) {
+	bd.session = sessionID
+}
+
+// SetOutput sets where progress is streamed
+func (bd *BaseDelegator) SetOutput(w io.Writer) {
+	bd.output = w
+}
+
+// SetLogDir sets the artifacts directory
+func (bd *BaseDelegator) SetLogDir(dir string) {
+	bd.logDir = dir
+}
+
+// SetPermissions sets the permission profile
+func (bd *BaseDelegator) SetPermissions(profile *permissions.Profile) {
+	bd.permissions = profile
+}
+
+// permissionProfile returns the profile Execute runs with, or an error when
+// the tool can't enforce it or the extra arguments would override it
+func (bd *BaseDelegator) permissionProfile() (*permissions.Profile, error) {
+	profile := bd.permissions
+	if profile == nil {
+		profile = permissions.Default()
+	}
+	if err := permissions.Check(bd.toolType, profile); err != nil && bd.permissions != nil {
+		return nil, err
+	}
+	if err := bd.checkExtraArgs(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// checkExtraArgs returns an error when the extra arguments change what the
+// tool may do. The permission profile decides that, and routing checked that
+// the tool enforces it, so they can't override it.
+func (bd *BaseDelegator) checkExtraArgs() error {
+	var flags, settings []string
+	switch bd.toolType {
+	case trackers.ClaudeCodeTool:
+		flags = claudePermissionFlags
+	case trackers.CodexTool:
+		flags, settings = codexPermissionFlags, codexPermissionSettings
+	}
+
+	for i, arg := range bd.extraArgs {
+		name, value, hasValue := strings.Cut(arg, "=")
+		for _, flag := range flags {
+			if name == flag {
+				return fmt.Errorf("%s can't be passed to %s: set permissions with --permissions, --allow-command or --deny-command", name, bd.toolName)
+			}
+		}
+		if len(settings) == 0 || (name != "-c" && name != "--config") {
+			continue
+		}
+		if !hasValue && i+1 < len(bd.extraArgs) {
+			value = bd.extraArgs[i+1]
+		}
+		key, _, _ := strings.Cut(value, "=")
+		for _, setting := range settings {
+			if key == setting || strings.HasPrefix(key, setting+".") {
+				return fmt.Errorf("%s can't be set for %s: set permissions with --permissions, --allow-command or --deny-command", key, bd.toolName)
+			}
+		}
+	}
+	return nil
+}
+
+// queryProfile returns the profile Query runs with: the requested one, or
+// the default, lowered to read-only
+func (bd *BaseDelegator) queryProfile() *permissions.Profile {
+	if bd.permissions == nil {
+		return permissions.Default().ReadOnly()
+	}
+	return bd.permissions.ReadOnly()
+}
+
+// SetEnvironment sets the tool's environment
+func (bd *BaseDelegator) SetEnvironment(env *Environment) {
+	bd.env = env
+}
+
+// SetExtraArgs sets the arguments passed through to the tool
+func (bd *BaseDelegator) SetExtraArgs(args []string) {
+	bd.extraArgs = args
+}
+
+// SetProgress sets how progress is shown
+func (bd *BaseDelegator) SetProgress(mode progress.Mode) {
+	bd.progressMode = mode
+}
+
+// SetRecording sets the file the tool's output is recorded to
+func (bd *BaseDelegator) SetRecording(path string) {
+	bd.recording = path
+}
+
+// SetCommand sets the executable run for the tool
+func (bd *BaseDelegator) SetCommand(command string) {
+	bd.command = command
+}
+
+// SetEvents sets the channel events are sent to
+func (bd *BaseDelegator) SetEvents(events chan<- *Event) {
+	bd.events = events
+}
+
+// prepareCommand prepares the tool's process in its directory, with its
+// environment and in its own process group
+func (bd *BaseDelegator) prepareCommand(ctx context.Context, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, bd.command, args...)
+	cmd.Dir = bd.workDir
+	if bd.env != nil {
+		cmd.Env = bd.env.Apply(os.Environ())
+	}
+	setProcessGroup(cmd)
+	return cmd
+}
+
+// dir returns the directory the tool runs in
+func (bd *BaseDelegator) dir() string {
+	if bd.workDir == "" {
+		return "."
+	}
+	return bd.workDir
+}
+
+// splitAttachments separates the attachments the tool takes by path from
+// those embedded in the prompt
+func (bd *BaseDelegator) splitAttachments(byPath func(*Attachment) bool) (inline, files []*Attachment) {
+	for _, a := range bd.attachments {
+		if a.IsFile() && byPath(a) {
+			files = append(files, a)
+		} else {
+			inline = append(inline, a)
+		}
+	}
+	return inline, files
+}
+
 // ExecuteCommand executes a command with timeout and captures output
 // Uses streaming to parse real-time progress from Claude Code output
 func (bd *BaseDelegator) ExecuteCommand(ctx context.Context, args []string) (*DelegationResult, error) {
@@ -103,7 +337,10 @@ func (bd *BaseDelegator) ExecuteCommand(ctx context.Context, args []string) (*De
 	defer cancel()
 
 	// Prepare command
-	cmd := exec.CommandContext(ctx, bd.command, args...)
+	cmd := bd.prepareCommand(ctx, args)
+
+	// Scan the files, without reading them, so the tool's changes can be reported
+	before, snapErr := changes.Scan(bd.dir())
 
 	// Get stdout pipe for streaming
 	stdoutPipe, err := cmd.StdoutPipe()
@@ -111,32 +348,43 @@ func (bd *BaseDelegator) ExecuteCommand(ctx context.Context, args []string) (*De
 		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
 	}
 
-	// Capture stderr separately
+	// Capture stderr separately, and keep everything the tool prints
 	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	logs := bd.openLogs(args)
+	defer logs.Close()
+	cmd.Stderr = io.MultiWriter(&stderr, logs.stderr)
+	stdout := io.TeeReader(stdoutPipe, logs.stream)
 
 	// Start timing
 	start := time.Now()
 
 	// Start command (non-blocking)
-	err = cmd.Start()
+	untrack, err := startTracked(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start command: %w", err)
 	}
 
+	// Progress goes to stderr unless the caller collects it
+	var out io.Writer = os.Stderr
+	if bd.output != nil {
+		out = bd.output
+	}
+
+	// Show that the tool is still working while it prints nothing. Callers
+	// that collect the output report progress their own way.
+	mode := progress.Resolve(bd.progressMode, os.Stderr)
+	if bd.output != nil {
+		mode = progress.None
+	}
+	reporter := progress.New(mode, os.Stderr, idleProgressDelay)
+	reporter.Start("")
+
 	// Parse stream concurrently
-	var output string
+	var transcript *Transcript
 	var parseErr error
 	var wg sync.WaitGroup
 	wg.Add(1)
 
-	// Track last activity time and spinner state
-	var lastActivityTime atomic.Int64
-	lastActivityTime.Store(time.Now().UnixNano())
-	var spinnerShown atomic.Bool
-	spinnerFrames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
-	var spinnerIdx atomic.Int32
-
 	go func() {
 		defer wg.Done()
 
@@ -144,89 +392,20 @@ func (bd *BaseDelegator) ExecuteCommand(ctx context.Context, args []string) (*De
 			if line == "" {
 				return
 			}
-
-			if spinnerShown.Load() {
-				fmt.Fprint(os.Stderr, "\b\b")
-				spinnerShown.Store(false)
-			}
-			fmt.Fprint(os.Stderr, line)
-			fmt.Fprint(os.Stderr, "\n")
-			os.Stderr.Sync()
-			lastActivityTime.Store(time.Now().UnixNano())
-		}
-
-		var renderer *StreamingMarkdownRenderer
-		if shouldUseColors() && bd.parserType != ParserTypeCodex {
-			renderer = NewStreamingMarkdownRenderer(lineHandler)
-			lineHandler = nil
-		}
-
-		var parser Parser
-		switch bd.parserType {
-		case ParserTypeCodex:
-			parser = NewCodexStreamParser(stdoutPipe, func(line string) {
-				if renderer != nil {
-					renderer.Write([]byte(line + "\n"))
-				} else if lineHandler != nil {
-					lineHandler(line)
-				}
-			})
-		default:
-			parser = NewStreamParser(stdoutPipe, func(line string) {
-				if renderer != nil {
-					renderer.Write([]byte(line + "\n"))
-				} else if lineHandler != nil {
-					lineHandler(line)
-				}
-			})
+			reporter.Clear()
+			fmt.Fprintln(out, line)
 		}
 
-		output, parseErr = parser.Parse()
-
-		if renderer != nil {
-			renderer.Flush()
-			renderer.Close()
-		}
-	}()
-
-	// Show spinner when idle for more than 2 seconds
-	spinnerTicker := time.NewTicker(300 * time.Millisecond)
-	defer spinnerTicker.Stop()
-
-	go func() {
-		for range spinnerTicker.C {
-			now := time.Now().UnixNano()
-			lastActivity := lastActivityTime.Load()
-			timeSinceActivity := time.Duration(now - lastActivity)
-
-			// Show spinner if idle for more than 2 seconds
-			if timeSinceActivity > 2*time.Second {
-				if !spinnerShown.Load() {
-					// First time showing spinner - add space and spinner
-					spinnerShown.Store(true)
-					fmt.Fprintf(os.Stderr, " %s", spinnerFrames[spinnerIdx.Load()%int32(len(spinnerFrames))])
-					os.Stderr.Sync()
-				} else {
-					// Update spinner frame
-					fmt.Fprintf(os.Stderr, "\b%s", spinnerFrames[spinnerIdx.Load()%int32(len(spinnerFrames))])
-					os.Stderr.Sync()
-				}
-				spinnerIdx.Add(1)
-			}
-		}
+		transcript, parseErr = bd.parseOutput(stdout, lineHandler, bd.output == nil && shouldUseColors())
 	}()
 
 	// Wait for streaming to complete
 	wg.Wait()
-
-	// Stop spinner ticker and clean up any remaining spinner
-	spinnerTicker.Stop()
-	if spinnerShown.Load() {
-		fmt.Fprint(os.Stderr, "\b\b") // Remove spinner
-	}
+	reporter.Stop()
 
 	// Wait for command to finish
 	cmdErr := cmd.Wait()
+	untrack()
 	duration := time.Since(start)
 
 	// Get exit code
@@ -238,6 +417,10 @@ func (bd *BaseDelegator) ExecuteCommand(ctx context.Context, args []string) (*De
 			exitCode = -1
 		}
 	}
+	logs.RecordExit(exitCode)
+
+	output := transcript.Output()
+	logs.WriteTranscript(output)
 
 	// Combine output with stderr if present
 	if stderr.Len() > 0 {
@@ -247,13 +430,14 @@ func (bd *BaseDelegator) ExecuteCommand(ctx context.Context, args []string) (*De
 		output += stderr.String()
 	}
 
-	// If parsing failed, return error
-	if parseErr != nil {
+	// If parsing failed, return error. A cancelled run's pipes can be closed
+	// under the parser, and its partial output is still worth returning.
+	if parseErr != nil && ctx.Err() == nil {
 		return nil, fmt.Errorf("stream parsing failed: %w", parseErr)
 	}
 
-	// Estimate tokens used (rough: ~4 chars per token)
-	tokensUsed := len(output) / 4
+	// Count output tokens with the tool's tokenizer
+	tokensUsed := tokenizer.ForTool(bd.toolType).Count(output)
 
 	// Build result
 	result := &DelegationResult{
@@ -263,19 +447,68 @@ func (bd *BaseDelegator) ExecuteCommand(ctx context.Context, args []string) (*De
 		Duration:   duration,
 		ToolName:   bd.toolName,
 		ExitCode:   exitCode,
+		SessionID:  transcript.SessionID(),
+		Activity:   transcript.Activity(),
+		Usage:      transcript.Usage(),
 	}
 
 	if cmdErr != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			result.Error = fmt.Sprintf("execution timeout after %v", bd.timeout)
-		} else {
-			result.Error = cmdErr.Error()
+		result.Error = cmdErr.Error()
+	}
+	bd.markCancelled(ctx, result)
+
+	if snapErr == nil {
+		if after, err := changes.Scan(bd.dir()); err == nil {
+			result.FilesChanged = changes.Compare(before, after)
 		}
 	}
 
 	return result, nil
 }
 
+// parseOutput parses a tool's stdout into events until it ends, passing the
+// transcript to onLine a line at a time, through the markdown renderer when
+// render is set
+func (bd *BaseDelegator) parseOutput(stdout io.Reader, onLine LineHandler, render bool) (*Transcript, error) {
+	var renderer *StreamingMarkdownRenderer
+	if render && bd.parserType != ParserTypeCodex {
+		renderer = NewStreamingMarkdownRenderer(onLine)
+	}
+
+	transcript := NewTranscript(func(line string) {
+		if renderer != nil {
+			renderer.Write([]byte(line + "\n"))
+		} else {
+			onLine(line)
+		}
+	})
+	emit := func(event *Event) {
+		event.Tool = bd.toolType
+		event.Time = time.Now()
+		transcript.Handle(event)
+		if bd.events != nil {
+			bd.events <- event
+		}
+	}
+
+	var parser Parser
+	switch bd.parserType {
+	case ParserTypeCodex:
+		parser = NewCodexStreamParser(stdout, emit)
+	default:
+		parser = NewStreamParser(stdout, emit)
+	}
+
+	err := parser.Parse()
+	transcript.Close()
+
+	if renderer != nil {
+		renderer.Flush()
+		renderer.Close()
+	}
+	return transcript, err
+}
+
 // ExecuteCommandSimple executes a command without streaming visual feedback
 // Used for council mode where we just want the final output without spinners
 func (bd *BaseDelegator) ExecuteCommandSimple(ctx context.Context, args []string) (*DelegationResult, error) {
@@ -284,18 +517,24 @@ func (bd *BaseDelegator) ExecuteCommandSimple(ctx context.Context, args []string
 	defer cancel()
 
 	// Prepare command
-	cmd := exec.CommandContext(ctx, bd.command, args...)
+	cmd := bd.prepareCommand(ctx, args)
 
-	// Capture both stdout and stderr
+	// Capture both stdout and stderr, and keep everything the tool prints
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	logs := bd.openLogs(args)
+	defer logs.Close()
+	cmd.Stdout = io.MultiWriter(&stdout, logs.stream)
+	cmd.Stderr = io.MultiWriter(&stderr, logs.stderr)
 
 	// Start timing
 	start := time.Now()
 
 	// Execute command (blocking)
-	err := cmd.Run()
+	untrack, err := startTracked(cmd)
+	if err == nil {
+		err = cmd.Wait()
+		untrack()
+	}
 	duration := time.Since(start)
 
 	// Get exit code
@@ -307,9 +546,11 @@ func (bd *BaseDelegator) ExecuteCommandSimple(ctx context.Context, args []string
 			exitCode = -1
 		}
 	}
+	logs.RecordExit(exitCode)
 
 	// Combine output
 	output := stdout.String()
+	logs.WriteTranscript(output)
 	if stderr.Len() > 0 {
 		if len(output) > 0 {
 			output += "\n"
@@ -317,8 +558,8 @@ func (bd *BaseDelegator) ExecuteCommandSimple(ctx context.Context, args []string
 		output += stderr.String()
 	}
 
-	// Estimate tokens used
-	tokensUsed := len(output) / 4
+	// Count output tokens with the tool's tokenizer
+	tokensUsed := tokenizer.ForTool(bd.toolType).Count(output)
 
 	// Build result
 	result := &DelegationResult{
@@ -331,22 +572,34 @@ func (bd *BaseDelegator) ExecuteCommandSimple(ctx context.Context, args []string
 	}
 
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			result.Error = fmt.Sprintf("execution timeout after %v", bd.timeout)
-		} else {
-			result.Error = err.Error()
-		}
+		result.Error = err.Error()
 	}
+	bd.markCancelled(ctx, result)
 
 	return result, nil
 }
 
-// EstimateTokens estimates tokens from text (rough: ~4 characters per token)
-func EstimateTokens(text string) int {
-	if len(text) == 0 {
-		return 0
+// markCancelled records why a run stopped early, if it did. ctx is the
+// run's context, which carries the timeout and the caller's cancellation.
+func (bd *BaseDelegator) markCancelled(ctx context.Context, result *DelegationResult) {
+	switch {
+	case ctx.Err() == nil, result.Success:
+		return // Finished before it could be stopped
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.CancelReason = fmt.Sprintf("execution timeout after %v", bd.timeout)
+	case context.Cause(ctx) != nil && context.Cause(ctx) != context.Canceled:
+		result.CancelReason = context.Cause(ctx).Error()
+	default:
+		result.CancelReason = "cancelled"
 	}
-	return len(text) / 4
+	result.Cancelled = true
+	result.Success = false
+	result.Error = result.CancelReason
+}
+
+// EstimateTokens estimates tokens from text with the default tokenizer
+func EstimateTokens(text string) int {
+	return tokenizer.Default().Count(text)
 }
 
 // FormatDuration formats a duration as a human-readable string
@@ -362,12 +615,17 @@ func FormatDuration(d time.Duration) string {
 	return fmt.Sprintf("%dm%ds", minutes, seconds)
 }
 
-// TruncateOutput truncates output to a maximum length
+// TruncateOutput truncates output to at most maxLength bytes, without
+// splitting a multi-byte character
 func TruncateOutput(output string, maxLength int) string {
 	if len(output) <= maxLength {
 		return output
 	}
-	return output[:maxLength] + "... (truncated)"
+	cut := maxLength
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return output[:cut] + "... (truncated)"
 }
 
 // shouldUseColors checks if terminal supports colors and formatting
@@ -0,0 +1,179 @@
+package delegators
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/artifacts"
+	"github.com/crlian/ai-dispatcher/pkg/changes"
+)
+
+func TestExecuteCommandFilesChanged(t *testing.T) {
+	type fileWant struct {
+		status         changes.Status
+		added, removed int
+		uncounted      bool
+	}
+	tests := []struct {
+		name string
+		git  bool
+		want map[string]fileWant
+	}{
+		{
+			name: "git repository",
+			git:  true,
+			want: map[string]fileWant{
+				"edit.txt": {status: changes.Modified, added: 2, removed: 1},
+				"gone.txt": {status: changes.Deleted, removed: 1},
+				"new.txt":  {status: changes.Created, added: 1},
+			},
+		},
+		{
+			// Without git the scan keeps the earlier content
+			name: "plain directory",
+			want: map[string]fileWant{
+				"edit.txt": {status: changes.Modified, added: 2, removed: 1},
+				"gone.txt": {status: changes.Deleted, removed: 1},
+				"new.txt":  {status: changes.Created, added: 1},
+			},
+		},
+	}
+
+	script := "printf 'one\\nTWO\\nthree\\nfour\\n' > edit.txt && rm gone.txt && echo new > new.txt && echo unchanged > same.txt"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.git {
+				if _, err := exec.LookPath("git"); err != nil {
+					t.Skip("git not installed")
+				}
+				if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+					t.Fatalf("git init: %v: %s", err, out)
+				}
+			}
+			writeFile(t, dir, "edit.txt", "one\ntwo\nthree\n")
+			writeFile(t, dir, "gone.txt", "bye\n")
+			writeFile(t, dir, "same.txt", "unchanged\n")
+			if tt.git {
+				if out, err := exec.Command("git", "-C", dir, "add", ".").CombinedOutput(); err != nil {
+					t.Fatalf("git add: %v: %s", err, out)
+				}
+			}
+
+			bd := NewBaseDelegator("Shell", "shell", "sh")
+			bd.SetWorkDir(dir)
+			result, err := bd.ExecuteCommand(context.Background(), []string{"-c", script})
+			if err != nil {
+				t.Fatalf("ExecuteCommand() error = %v", err)
+			}
+			if !result.Success {
+				t.Fatalf("command failed: %s", result.Output)
+			}
+
+			want := tt.want
+			if len(result.FilesChanged) != len(want) {
+				t.Fatalf("FilesChanged has %d files, want %d: %+v", len(result.FilesChanged), len(want), result.FilesChanged)
+			}
+			for _, f := range result.FilesChanged {
+				w, ok := want[f.Path]
+				if !ok {
+					t.Errorf("unexpected change to %s", f.Path)
+					continue
+				}
+				if f.Status != w.status || f.Added != w.added || f.Removed != w.removed || f.Uncounted != w.uncounted {
+					t.Errorf("%s: got %s +%d -%d (uncounted %v), want %s +%d -%d (uncounted %v)",
+						f.Path, f.Status, f.Added, f.Removed, f.Uncounted, w.status, w.added, w.removed, w.uncounted)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteCommandLogs(t *testing.T) {
+	logDir := t.TempDir()
+	bd := NewBaseDelegator("Shell", "shell", "sh")
+	bd.SetWorkDir(t.TempDir())
+	bd.SetLogDir(logDir)
+
+	script := `printf '%s\n' '{"type":"result","result":"## Done\n\nAll fixed."}'; echo 'warning: slow' >&2`
+	if _, err := bd.ExecuteCommand(context.Background(), []string{"-c", script}); err != nil {
+		t.Fatalf("ExecuteCommand() error = %v", err)
+	}
+
+	want := map[string]string{
+		artifacts.StreamFile:     `{"type":"result","result":"## Done\n\nAll fixed."}` + "\n",
+		artifacts.StderrFile:     "warning: slow\n",
+		artifacts.TranscriptFile: "## Done\n\nAll fixed.\n",
+	}
+	for name, content := range want {
+		got, err := artifacts.Read(logDir, name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		max    int
+		want   string
+	}{
+		{"short", "hello", 10, "hello"},
+		{"ascii", "hello world", 5, "hello... (truncated)"},
+		{"multi-byte rune at the cut", "añb", 2, "a... (truncated)"},
+		{"emoji", "ok 🚀 go", 5, "ok ... (truncated)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TruncateOutput(tt.output, tt.max); got != tt.want {
+				t.Errorf("TruncateOutput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckExtraArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		delegator *BaseDelegator
+		args      []string
+		wantErr   bool
+	}{
+		{name: "claude model", delegator: NewClaudeCodeDelegator().BaseDelegator, args: []string{"--model", "sonnet"}},
+		{name: "claude permission mode", delegator: NewClaudeCodeDelegator().BaseDelegator, args: []string{"--permission-mode", "bypassPermissions"}, wantErr: true},
+		{name: "claude skip permissions", delegator: NewClaudeCodeDelegator().BaseDelegator, args: []string{"--dangerously-skip-permissions"}, wantErr: true},
+		{name: "claude allowed tools", delegator: NewClaudeCodeDelegator().BaseDelegator, args: []string{"--allowedTools=Bash"}, wantErr: true},
+		{name: "codex config", delegator: NewCodexDelegator().BaseDelegator, args: []string{"-c", "model_reasoning_effort=high"}},
+		{name: "codex sandbox", delegator: NewCodexDelegator().BaseDelegator, args: []string{"-s", "danger-full-access"}, wantErr: true},
+		{name: "codex bypass", delegator: NewCodexDelegator().BaseDelegator, args: []string{"--dangerously-bypass-approvals-and-sandbox"}, wantErr: true},
+		{name: "codex sandbox setting", delegator: NewCodexDelegator().BaseDelegator, args: []string{"-c", "sandbox_mode=danger-full-access"}, wantErr: true},
+		{name: "codex nested setting", delegator: NewCodexDelegator().BaseDelegator, args: []string{"--config=sandbox_workspace_write.network_access=true"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.delegator.SetExtraArgs(tt.args)
+			if err := tt.delegator.checkExtraArgs(); (err != nil) != tt.wantErr {
+				t.Errorf("checkExtraArgs(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,78 @@
+package delegators
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// essentialEnv lists the variables passed on even when an allowlist leaves
+// them out, since tools can't start or find their login without them
+var essentialEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "LANG", "LC_*", "TMPDIR", "TZ"}
+
+// Environment controls the variables a tool runs with. Patterns are globs
+// matched against variable names, like AWS_*.
+type Environment struct {
+	Set   map[string]string // Variables set for the tool, whatever the rules
+	Allow []string          // Only matching variables are passed on (all when empty)
+	Deny  []string          // Matching variables are never passed on
+}
+
+// ParseEnvAssignment splits a KEY=VALUE assignment
+func ParseEnvAssignment(assignment string) (string, string, error) {
+	key, value, ok := strings.Cut(assignment, "=")
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("invalid environment variable %q: must be KEY=VALUE", assignment)
+	}
+	return key, value, nil
+}
+
+// ValidatePatterns checks that every pattern is a valid glob
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid environment pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Apply returns the environment a tool gets when the dispatcher's is base
+// (as from os.Environ)
+func (e *Environment) Apply(base []string) []string {
+	env := make([]string, 0, len(base)+len(e.Set))
+	for _, entry := range base {
+		name, _, _ := strings.Cut(entry, "=")
+		if _, set := e.Set[name]; set {
+			continue
+		}
+		if len(e.Allow) > 0 && !matchesAny(name, e.Allow) && !matchesAny(name, essentialEnv) {
+			continue
+		}
+		if matchesAny(name, e.Deny) {
+			continue
+		}
+		env = append(env, entry)
+	}
+
+	names := make([]string, 0, len(e.Set))
+	for name := range e.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+e.Set[name])
+	}
+	return env
+}
+
+// matchesAny reports whether a variable name matches one of the patterns
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package delegators
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentApply(t *testing.T) {
+	base := []string{"PATH=/usr/bin", "HOME=/home/dev", "AWS_SECRET_ACCESS_KEY=s3cr3t", "AWS_REGION=eu-west-1", "OPENAI_API_KEY=sk", "GOFLAGS=-mod=mod"}
+
+	tests := []struct {
+		name string
+		env  *Environment
+		want []string
+	}{
+		{
+			name: "no rules",
+			env:  &Environment{},
+			want: base,
+		},
+		{
+			name: "deny",
+			env:  &Environment{Deny: []string{"AWS_*"}},
+			want: []string{"PATH=/usr/bin", "HOME=/home/dev", "OPENAI_API_KEY=sk", "GOFLAGS=-mod=mod"},
+		},
+		{
+			name: "allow keeps the essentials",
+			env:  &Environment{Allow: []string{"OPENAI_API_KEY", "AWS_*"}, Deny: []string{"AWS_SECRET_*"}},
+			want: []string{"PATH=/usr/bin", "HOME=/home/dev", "AWS_REGION=eu-west-1", "OPENAI_API_KEY=sk"},
+		},
+		{
+			name: "set overrides and survives the rules",
+			env:  &Environment{Set: map[string]string{"GOFLAGS": "-mod=vendor", "AWS_PROFILE": "dev"}, Deny: []string{"GOFLAGS", "AWS_*"}},
+			want: []string{"PATH=/usr/bin", "HOME=/home/dev", "OPENAI_API_KEY=sk", "AWS_PROFILE=dev", "GOFLAGS=-mod=vendor"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.env.Apply(base); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEnvAssignment(t *testing.T) {
+	tests := []struct {
+		assignment string
+		key, value string
+		wantErr    bool
+	}{
+		{assignment: "FOO=bar", key: "FOO", value: "bar"},
+		{assignment: "URL=http://x?a=b", key: "URL", value: "http://x?a=b"},
+		{assignment: "EMPTY=", key: "EMPTY"},
+		{assignment: "FOO", wantErr: true},
+		{assignment: "=bar", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		key, value, err := ParseEnvAssignment(tt.assignment)
+		if (err != nil) != tt.wantErr || key != tt.key || value != tt.value {
+			t.Errorf("ParseEnvAssignment(%q) = %q, %q, %v", tt.assignment, key, value, err)
+		}
+	}
+}
+
+func TestExecuteCommandEnvironment(t *testing.T) {
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "s3cr3t")
+	bd := NewBaseDelegator("Shell", "shell", "sh")
+	bd.SetWorkDir(t.TempDir())
+	bd.SetOutput(&strings.Builder{})
+	bd.SetEnvironment(&Environment{Set: map[string]string{"TASK_ID": "42"}, Deny: []string{"AWS_*"}})
+
+	result, err := bd.ExecuteCommand(context.Background(), []string{"-c", `echo "id=$TASK_ID secret=$AWS_SECRET_ACCESS_KEY"`})
+	if err != nil {
+		t.Fatalf("ExecuteCommand() error = %v", err)
+	}
+	if result.Output != "id=42 secret=\n" {
+		t.Errorf("Output = %q, want the set variable and not the denied one", result.Output)
+	}
+}
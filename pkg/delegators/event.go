@@ -0,0 +1,233 @@
+package delegators
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// EventType is the kind of an Event
+type EventType string
+
+const (
+	EventText      EventType = "text"      // A piece of the tool's reply
+	EventReasoning EventType = "reasoning" // The model's reasoning
+	EventToolCall  EventType = "tool_call" // A tool the model called (Read, Edit, ...)
+	EventCommand   EventType = "command"   // A shell command started or finished
+	EventFileEdit  EventType = "file_edit" // A file the tool changed
+	EventUsage     EventType = "usage"     // Tokens used, as reported by the tool
+	EventError     EventType = "error"     // An error the tool reported
+	EventSession   EventType = "session"   // The session a follow-up can resume
+	EventResult    EventType = "result"    // The tool's final answer
+)
+
+// Command statuses
+const (
+	CommandStarted   = "started"
+	CommandCompleted = "completed"
+)
+
+// Event is something a tool reported while running. Which fields are set
+// depends on the type.
+type Event struct {
+	Type      EventType         `json:"type"`
+	Tool      trackers.ToolType `json:"tool"`
+	Time      time.Time         `json:"time"`
+	ID        string            `json:"id,omitempty"`        // Pairs a command's start and finish
+	Text      string            `json:"text,omitempty"`      // text, reasoning, error, result
+	Name      string            `json:"name,omitempty"`      // tool_call
+	Input     json.RawMessage   `json:"input,omitempty"`     // tool_call
+	Command   string            `json:"command,omitempty"`   // command
+	Status    string            `json:"status,omitempty"`    // command: started or completed
+	ExitCode  *int              `json:"exit_code,omitempty"` // command, once completed
+	Output    string            `json:"output,omitempty"`    // command, once completed
+	Path      string            `json:"path,omitempty"`      // file_edit
+	Kind      string            `json:"kind,omitempty"`      // file_edit: add, update or delete
+	Usage     *Usage            `json:"usage,omitempty"`     // usage
+	SessionID string            `json:"session_id,omitempty"`
+}
+
+// EventHandler receives the events of a stream as they are parsed
+type EventHandler func(event *Event)
+
+// Usage is the token usage a tool reports. Input tokens include the cached
+// ones, as Codex counts them.
+type Usage struct {
+	InputTokens       int `json:"input_tokens"`
+	CachedInputTokens int `json:"cached_input_tokens,omitempty"`
+	OutputTokens      int `json:"output_tokens"`
+}
+
+// Total returns every token the run used, read and written
+func (u *Usage) Total() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// minReasoningShown is the length below which reasoning isn't shown, to
+// leave out short planning notes
+const minReasoningShown = 200
+
+// Transcript turns a run's events into the text shown for them, and
+// collects the session, activity and usage they report. Text runs together
+// into lines until an event of another kind arrives.
+type Transcript struct {
+	onLine    LineHandler
+	line      strings.Builder
+	output    strings.Builder
+	shownText bool
+	sessionID string
+	activity  Activity
+	commands  map[string]*CommandRun // Started commands by event ID
+	usage     *Usage
+}
+
+// NewTranscript creates a transcript that passes each complete line to onLine
+func NewTranscript(onLine LineHandler) *Transcript {
+	if onLine == nil {
+		onLine = func(line string) {}
+	}
+	return &Transcript{onLine: onLine, commands: make(map[string]*CommandRun)}
+}
+
+// Handle adds an event to the transcript
+func (t *Transcript) Handle(event *Event) {
+	if event.Type != EventText {
+		t.endLine()
+	}
+
+	switch event.Type {
+	case EventText:
+		t.shownText = true
+		t.write(event.Text)
+
+	case EventReasoning:
+		t.activity.Reasoning = append(t.activity.Reasoning, event.Text)
+		if len(event.Text) > minReasoningShown {
+			t.write(ensureNewline(event.Text))
+		}
+
+	case EventCommand:
+		cmd := t.commandRun(event)
+		if event.Status != CommandCompleted {
+			t.write("$ " + event.Command + "\n")
+			return
+		}
+		cmd.ExitCode = event.ExitCode
+		cmd.Output = event.Output
+		if event.Output != "" {
+			t.write(ensureNewline(event.Output))
+		}
+
+	case EventFileEdit:
+		t.activity.FileEdits = append(t.activity.FileEdits, &FileEdit{Path: event.Path, Kind: event.Kind})
+		t.write("✎ " + event.Kind + " " + event.Path + "\n")
+
+	case EventUsage:
+		if event.Usage == nil {
+			return
+		}
+		if t.usage == nil {
+			t.usage = &Usage{}
+		}
+		t.usage.InputTokens += event.Usage.InputTokens
+		t.usage.CachedInputTokens += event.Usage.CachedInputTokens
+		t.usage.OutputTokens += event.Usage.OutputTokens
+
+	case EventError:
+		t.activity.Errors = append(t.activity.Errors, event.Text)
+		t.write("[Error] " + event.Text + "\n")
+
+	case EventSession:
+		t.sessionID = event.SessionID
+
+	case EventResult:
+		t.activity.FinalMessage = event.Text
+		// The answer usually was streamed as text already
+		if !t.shownText {
+			t.write(ensureNewline(event.Text))
+		}
+	}
+}
+
+// Close passes on the last line if it wasn't ended
+func (t *Transcript) Close() {
+	if t.line.Len() == 0 {
+		return
+	}
+	line := t.line.String()
+	t.line.Reset()
+	t.onLine(line)
+	t.output.WriteString(line)
+}
+
+// Output returns the text shown so far
+func (t *Transcript) Output() string {
+	return t.output.String()
+}
+
+// SessionID returns the last session reported, if any
+func (t *Transcript) SessionID() string {
+	return t.sessionID
+}
+
+// Activity returns the commands, file edits, reasoning, final message and
+// errors reported, or nil when none were
+func (t *Transcript) Activity() *Activity {
+	a := &t.activity
+	if len(a.Commands) == 0 && len(a.FileEdits) == 0 && len(a.Reasoning) == 0 && a.FinalMessage == "" && len(a.Errors) == 0 {
+		return nil
+	}
+	return a
+}
+
+// Usage returns the total token usage reported, or nil when none was
+func (t *Transcript) Usage() *Usage {
+	return t.usage
+}
+
+// write passes each line text completes to onLine
+func (t *Transcript) write(text string) {
+	for {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			t.line.WriteString(text)
+			return
+		}
+		t.line.WriteString(text[:i])
+		line := t.line.String()
+		t.line.Reset()
+		t.onLine(line)
+		t.output.WriteString(line)
+		t.output.WriteString("\n")
+		text = text[i+1:]
+	}
+}
+
+// endLine ends a line of text left unfinished
+func (t *Transcript) endLine() {
+	if t.line.Len() > 0 {
+		t.write("\n")
+	}
+}
+
+// commandRun returns the command an event is about, recording it the first
+// time it is seen
+func (t *Transcript) commandRun(event *Event) *CommandRun {
+	if cmd, ok := t.commands[event.ID]; ok && event.ID != "" {
+		return cmd
+	}
+	cmd := &CommandRun{Command: event.Command}
+	t.commands[event.ID] = cmd
+	t.activity.Commands = append(t.activity.Commands, cmd)
+	return cmd
+}
+
+// ensureNewline ends text with a newline, so blocks don't run together
+func ensureNewline(text string) string {
+	if strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
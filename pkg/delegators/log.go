@@ -0,0 +1,93 @@
+package delegators
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/crlian/ai-dispatcher/pkg/artifacts"
+)
+
+// runLogs are the artifact files a run's output is written to, and the
+// recording of the run. Writers of logs that aren't kept discard what
+// they're given.
+type runLogs struct {
+	stream     io.Writer
+	stderr     io.Writer
+	transcript io.Writer
+	recorder   *Recorder
+	files      []*os.File
+}
+
+// openLogs opens the run's logs in the artifacts directory and its
+// recording, if they are set. Logs are a record, not part of the run, so one
+// that can't be opened is skipped with a warning.
+func (bd *BaseDelegator) openLogs(args []string) *runLogs {
+	logs := &runLogs{stream: io.Discard, stderr: io.Discard, transcript: io.Discard}
+	if bd.logDir != "" {
+		logs.openArtifacts(bd.logDir)
+	}
+
+	if bd.recording != "" {
+		file, err := OpenRecording(bd.recording)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			return logs
+		}
+		logs.files = append(logs.files, file)
+		logs.recorder = NewRecorder(file, bd.toolType, bd.toolName, bd.parserType, bd.command, args)
+		logs.stream = io.MultiWriter(logs.stream, logs.recorder.Stdout())
+		logs.stderr = io.MultiWriter(logs.stderr, logs.recorder.Stderr())
+	}
+	return logs
+}
+
+// openArtifacts opens the logs in the artifacts directory
+func (l *runLogs) openArtifacts(dir string) {
+	for _, log := range []struct {
+		name string
+		w    *io.Writer
+	}{
+		{artifacts.StreamFile, &l.stream},
+		{artifacts.StderrFile, &l.stderr},
+		{artifacts.TranscriptFile, &l.transcript},
+	} {
+		file, err := artifacts.OpenLog(dir, log.name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		*log.w = file
+		l.files = append(l.files, file)
+	}
+}
+
+// RecordExit ends the recording with the tool's exit code
+func (l *runLogs) RecordExit(code int) {
+	if l.recorder == nil {
+		return
+	}
+	l.recorder.Exit(code)
+	if err := l.recorder.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write recording: %v\n", err)
+	}
+}
+
+// WriteTranscript appends the parsed output of an attempt to the transcript
+func (l *runLogs) WriteTranscript(output string) {
+	if output == "" {
+		return
+	}
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	io.WriteString(l.transcript, output)
+}
+
+// Close closes the log files
+func (l *runLogs) Close() {
+	for _, file := range l.files {
+		file.Close()
+	}
+}
@@ -23,13 +23,25 @@ func NewOpenCodeDelegator() *OpenCodeDelegator {
 	}
 }
 
-// Execute runs a task using OpenCode
+// Execute runs a task using OpenCode. OpenCode has no permission flags, so
+// only full access can be requested; it runs with its own config otherwise.
 func (ocd *OpenCodeDelegator) Execute(ctx context.Context, task string) (*DelegationResult, error) {
+	if _, err := ocd.permissionProfile(); err != nil {
+		return nil, fmt.Errorf("opencode execution failed: %w", err)
+	}
+
+	inline, files := ocd.attachmentArgs()
+
 	// Build command arguments
 	args := []string{
 		"run",
-		task,
+		withAttachments(task, inline, nil),
 	}
+	args = append(args, files...)
+	if ocd.session != "" {
+		args = append(args, "--session", ocd.session)
+	}
+	args = append(args, ocd.extraArgs...)
 
 	// Execute command
 	result, err := ocd.ExecuteCommand(ctx, args)
@@ -44,10 +56,13 @@ func (ocd *OpenCodeDelegator) Execute(ctx context.Context, task string) (*Delega
 func (ocd *OpenCodeDelegator) Query(ctx context.Context, prompt string) (string, error) {
 	// OpenCode might have a chat or ask mode
 	// For now, we'll use run with a modified prompt that asks for plan only
+	inline, files := ocd.attachmentArgs()
 	args := []string{
 		"run",
-		prompt + "\n\nIMPORTANT: Only describe your approach. Do NOT modify any files.",
+		withAttachments(prompt, inline, nil) + "\n\nIMPORTANT: Only describe your approach. Do NOT modify any files.",
 	}
+	args = append(args, files...)
+	args = append(args, ocd.extraArgs...)
 
 	// Execute command WITHOUT streaming (clean output for council chat)
 	result, err := ocd.ExecuteCommandSimple(ctx, args)
@@ -57,3 +72,14 @@ func (ocd *OpenCodeDelegator) Query(ctx context.Context, prompt string) (string,
 
 	return result.Output, nil
 }
+
+// attachmentArgs returns the attachments embedded in the prompt and the
+// --file flags for the rest (OpenCode attaches files itself)
+func (ocd *OpenCodeDelegator) attachmentArgs() ([]*Attachment, []string) {
+	inline, files := ocd.splitAttachments(func(*Attachment) bool { return true })
+	var args []string
+	for _, a := range files {
+		args = append(args, "--file", a.Path)
+	}
+	return inline, args
+}
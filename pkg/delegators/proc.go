@@ -0,0 +1,38 @@
+package delegators
+
+import (
+	"os/exec"
+	"sync"
+)
+
+// running holds the tools' processes while they run, so they can be killed
+// when the dispatcher is made to exit without waiting for them to stop
+var running = struct {
+	sync.Mutex
+	cmds map[*exec.Cmd]bool
+}{cmds: make(map[*exec.Cmd]bool)}
+
+// startTracked starts cmd and tracks it until the returned function is called
+func startTracked(cmd *exec.Cmd) (func(), error) {
+	running.Lock()
+	defer running.Unlock()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	running.cmds[cmd] = true
+	return func() {
+		running.Lock()
+		defer running.Unlock()
+		delete(running.cmds, cmd)
+	}, nil
+}
+
+// KillRunning kills every running tool and the processes it started, right
+// away. Cancelling the context of a run stops its tool gracefully instead.
+func KillRunning() {
+	running.Lock()
+	defer running.Unlock()
+	for cmd := range running.cmds {
+		killProcessGroup(cmd)
+	}
+}
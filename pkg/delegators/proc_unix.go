@@ -0,0 +1,33 @@
+//go:build !windows
+
+package delegators
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup runs the command in its own process group, so cancelling
+// it also stops the processes it started (node workers, shells, test runs).
+// Cancellation sends SIGTERM to the group, then SIGKILL after KillGracePeriod.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+			return cmd.Process.Kill()
+		}
+		time.AfterFunc(KillGracePeriod, func() {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		return nil
+	}
+	// Stop waiting for output held open by processes that ignore both signals
+	cmd.WaitDelay = KillGracePeriod + time.Second
+}
+
+// killProcessGroup sends SIGKILL to the command's process group
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
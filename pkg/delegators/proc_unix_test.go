@@ -0,0 +1,94 @@
+//go:build !windows
+
+package delegators
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecuteCommandCancelled(t *testing.T) {
+	tests := []struct {
+		name       string
+		timeout    time.Duration
+		cancel     error
+		wantReason string
+	}{
+		{"interrupted", time.Minute, errors.New("interrupted by SIGINT"), "interrupted by SIGINT"},
+		{"timed out", 300 * time.Millisecond, nil, "execution timeout after 300ms"},
+	}
+
+	// The background sleep stands in for a tool's worker process
+	script := "sleep 30 & echo $! > child.pid; echo started; wait"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			bd := NewBaseDelegator("Shell", "shell", "sh")
+			bd.SetWorkDir(dir)
+			bd.SetTimeout(tt.timeout)
+
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			if tt.cancel != nil {
+				time.AfterFunc(300*time.Millisecond, func() { cancel(tt.cancel) })
+			}
+
+			start := time.Now()
+			result, err := bd.ExecuteCommand(ctx, []string{"-c", script})
+			if err != nil {
+				t.Fatalf("ExecuteCommand() error = %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > KillGracePeriod {
+				t.Errorf("took %v to stop", elapsed)
+			}
+
+			if !result.Cancelled || result.Success {
+				t.Errorf("Cancelled = %v, Success = %v, want cancelled and failed", result.Cancelled, result.Success)
+			}
+			if result.CancelReason != tt.wantReason {
+				t.Errorf("CancelReason = %q, want %q", result.CancelReason, tt.wantReason)
+			}
+			if !strings.Contains(result.Output, "started") {
+				t.Errorf("partial output lost: %q", result.Output)
+			}
+			if result.Duration <= 0 {
+				t.Errorf("Duration = %v, want the elapsed time", result.Duration)
+			}
+
+			data, err := os.ReadFile(filepath.Join(dir, "child.pid"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The child is killed with its group, though reaping it can lag
+			deadline := time.Now().Add(2 * time.Second)
+			for syscall.Kill(pid, 0) == nil && !isZombie(pid) {
+				if time.Now().After(deadline) {
+					t.Fatalf("child process %d is still running", pid)
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+		})
+	}
+}
+
+// isZombie reports whether a process has exited but not been reaped yet
+func isZombie(pid int) bool {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(data))
+	return len(fields) > 2 && fields[2] == "Z"
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package delegators
+
+import (
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup kills the command when it is cancelled. Windows has no
+// process groups to signal, so processes it started may outlive it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = KillGracePeriod + time.Second
+}
+
+// killProcessGroup kills the command; the processes it started are left
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,309 @@
+package delegators
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/crlian/ai-dispatcher/pkg/tokenizer"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// A recording holds the sessions of a tool as JSON lines: a start line with
+// the command, then every chunk of stdout and stderr as the tool wrote it,
+// timed from the start, and an exit line with the exit code.
+const (
+	recordStart  = "start"
+	recordStdout = "stdout"
+	recordStderr = "stderr"
+	recordExit   = "exit"
+)
+
+// recordLine is one line of a recording
+type recordLine struct {
+	Type string  `json:"type"`
+	Time float64 `json:"t"` // Seconds since the start
+
+	// start
+	Tool     trackers.ToolType `json:"tool,omitempty"`
+	ToolName string            `json:"tool_name,omitempty"`
+	Parser   string            `json:"parser,omitempty"`
+	Command  string            `json:"command,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Started  *time.Time        `json:"started,omitempty"`
+
+	// stdout and stderr: text, or the bytes when they aren't valid UTF-8
+	Text  string `json:"text,omitempty"`
+	Bytes []byte `json:"bytes,omitempty"`
+
+	// exit
+	ExitCode *int `json:"exit_code,omitempty"`
+}
+
+// Recording is one recorded session of a tool
+type Recording struct {
+	Tool     trackers.ToolType
+	ToolName string
+	Parser   string
+	Command  string
+	Args     []string
+	Started  time.Time
+	Chunks   []*RecordedChunk
+	ExitCode int
+	Duration time.Duration // Until the tool exited
+}
+
+// RecordedChunk is output the tool wrote at once
+type RecordedChunk struct {
+	Offset time.Duration // Since the start
+	Stream string        // stdout or stderr
+	Data   []byte
+}
+
+// Recorder writes a session to a recording as it happens
+type Recorder struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	err   error
+}
+
+// NewRecorder starts recording a session of the tool's command to w
+func NewRecorder(w io.Writer, toolType trackers.ToolType, toolName, parser, command string, args []string) *Recorder {
+	r := &Recorder{enc: json.NewEncoder(w), start: time.Now()}
+	r.write(&recordLine{
+		Type:     recordStart,
+		Tool:     toolType,
+		ToolName: toolName,
+		Parser:   parser,
+		Command:  command,
+		Args:     args,
+		Started:  &r.start,
+	})
+	return r
+}
+
+// Stdout returns a writer that records what the tool writes to stdout
+func (r *Recorder) Stdout() io.Writer {
+	return &recordWriter{r, recordStdout}
+}
+
+// Stderr returns a writer that records what the tool writes to stderr
+func (r *Recorder) Stderr() io.Writer {
+	return &recordWriter{r, recordStderr}
+}
+
+// Exit records the tool's exit code, ending the session
+func (r *Recorder) Exit(code int) {
+	r.write(&recordLine{Type: recordExit, ExitCode: &code})
+}
+
+// Err returns the first error writing the recording
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// write stamps a line with its time and appends it. stdout and stderr are
+// written from different goroutines.
+func (r *Recorder) write(line *recordLine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	line.Time = time.Since(r.start).Seconds()
+	if line.Type == recordStart {
+		line.Time = 0
+	}
+	r.err = r.enc.Encode(line)
+}
+
+// recordWriter records the chunks written to one stream
+type recordWriter struct {
+	recorder *Recorder
+	stream   string
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	line := &recordLine{Type: w.stream}
+	if utf8.Valid(p) {
+		line.Text = string(p)
+	} else {
+		line.Bytes = append([]byte(nil), p...)
+	}
+	w.recorder.write(line)
+	return len(p), nil
+}
+
+// OpenRecording opens a recording file for appending, creating it if needed
+func OpenRecording(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	return file, nil
+}
+
+// ReadRecordings reads the sessions of a recording, in the order they ran
+func ReadRecordings(r io.Reader) ([]*Recording, error) {
+	var recordings []*Recording
+	var current *Recording
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var line recordLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("invalid recording at line %d: %w", n, err)
+		}
+		offset := time.Duration(line.Time * float64(time.Second))
+
+		if line.Type == recordStart {
+			current = &Recording{
+				Tool:     line.Tool,
+				ToolName: line.ToolName,
+				Parser:   line.Parser,
+				Command:  line.Command,
+				Args:     line.Args,
+			}
+			if line.Started != nil {
+				current.Started = *line.Started
+			}
+			recordings = append(recordings, current)
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("invalid recording at line %d: no session started", n)
+		}
+
+		switch line.Type {
+		case recordStdout, recordStderr:
+			data := line.Bytes
+			if data == nil {
+				data = []byte(line.Text)
+			}
+			current.Chunks = append(current.Chunks, &RecordedChunk{Offset: offset, Stream: line.Type, Data: data})
+			current.Duration = offset
+		case recordExit:
+			if line.ExitCode != nil {
+				current.ExitCode = *line.ExitCode
+			}
+			current.Duration = offset
+		default:
+			return nil, fmt.Errorf("invalid recording at line %d: unknown type %q", n, line.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	if len(recordings) == 0 {
+		return nil, fmt.Errorf("the recording has no sessions")
+	}
+	return recordings, nil
+}
+
+// ReplayOptions controls how a recording is replayed
+type ReplayOptions struct {
+	Speed  float64       // 1 keeps the recorded pace, 2 is twice as fast, 0 doesn't pause
+	Output io.Writer     // Where the transcript is printed (discarded when nil)
+	Stderr io.Writer     // Where the recorded stderr is printed (discarded when nil)
+	Render bool          // Render markdown, as a run on a terminal does
+	Events chan<- *Event // Where the parsed events are sent, as in SetEvents
+}
+
+// Replay runs the recorded output through the same parser and renderer as
+// the tool's run, at the recorded pace scaled by Speed. The result is the
+// one the run had, timed as recorded.
+func (rec *Recording) Replay(ctx context.Context, opts ReplayOptions) (*DelegationResult, error) {
+	bd := NewBaseDelegator(rec.ToolName, rec.Tool, rec.Command)
+	bd.parserType = rec.Parser
+	bd.events = opts.Events
+
+	output, stderrOut := opts.Output, opts.Stderr
+	if output == nil {
+		output = io.Discard
+	}
+	if stderrOut == nil {
+		stderrOut = io.Discard
+	}
+
+	// Feed the parser the recorded stdout as the tool wrote it
+	stdout, feed := io.Pipe()
+	var stderr bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		start := time.Now()
+		for _, chunk := range rec.Chunks {
+			if opts.Speed > 0 {
+				wait := time.Duration(float64(chunk.Offset)/opts.Speed) - time.Since(start)
+				select {
+				case <-ctx.Done():
+				case <-time.After(wait):
+				}
+			}
+			if ctx.Err() != nil {
+				feed.CloseWithError(ctx.Err())
+				return
+			}
+
+			if chunk.Stream == recordStderr {
+				stderr.Write(chunk.Data)
+				stderrOut.Write(chunk.Data)
+			} else if _, err := feed.Write(chunk.Data); err != nil {
+				return // The parser stopped reading
+			}
+		}
+		feed.Close()
+	}()
+
+	transcript, parseErr := bd.parseOutput(stdout, func(line string) {
+		if line != "" {
+			fmt.Fprintln(output, line)
+		}
+	}, opts.Render)
+	stdout.Close()
+	<-done
+
+	if parseErr != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf("stream parsing failed: %w", parseErr)
+	}
+
+	result := &DelegationResult{
+		Success:   rec.ExitCode == 0,
+		Output:    transcript.Output(),
+		Duration:  rec.Duration,
+		ToolName:  rec.ToolName,
+		ExitCode:  rec.ExitCode,
+		SessionID: transcript.SessionID(),
+		Activity:  transcript.Activity(),
+		Usage:     transcript.Usage(),
+	}
+	if stderr.Len() > 0 {
+		if len(result.Output) > 0 {
+			result.Output += "\n"
+		}
+		result.Output += stderr.String()
+	}
+	result.TokensUsed = tokenizer.ForTool(rec.Tool).Count(result.Output)
+	if rec.ExitCode != 0 {
+		result.Error = fmt.Sprintf("exit status %d", rec.ExitCode)
+	}
+	if ctx.Err() != nil {
+		result.Success = false
+		bd.markCancelled(ctx, result)
+	}
+	return result, nil
+}
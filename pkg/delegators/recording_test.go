@@ -0,0 +1,176 @@
+package delegators
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRecorder(&buf, trackers.ClaudeCodeTool, "Claude Code", ParserTypeClaude, "claude", []string{"-p", "fix it"})
+	r.Stdout().Write([]byte(`{"type":"system","session_id":"s1"}` + "\n"))
+	r.Stderr().Write([]byte("warning\n"))
+	r.Stdout().Write([]byte{0xff, 0xfe, '\n'}) // Not UTF-8
+	r.Exit(2)
+
+	second := NewRecorder(&buf, trackers.CodexTool, "Codex", ParserTypeCodex, "codex", nil)
+	second.Exit(0)
+
+	recordings, err := ReadRecordings(&buf)
+	if err != nil {
+		t.Fatalf("ReadRecordings() error = %v", err)
+	}
+	if len(recordings) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(recordings))
+	}
+
+	rec := recordings[0]
+	if rec.Tool != trackers.ClaudeCodeTool || rec.Parser != ParserTypeClaude || rec.ExitCode != 2 || rec.Started.IsZero() {
+		t.Errorf("session = %+v", rec)
+	}
+	if strings.Join(rec.Args, " ") != "-p fix it" {
+		t.Errorf("Args = %q", rec.Args)
+	}
+	want := []struct {
+		stream string
+		data   string
+	}{
+		{"stdout", `{"type":"system","session_id":"s1"}` + "\n"},
+		{"stderr", "warning\n"},
+		{"stdout", "\xff\xfe\n"},
+	}
+	if len(rec.Chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(rec.Chunks), len(want))
+	}
+	for i, chunk := range rec.Chunks {
+		if chunk.Stream != want[i].stream || string(chunk.Data) != want[i].data {
+			t.Errorf("chunk %d = %s %q, want %s %q", i, chunk.Stream, chunk.Data, want[i].stream, want[i].data)
+		}
+	}
+	if recordings[1].Tool != trackers.CodexTool {
+		t.Errorf("second session tool = %s", recordings[1].Tool)
+	}
+}
+
+func TestReadRecordingsInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string
+	}{
+		{name: "empty", recording: ""},
+		{name: "not json", recording: "hello\n"},
+		{name: "no start", recording: `{"type":"stdout","t":0,"text":"hi"}` + "\n"},
+		{name: "unknown type", recording: `{"type":"start","t":0}` + "\n" + `{"type":"stdin","t":0}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadRecordings(strings.NewReader(tt.recording)); err == nil {
+				t.Error("ReadRecordings() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	rec := &Recording{
+		Tool:     trackers.ClaudeCodeTool,
+		ToolName: "Claude Code",
+		Parser:   ParserTypeClaude,
+		ExitCode: 1,
+		Duration: 90 * time.Second,
+		Chunks: []*RecordedChunk{
+			{Offset: 0, Stream: "stdout", Data: []byte(`{"type":"system","session_id":"s1"}` + "\n" + `{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"Hello"}}}` + "\n")},
+			{Offset: 20 * time.Millisecond, Stream: "stdout", Data: []byte(`{"type":"stream_event","event":{"type":"content_block_de`)},
+			{Offset: 40 * time.Millisecond, Stream: "stdout", Data: []byte(`lta","delta":{"type":"text_delta","text":" world\n"}}}` + "\n")},
+			{Offset: 60 * time.Millisecond, Stream: "stderr", Data: []byte("rate limited\n")},
+		},
+	}
+
+	var output, stderr bytes.Buffer
+	start := time.Now()
+	result, err := rec.Replay(context.Background(), ReplayOptions{Speed: 2, Output: &output, Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Replay() took %s, want the recorded pace at twice the speed", elapsed)
+	}
+
+	if output.String() != "Hello world\n" {
+		t.Errorf("output = %q", output.String())
+	}
+	if stderr.String() != "rate limited\n" {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+	if result.Success || result.ExitCode != 1 || result.SessionID != "s1" || result.Duration != 90*time.Second {
+		t.Errorf("result = %+v", result)
+	}
+	if result.Output != "Hello world\n\nrate limited\n" {
+		t.Errorf("Output = %q", result.Output)
+	}
+}
+
+func TestReplayCancelled(t *testing.T) {
+	rec := &Recording{
+		Tool:   trackers.ClaudeCodeTool,
+		Parser: ParserTypeClaude,
+		Chunks: []*RecordedChunk{
+			{Offset: 0, Stream: "stdout", Data: []byte("first\n")},
+			{Offset: time.Hour, Stream: "stdout", Data: []byte("never\n")},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := rec.Replay(ctx, ReplayOptions{Speed: 1})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if !result.Cancelled || result.Success || result.Output != "first\n" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestExecuteRecording(t *testing.T) {
+	fakeCodex(t, `{"type":"thread.started","thread_id":"0199-thread"}
+{"type":"item.completed","item":{"id":"item_1","type":"agent_message","text":"Done."}}`)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+
+	cd := NewCodexDelegator()
+	cd.SetOutput(&strings.Builder{})
+	cd.SetWorkDir(t.TempDir())
+	cd.SetRecording(path)
+	result, err := cd.Execute(context.Background(), "say done")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	recordings, err := ReadRecordings(f)
+	if err != nil {
+		t.Fatalf("ReadRecordings() error = %v", err)
+	}
+	if len(recordings) != 1 || recordings[0].Tool != trackers.CodexTool || recordings[0].Command != "codex" {
+		t.Fatalf("recordings = %+v", recordings)
+	}
+
+	replayed, err := recordings[0].Replay(context.Background(), ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if replayed.Output != result.Output || replayed.SessionID != result.SessionID || replayed.Success != result.Success {
+		t.Errorf("replay = %+v, run = %+v", replayed, result)
+	}
+}
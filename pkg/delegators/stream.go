@@ -5,30 +5,74 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"strings"
 )
 
 type LineHandler func(line string)
 
+// StreamParser parses Claude's stream-json output into events. Lines that
+// aren't stream-json events, such as OpenCode's plain output, are text.
 type StreamParser struct {
-	reader     io.Reader
-	onLine     LineHandler
-	lineBuffer strings.Builder
+	reader    io.Reader
+	onEvent   EventHandler
+	sessionID string
+	streamed  bool // Text deltas arrived since the last assistant message
 }
 
-func NewStreamParser(reader io.Reader, onLine LineHandler) *StreamParser {
-	if onLine == nil {
-		onLine = func(line string) {}
+// claudeEvent is one line of Claude's stream-json output
+type claudeEvent struct {
+	Type      string             `json:"type"`
+	SessionID string             `json:"session_id"`
+	Event     *claudeStreamEvent `json:"event"`   // stream_event
+	Message   *claudeMessage     `json:"message"` // assistant
+	Result    string             `json:"result"`  // result
+	IsError   bool               `json:"is_error"`
+	Usage     *claudeUsage       `json:"usage"`
+}
+
+// claudeStreamEvent is a partial message event
+type claudeStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+// claudeMessage is a complete assistant message
+type claudeMessage struct {
+	Content []struct {
+		Type     string          `json:"type"`
+		Text     string          `json:"text"`
+		Thinking string          `json:"thinking"`
+		ID       string          `json:"id"`
+		Name     string          `json:"name"`
+		Input    json.RawMessage `json:"input"`
+	} `json:"content"`
+}
+
+// claudeUsage is the usage of a result event. Unlike Codex, Claude counts
+// cached input apart from input_tokens.
+type claudeUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+}
+
+func NewStreamParser(reader io.Reader, onEvent EventHandler) *StreamParser {
+	if onEvent == nil {
+		onEvent = func(event *Event) {}
 	}
 	return &StreamParser{
-		reader: reader,
-		onLine: onLine,
+		reader:  reader,
+		onEvent: onEvent,
 	}
 }
 
-func (sp *StreamParser) Parse() (string, error) {
+// Parse reads the stream to its end, passing each event to the handler
+func (sp *StreamParser) Parse() error {
 	scanner := bufio.NewScanner(sp.reader)
-	var fullOutput strings.Builder
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
@@ -36,96 +80,70 @@ func (sp *StreamParser) Parse() (string, error) {
 			continue
 		}
 
-		var event map[string]interface{}
-		if err := json.Unmarshal(line, &event); err != nil {
-			sp.processText(string(line), &fullOutput)
+		var event claudeEvent
+		if err := json.Unmarshal(line, &event); err != nil || event.Type == "" {
+			sp.onEvent(&Event{Type: EventText, Text: string(line) + "\n"})
 			continue
 		}
 
-		eventType, ok := event["type"].(string)
-		if !ok {
-			sp.processText(string(line), &fullOutput)
-			continue
+		// The system and result events carry the session a follow-up can resume
+		if event.SessionID != "" && event.SessionID != sp.sessionID {
+			sp.sessionID = event.SessionID
+			sp.onEvent(&Event{Type: EventSession, SessionID: event.SessionID})
 		}
 
-		if eventType == "stream_event" {
-			if streamEvent, ok := event["event"].(map[string]interface{}); ok {
-				if eventSubType, ok := streamEvent["type"].(string); ok && eventSubType == "content_block_delta" {
-					if delta, ok := streamEvent["delta"].(map[string]interface{}); ok {
-						if deltaType, ok := delta["type"].(string); ok && deltaType == "text_delta" {
-							if text, ok := delta["text"].(string); ok && text != "" {
-								sp.processText(text, &fullOutput)
-							}
-						}
-					}
-				}
+		switch event.Type {
+		case "stream_event":
+			if e := event.Event; e != nil && e.Type == "content_block_delta" && e.Delta.Type == "text_delta" && e.Delta.Text != "" {
+				sp.streamed = true
+				sp.onEvent(&Event{Type: EventText, Text: e.Delta.Text})
 			}
-		}
 
-		if eventType == "assistant" {
-			if message, ok := event["message"].(map[string]interface{}); ok {
-				if content, ok := message["content"].([]interface{}); ok {
-					for _, item := range content {
-						if contentItem, ok := item.(map[string]interface{}); ok {
-							if text, ok := contentItem["text"].(string); ok && text != "" {
-								if sp.lineBuffer.Len() == 0 {
-									sp.processText(text, &fullOutput)
-								}
-							}
-						}
-					}
-				}
+		case "assistant":
+			if event.Message != nil {
+				sp.message(event.Message)
 			}
-		}
 
-		if eventType == "result" {
-			if result, ok := event["result"].(string); ok && result != "" {
-				if sp.lineBuffer.Len() == 0 {
-					sp.processText(result, &fullOutput)
+		case "result":
+			if event.Usage != nil {
+				sp.onEvent(&Event{Type: EventUsage, Usage: &Usage{
+					InputTokens:       event.Usage.InputTokens + event.Usage.CacheCreationInputTokens + event.Usage.CacheReadInputTokens,
+					CachedInputTokens: event.Usage.CacheReadInputTokens,
+					OutputTokens:      event.Usage.OutputTokens,
+				}})
+			}
+			if event.IsError {
+				if event.Result != "" {
+					sp.onEvent(&Event{Type: EventError, Text: event.Result})
 				}
+			} else if event.Result != "" {
+				sp.onEvent(&Event{Type: EventResult, Text: event.Result})
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("stream parsing error: %w", err)
-	}
-
-	if sp.lineBuffer.Len() > 0 {
-		line := sp.lineBuffer.String()
-		sp.onLine(line)
-		fullOutput.WriteString(line)
-		sp.lineBuffer.Reset()
+		return fmt.Errorf("stream parsing error: %w", err)
 	}
-
-	return fullOutput.String(), nil
+	return nil
 }
 
-func (sp *StreamParser) processText(text string, output *strings.Builder) {
-	sp.lineBuffer.WriteString(text)
-
-	for {
-		content := sp.lineBuffer.String()
-		newlineIdx := strings.Index(content, "\n")
-		if newlineIdx == -1 {
-			break
+// message passes on the content of a complete assistant message. Its text
+// was streamed already when partial messages are on.
+func (sp *StreamParser) message(message *claudeMessage) {
+	for _, block := range message.Content {
+		switch block.Type {
+		case "text":
+			if block.Text != "" && !sp.streamed {
+				sp.onEvent(&Event{Type: EventText, Text: ensureNewline(block.Text)})
+			}
+		case "thinking":
+			if block.Thinking != "" {
+				sp.onEvent(&Event{Type: EventReasoning, Text: block.Thinking})
+			}
+		case "tool_use":
+			sp.onEvent(&Event{Type: EventToolCall, ID: block.ID, Name: block.Name, Input: block.Input})
 		}
-
-		line := content[:newlineIdx]
-		remaining := content[newlineIdx+1:]
-
-		sp.onLine(line)
-		output.WriteString(line)
-		output.WriteString("\n")
-
-		sp.lineBuffer.Reset()
-		sp.lineBuffer.WriteString(remaining)
-	}
-}
-
-func (sp *StreamParser) GetAccumulated() string {
-	if sp.lineBuffer.Len() > 0 {
-		return sp.lineBuffer.String()
 	}
-	return ""
+	sp.streamed = false
 }
@@ -0,0 +1,244 @@
+package delegators
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// transcribe runs a parser into a transcript, and returns the transcript
+// and the lines it showed
+func transcribe(t *testing.T, parse func(EventHandler) error) (*Transcript, []string) {
+	t.Helper()
+	var lines []string
+	transcript := NewTranscript(func(line string) { lines = append(lines, line) })
+	if err := parse(transcript.Handle); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	transcript.Close()
+	return transcript, lines
+}
+
+func TestStreamParserSessionID(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+	}{
+		{
+			name: "claude stream-json",
+			stream: `{"type":"system","subtype":"init","session_id":"3f2a-init"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Done\n"}]},"session_id":"3f2a-init"}
+{"type":"result","result":"Done","session_id":"3f2a-result"}`,
+			want: "3f2a-result",
+		},
+		{
+			name:   "plain text",
+			stream: "no session here\n",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcript, _ := transcribe(t, func(onEvent EventHandler) error {
+				return NewStreamParser(strings.NewReader(tt.stream), onEvent).Parse()
+			})
+			if got := transcript.SessionID(); got != tt.want {
+				t.Errorf("SessionID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodexStreamParserSessionID(t *testing.T) {
+	stream := `{"type":"thread.started","thread_id":"0199-thread"}
+{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}`
+
+	transcript, _ := transcribe(t, func(onEvent EventHandler) error {
+		return NewCodexStreamParser(strings.NewReader(stream), onEvent).Parse()
+	})
+	if got := transcript.SessionID(); got != "0199-thread" {
+		t.Errorf("SessionID() = %q, want 0199-thread", got)
+	}
+}
+
+func TestCodexStreamParserActivity(t *testing.T) {
+	stream := `{"type":"thread.started","thread_id":"0199-thread"}
+{"type":"turn.started"}
+{"type":"item.completed","item":{"id":"item_0","type":"reasoning","text":"**Scanning the parser**"}}
+{"type":"item.started","item":{"id":"item_1","type":"command_execution","command":"bash -lc 'go test ./...'","aggregated_output":"","exit_code":null,"status":"in_progress"}}
+{"type":"item.completed","item":{"id":"item_1","type":"command_execution","command":"bash -lc 'go test ./...'","aggregated_output":"--- FAIL: TestParse\n","exit_code":1,"status":"failed"}}
+{"type":"item.completed","item":{"id":"item_2","type":"file_change","changes":[{"path":"parser.go","kind":"update"},{"path":"parser_test.go","kind":"add"}],"status":"completed"}}
+{"type":"item.started","item":{"id":"item_3","type":"command_execution","command":"bash -lc 'go test ./...'","status":"in_progress"}}
+not json from a wrapper script
+{"type":"item.completed","item":{"id":"item_4","type":"agent_message","text":"Fixed the off-by-one in Parse."}}
+{"type":"error","message":"stream disconnected, retrying"}
+{"type":"turn.completed","usage":{"input_tokens":1200,"output_tokens":300}}`
+
+	transcript, lines := transcribe(t, func(onEvent EventHandler) error {
+		return NewCodexStreamParser(strings.NewReader(stream), onEvent).Parse()
+	})
+	output := transcript.Output()
+
+	activity := transcript.Activity()
+	if activity == nil {
+		t.Fatal("Activity() = nil")
+	}
+	if len(activity.Commands) != 2 {
+		t.Fatalf("Commands = %d, want 2", len(activity.Commands))
+	}
+	if cmd := activity.Commands[0]; !cmd.Failed() || cmd.Output != "--- FAIL: TestParse\n" {
+		t.Errorf("first command = %+v, want it failed with its output", cmd)
+	}
+	if cmd := activity.Commands[1]; cmd.ExitCode != nil || cmd.Failed() {
+		t.Errorf("unfinished command = %+v, want no exit code", cmd)
+	}
+	if len(activity.FileEdits) != 2 || activity.FileEdits[1].Path != "parser_test.go" || activity.FileEdits[1].Kind != "add" {
+		t.Errorf("FileEdits = %+v", activity.FileEdits)
+	}
+	if len(activity.Reasoning) != 1 || activity.Reasoning[0] != "**Scanning the parser**" {
+		t.Errorf("Reasoning = %q", activity.Reasoning)
+	}
+	if activity.FinalMessage != "Fixed the off-by-one in Parse." {
+		t.Errorf("FinalMessage = %q", activity.FinalMessage)
+	}
+	if len(activity.Errors) != 1 || activity.Errors[0] != "stream disconnected, retrying" {
+		t.Errorf("Errors = %q", activity.Errors)
+	}
+
+	// Short reasoning isn't streamed; everything else is
+	want := []string{
+		"$ bash -lc 'go test ./...'",
+		"--- FAIL: TestParse",
+		"✎ update parser.go",
+		"✎ add parser_test.go",
+		"$ bash -lc 'go test ./...'",
+		"Fixed the off-by-one in Parse.",
+		"[Error] stream disconnected, retrying",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("streamed lines = %q, want %q", lines, want)
+	}
+	if output != strings.Join(want, "\n")+"\n" {
+		t.Errorf("output = %q", output)
+	}
+}
+
+func TestCodexStreamParserNoActivity(t *testing.T) {
+	transcript, _ := transcribe(t, func(onEvent EventHandler) error {
+		return NewCodexStreamParser(strings.NewReader(`{"type":"thread.started","thread_id":"t"}`), onEvent).Parse()
+	})
+	if a := transcript.Activity(); a != nil {
+		t.Errorf("Activity() = %+v, want nil", a)
+	}
+}
+
+func TestStreamParserEvents(t *testing.T) {
+	stream := `{"type":"system","subtype":"init","session_id":"3f2a"}
+{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"Let me "}},"session_id":"3f2a"}
+{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"check."}},"session_id":"3f2a"}
+{"type":"assistant","message":{"content":[{"type":"thinking","thinking":"Look at main.go"},{"type":"text","text":"Let me check."},{"type":"tool_use","id":"toolu_1","name":"Read","input":{"file_path":"main.go"}}]},"session_id":"3f2a"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"It compiles."}]},"session_id":"3f2a"}
+{"type":"result","result":"It compiles.","usage":{"input_tokens":10,"cache_creation_input_tokens":100,"cache_read_input_tokens":900,"output_tokens":40},"session_id":"3f2a"}`
+
+	var events []*Event
+	transcript, lines := transcribe(t, func(onEvent EventHandler) error {
+		return NewStreamParser(strings.NewReader(stream), func(event *Event) {
+			events = append(events, event)
+			onEvent(event)
+		}).Parse()
+	})
+
+	var types []EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	wantTypes := []EventType{EventSession, EventText, EventText, EventReasoning, EventToolCall, EventText, EventUsage, EventResult}
+	if fmt.Sprint(types) != fmt.Sprint(wantTypes) {
+		t.Errorf("event types = %v, want %v", types, wantTypes)
+	}
+	if call := events[4]; call.Name != "Read" || string(call.Input) != `{"file_path":"main.go"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+
+	// Streamed text isn't repeated from the complete message
+	want := []string{"Let me check.", "It compiles."}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if usage := transcript.Usage(); usage == nil || *usage != (Usage{InputTokens: 1010, CachedInputTokens: 900, OutputTokens: 40}) {
+		t.Errorf("Usage() = %+v", usage)
+	}
+	if a := transcript.Activity(); a == nil || a.FinalMessage != "It compiles." {
+		t.Errorf("Activity() = %+v, want the final message", a)
+	}
+}
+
+func TestStreamParserPlainText(t *testing.T) {
+	transcript, lines := transcribe(t, func(onEvent EventHandler) error {
+		return NewStreamParser(strings.NewReader("Reading files\n\nDone: 2 files\n"), onEvent).Parse()
+	})
+	if want := []string{"Reading files", "Done: 2 files"}; strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if got := transcript.Output(); got != "Reading files\nDone: 2 files\n" {
+		t.Errorf("Output() = %q", got)
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	exitCode := 2
+	tests := []struct {
+		name   string
+		events []*Event
+		want   string
+	}{
+		{
+			name:   "text deltas join into lines",
+			events: []*Event{{Type: EventText, Text: "Hel"}, {Type: EventText, Text: "lo\nwor"}, {Type: EventText, Text: "ld"}},
+			want:   "Hello\nworld",
+		},
+		{
+			name:   "other events end a partial line",
+			events: []*Event{{Type: EventText, Text: "Fixing"}, {Type: EventFileEdit, Path: "a.go", Kind: "update"}},
+			want:   "Fixing\n✎ update a.go\n",
+		},
+		{
+			name: "commands show their output once finished",
+			events: []*Event{
+				{Type: EventCommand, ID: "1", Command: "make", Status: CommandStarted},
+				{Type: EventCommand, ID: "1", Command: "make", Status: CommandCompleted, ExitCode: &exitCode, Output: "error"},
+			},
+			want: "$ make\nerror\n",
+		},
+		{
+			name:   "short reasoning and tool calls aren't shown",
+			events: []*Event{{Type: EventReasoning, Text: "Plan"}, {Type: EventToolCall, Name: "Read"}, {Type: EventError, Text: "rate limited"}},
+			want:   "[Error] rate limited\n",
+		},
+		{
+			name:   "result shown when no text was",
+			events: []*Event{{Type: EventResult, Text: "Done"}},
+			want:   "Done\n",
+		},
+		{
+			name:   "result not repeated after text",
+			events: []*Event{{Type: EventText, Text: "Done\n"}, {Type: EventResult, Text: "Done"}},
+			want:   "Done\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcript := NewTranscript(nil)
+			for _, event := range tt.events {
+				transcript.Handle(event)
+			}
+			transcript.Close()
+			if got := transcript.Output(); got != tt.want {
+				t.Errorf("Output() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
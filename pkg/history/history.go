@@ -0,0 +1,196 @@
+package history
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileName is the history file inside the state directory
+const FileName = "history.jsonl"
+
+// minDurationRuns is how many finished runs a tool needs before its median
+// duration is trusted
+const minDurationRuns = 3
+
+// Record is a single dispatched run
+type Record struct {
+	RunID           string        `json:"run_id"`
+	Time            time.Time     `json:"time"`
+	Task            string        `json:"task"`
+	Attachments     []string      `json:"attachments,omitempty"`
+	Tool            string        `json:"tool"`
+	Level           string        `json:"level"`
+	Category        string        `json:"category"`
+	Language        string        `json:"language,omitempty"`
+	Mode            string        `json:"mode"`
+	SessionID       string        `json:"session_id,omitempty"` // Tool session a follow-up can resume
+	Dir             string        `json:"dir,omitempty"`        // Directory the tool ran in, where its session can be resumed
+	EstimatedTokens int           `json:"estimated_tokens"`
+	ActualTokens    int           `json:"actual_tokens"`
+	UsageTokens     int           `json:"usage_tokens,omitempty"` // Tokens the tool reported reading and writing, when it did
+	Success         bool          `json:"success"`
+	Cancelled       bool          `json:"cancelled,omitempty"` // Interrupted or timed out
+	Verified        *bool         `json:"verified,omitempty"`  // Nil when no verification commands ran
+	Retries         int           `json:"retries,omitempty"`
+	Duration        time.Duration `json:"duration"`
+}
+
+// Store appends run records to a JSON Lines file
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewStore creates a store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Open returns the store in a state directory
+func Open(stateDir string) *Store {
+	return NewStore(filepath.Join(stateDir, FileName))
+}
+
+// Path returns the history file path
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append adds a record to the end of the history
+func (s *Store) Append(record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Load reads every record, oldest first. A missing file is an empty history
+// and malformed lines (e.g. from an interrupted write) are skipped.
+func (s *Store) Load() ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	var records []*Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, &record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	return records, nil
+}
+
+// Find returns the record with the given run ID (or unique prefix of one)
+func (s *Store) Find(runID string) (*Record, error) {
+	records, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Record
+	for _, record := range records {
+		if record.RunID == runID {
+			return record, nil
+		}
+		if len(runID) >= 4 && len(record.RunID) > len(runID) && record.RunID[:len(runID)] == runID {
+			if found != nil {
+				return nil, fmt.Errorf("run ID %q is ambiguous", runID)
+			}
+			found = record
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("run %q not found in history", runID)
+	}
+	return found, nil
+}
+
+// LastWithSession returns the most recent run in dir that recorded a tool
+// session. Tools keep sessions per directory, so runs elsewhere can't be
+// continued from dir.
+func (s *Store) LastWithSession(dir string) (*Record, error) {
+	records, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].SessionID != "" && records[i].Dir == dir {
+			return records[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no previous run in %s has a session to continue", dir)
+}
+
+// MedianDurations returns the median duration of each tool's successful runs,
+// for tools with enough of them. Failed and cancelled runs stopped early, so
+// they would make a tool look faster than it is.
+func MedianDurations(records []*Record) map[string]time.Duration {
+	durations := make(map[string][]time.Duration)
+	for _, record := range records {
+		if record.Success && !record.Cancelled && record.Duration > 0 {
+			durations[record.Tool] = append(durations[record.Tool], record.Duration)
+		}
+	}
+
+	medians := make(map[string]time.Duration)
+	for tool, d := range durations {
+		if len(d) < minDurationRuns {
+			continue
+		}
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		medians[tool] = d[len(d)/2]
+	}
+	return medians
+}
+
+// NewRunID returns a sortable, unique run ID (timestamp plus random suffix)
+func NewRunID() string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return time.Now().UTC().Format("20060102-150405.000000")
+	}
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,151 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreAppendAndLoad(t *testing.T) {
+	store := Open(filepath.Join(t.TempDir(), "state"))
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() on missing file error = %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("Load() on missing file = %d records, want 0", len(records))
+	}
+
+	first := &Record{RunID: "20260101-120000-aaaaaa", Task: "fix typo", Tool: "codex", Category: "bugfix", Success: true, Duration: time.Second}
+	second := &Record{RunID: "20260101-130000-bbbbbb", Task: "how does routing work?", Tool: "claude-code", Category: "question", Mode: "query"}
+	for _, record := range []*Record{first, second} {
+		if err := store.Append(record); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	// A truncated line from an interrupted write is skipped
+	file, err := os.OpenFile(store.Path(), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"run_id": "broken`)
+	file.Close()
+
+	records, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Load() = %d records, want 2", len(records))
+	}
+	if records[1].Category != "question" || records[1].Mode != "query" {
+		t.Errorf("second record = %+v, want question/query", records[1])
+	}
+	if records[0].Duration != time.Second {
+		t.Errorf("first record duration = %v, want 1s", records[0].Duration)
+	}
+}
+
+func TestStoreFind(t *testing.T) {
+	store := Open(t.TempDir())
+	for _, id := range []string{"20260101-120000-aaaaaa", "20260101-120000-aabbbb", "20260102-090000-cccccc"} {
+		if err := store.Append(&Record{RunID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		runID   string
+		want    string
+		wantErr string
+	}{
+		{name: "exact", runID: "20260101-120000-aaaaaa", want: "20260101-120000-aaaaaa"},
+		{name: "unique prefix", runID: "20260102", want: "20260102-090000-cccccc"},
+		{name: "ambiguous prefix", runID: "20260101-120000-aa", wantErr: "ambiguous"},
+		{name: "unknown", runID: "19990101", wantErr: "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := store.Find(tt.runID)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Find() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if record.RunID != tt.want {
+				t.Errorf("Find() = %s, want %s", record.RunID, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreLastWithSession(t *testing.T) {
+	store := Open(t.TempDir())
+	if _, err := store.LastWithSession("/src/api"); err == nil {
+		t.Fatal("LastWithSession() on empty history returned no error")
+	}
+
+	for _, record := range []*Record{
+		{RunID: "20260101-120000-aaaaaa", Tool: "claude-code", SessionID: "first", Dir: "/src/api"},
+		{RunID: "20260101-130000-bbbbbb", Tool: "codex", SessionID: "second", Dir: "/src/api"},
+		{RunID: "20260101-140000-cccccc", Tool: "opencode", Dir: "/src/api"},
+		{RunID: "20260101-150000-dddddd", Tool: "claude-code", SessionID: "elsewhere", Dir: "/src/web"},
+		{RunID: "20260101-160000-eeeeee", Tool: "claude-code", SessionID: "unknown directory"},
+	} {
+		if err := store.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	record, err := store.LastWithSession("/src/api")
+	if err != nil {
+		t.Fatalf("LastWithSession() error = %v", err)
+	}
+	if record.SessionID != "second" || record.Tool != "codex" {
+		t.Errorf("LastWithSession() = %+v, want the codex run with session second", record)
+	}
+	if _, err := store.LastWithSession("/src/docs"); err == nil {
+		t.Error("LastWithSession() in a directory without runs returned no error")
+	}
+}
+
+func TestMedianDurations(t *testing.T) {
+	var records []*Record
+	add := func(tool string, seconds int, success, cancelled bool) {
+		records = append(records, &Record{Tool: tool, Duration: time.Duration(seconds) * time.Second, Success: success, Cancelled: cancelled})
+	}
+	for _, s := range []int{50, 10, 30, 20, 40} {
+		add("claude-code", s, true, false)
+	}
+	add("claude-code", 1, false, false) // Failed runs don't count
+	add("claude-code", 2, false, true)
+	add("codex", 5, true, false) // Too few runs
+	add("codex", 6, true, false)
+
+	got := MedianDurations(records)
+	want := map[string]time.Duration{"claude-code": 30 * time.Second}
+	if len(got) != len(want) || got["claude-code"] != want["claude-code"] {
+		t.Errorf("MedianDurations() = %v, want %v", got, want)
+	}
+}
+
+func TestNewRunIDIsUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := NewRunID()
+		if seen[id] {
+			t.Fatalf("NewRunID() returned duplicate %s", id)
+		}
+		seen[id] = true
+	}
+}
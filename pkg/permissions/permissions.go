@@ -0,0 +1,169 @@
+package permissions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// Level is how much a tool may change during a run
+type Level string
+
+const (
+	ReadOnly       Level = "read-only"       // Read files, change nothing
+	WorkspaceWrite Level = "workspace-write" // Change files in the working directory
+	FullAccess     Level = "full-access"     // No restrictions
+)
+
+// Levels lists the levels from the most to the least restrictive
+var Levels = []Level{ReadOnly, WorkspaceWrite, FullAccess}
+
+// ParseLevel validates a level name
+func ParseLevel(name string) (Level, error) {
+	for _, level := range Levels {
+		if Level(name) == level {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("unknown permission level %q: must be one of [read-only, workspace-write, full-access]", name)
+}
+
+// AtMost reports whether l allows no more than max. Unknown levels are left
+// for Validate to reject.
+func (l Level) AtMost(max Level) bool {
+	return l.rank() <= max.rank()
+}
+
+// rank is the position of the level in Levels, or -1 for unknown ones
+func (l Level) rank() int {
+	for i, level := range Levels {
+		if level == l {
+			return i
+		}
+	}
+	return -1
+}
+
+// Profile is what a tool is allowed to do during a run. Commands are matched
+// by prefix, so "git" covers every git subcommand and "git push" only pushes.
+type Profile struct {
+	Level           Level    `yaml:"level" json:"level"`
+	AllowedCommands []string `yaml:"allowed_commands" json:"allowed_commands,omitempty"` // Run without asking
+	DeniedCommands  []string `yaml:"denied_commands" json:"denied_commands,omitempty"`   // Never run
+}
+
+// Default returns the profile of runs that don't request one: the tool may
+// change files in the working directory
+func Default() *Profile {
+	return &Profile{Level: WorkspaceWrite}
+}
+
+// Validate checks the level and the command rules
+func (p *Profile) Validate() error {
+	if _, err := ParseLevel(string(p.Level)); err != nil {
+		return err
+	}
+	for _, commands := range [][]string{p.AllowedCommands, p.DeniedCommands} {
+		for _, command := range commands {
+			if strings.TrimSpace(command) == "" {
+				return fmt.Errorf("empty command in the permission rules")
+			}
+		}
+	}
+	return nil
+}
+
+// Restrict returns p (the default when nil) with the restrictions of r
+// added: its level when it is lower, and its denied commands. The fields of
+// r that would allow more, which are left out, are returned by name.
+func (p *Profile) Restrict(r *Profile) (*Profile, []string) {
+	restricted := Profile{Level: Default().Level}
+	if p != nil {
+		restricted = *p
+		if restricted.Level == "" {
+			restricted.Level = Default().Level
+		}
+	}
+
+	var ignored []string
+	if r.Level != "" {
+		if r.Level.AtMost(restricted.Level) {
+			restricted.Level = r.Level
+		} else {
+			ignored = append(ignored, "level")
+		}
+	}
+	if len(r.AllowedCommands) > 0 {
+		ignored = append(ignored, "allowed_commands")
+	}
+	restricted.DeniedCommands = append(append([]string(nil), restricted.DeniedCommands...), r.DeniedCommands...)
+	return &restricted, ignored
+}
+
+// HasCommandRules reports whether commands are allowed or denied by name
+func (p *Profile) HasCommandRules() bool {
+	return len(p.AllowedCommands) > 0 || len(p.DeniedCommands) > 0
+}
+
+// ReadOnly returns the profile with its level lowered to read-only, keeping
+// the command rules
+func (p *Profile) ReadOnly() *Profile {
+	readOnly := *p
+	readOnly.Level = ReadOnly
+	return &readOnly
+}
+
+// String describes the profile, e.g. "read-only, 2 allowed and 1 denied commands"
+func (p *Profile) String() string {
+	var rules []string
+	if n := len(p.AllowedCommands); n > 0 {
+		rules = append(rules, fmt.Sprintf("%d allowed", n))
+	}
+	if n := len(p.DeniedCommands); n > 0 {
+		rules = append(rules, fmt.Sprintf("%d denied", n))
+	}
+	if len(rules) == 0 {
+		return string(p.Level)
+	}
+	return fmt.Sprintf("%s, %s commands", p.Level, strings.Join(rules, " and "))
+}
+
+// Support is what a tool can enforce
+type Support struct {
+	Levels       []Level
+	CommandRules bool // Allowed and denied commands
+}
+
+// support lists what each tool's flags can enforce
+var support = map[trackers.ToolType]Support{
+	// Permission modes, and Bash rules in --allowedTools/--disallowedTools
+	trackers.ClaudeCodeTool: {Levels: Levels, CommandRules: true},
+	// Sandbox modes; commands can't be allowed or denied from the command line
+	trackers.CodexTool: {Levels: Levels},
+	// No permission flags, so it runs with the permissions of its own config
+	trackers.OpenCodeTool: {Levels: []Level{FullAccess}},
+}
+
+// SupportFor returns what a tool can enforce
+func SupportFor(tool trackers.ToolType) Support {
+	return support[tool]
+}
+
+// Check returns an error when a tool can't enforce a profile
+func Check(tool trackers.ToolType, p *Profile) error {
+	s := SupportFor(tool)
+	supported := false
+	for _, level := range s.Levels {
+		if level == p.Level {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("%s can't enforce %s permissions", tool, p.Level)
+	}
+	if p.HasCommandRules() && !s.CommandRules {
+		return fmt.Errorf("%s can't allow or deny commands", tool)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package permissions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range Levels {
+		if got, err := ParseLevel(string(level)); err != nil || got != level {
+			t.Errorf("ParseLevel(%q) = %q, %v", level, got, err)
+		}
+	}
+	if _, err := ParseLevel("admin"); err == nil {
+		t.Error("ParseLevel(admin) error = nil, want an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile *Profile
+		wantErr bool
+	}{
+		{name: "level only", profile: &Profile{Level: ReadOnly}},
+		{name: "with commands", profile: &Profile{Level: WorkspaceWrite, AllowedCommands: []string{"go test"}, DeniedCommands: []string{"git push"}}},
+		{name: "unknown level", profile: &Profile{Level: "root"}, wantErr: true},
+		{name: "missing level", profile: &Profile{AllowedCommands: []string{"go"}}, wantErr: true},
+		{name: "empty command", profile: &Profile{Level: FullAccess, DeniedCommands: []string{" "}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.profile.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	withRules := &Profile{Level: WorkspaceWrite, DeniedCommands: []string{"rm"}}
+	tests := []struct {
+		name    string
+		tool    trackers.ToolType
+		profile *Profile
+		wantErr bool
+	}{
+		{name: "claude read-only", tool: trackers.ClaudeCodeTool, profile: &Profile{Level: ReadOnly}},
+		{name: "claude command rules", tool: trackers.ClaudeCodeTool, profile: withRules},
+		{name: "codex workspace-write", tool: trackers.CodexTool, profile: &Profile{Level: WorkspaceWrite}},
+		{name: "codex command rules", tool: trackers.CodexTool, profile: withRules, wantErr: true},
+		{name: "opencode full-access", tool: trackers.OpenCodeTool, profile: &Profile{Level: FullAccess}},
+		{name: "opencode read-only", tool: trackers.OpenCodeTool, profile: &Profile{Level: ReadOnly}, wantErr: true},
+		{name: "unknown tool", tool: "cursor", profile: &Profile{Level: FullAccess}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(tt.tool, tt.profile); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfileString(t *testing.T) {
+	tests := []struct {
+		profile *Profile
+		want    string
+	}{
+		{&Profile{Level: ReadOnly}, "read-only"},
+		{&Profile{Level: WorkspaceWrite, AllowedCommands: []string{"go", "make"}}, "workspace-write, 2 allowed commands"},
+		{&Profile{Level: FullAccess, AllowedCommands: []string{"go"}, DeniedCommands: []string{"rm"}}, "full-access, 1 allowed and 1 denied commands"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.profile.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	global := &Profile{Level: WorkspaceWrite, AllowedCommands: []string{"go test"}, DeniedCommands: []string{"git push"}}
+	tests := []struct {
+		name        string
+		profile     *Profile
+		restriction *Profile
+		want        *Profile
+		wantIgnored []string
+	}{
+		{
+			name:        "lower level",
+			profile:     global,
+			restriction: &Profile{Level: ReadOnly, DeniedCommands: []string{"rm"}},
+			want:        &Profile{Level: ReadOnly, AllowedCommands: []string{"go test"}, DeniedCommands: []string{"git push", "rm"}},
+		},
+		{
+			name:        "higher level and allowed commands",
+			profile:     global,
+			restriction: &Profile{Level: FullAccess, AllowedCommands: []string{"curl"}},
+			want:        global,
+			wantIgnored: []string{"level", "allowed_commands"},
+		},
+		{
+			name:        "default",
+			restriction: &Profile{Level: FullAccess, DeniedCommands: []string{"rm"}},
+			want:        &Profile{Level: WorkspaceWrite, DeniedCommands: []string{"rm"}},
+			wantIgnored: []string{"level"},
+		},
+		{
+			name:        "unknown level left for Validate",
+			restriction: &Profile{Level: "root"},
+			want:        &Profile{Level: "root"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ignored := tt.profile.Restrict(tt.restriction)
+			if got.String() != tt.want.String() || strings.Join(got.DeniedCommands, ",") != strings.Join(tt.want.DeniedCommands, ",") {
+				t.Errorf("Restrict() = %+v, want %+v", got, tt.want)
+			}
+			if strings.Join(ignored, ",") != strings.Join(tt.wantIgnored, ",") {
+				t.Errorf("Restrict() ignored %q, want %q", ignored, tt.wantIgnored)
+			}
+		})
+	}
+	if len(global.DeniedCommands) != 1 {
+		t.Errorf("Restrict() changed the profile: %+v", global)
+	}
+}
@@ -0,0 +1,280 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Mode is how progress is reported
+type Mode string
+
+const (
+	Auto  Mode = "auto"  // TTY on a terminal, plain in CI and elsewhere
+	TTY   Mode = "tty"   // A spinner redrawn in place
+	Plain Mode = "plain" // Timestamped lines, for CI logs
+	None  Mode = "none"  // Nothing
+)
+
+// Modes lists the modes that can be requested
+var Modes = []Mode{Auto, TTY, Plain, None}
+
+// ParseMode validates a mode name
+func ParseMode(name string) (Mode, error) {
+	for _, mode := range Modes {
+		if Mode(name) == mode {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown progress mode %q: must be one of [auto, tty, plain, none]", name)
+}
+
+// HeartbeatInterval is how long plain progress waits without output before
+// printing that the step is still running
+const HeartbeatInterval = 30 * time.Second
+
+// ciVariables are set by CI services, whose logs can't redraw a line
+var ciVariables = []string{"CI", "CONTINUOUS_INTEGRATION", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "JENKINS_URL", "TF_BUILD", "TEAMCITY_VERSION"}
+
+// Detect picks the mode for progress written to f: TTY on a terminal, and
+// plain in CI, on dumb terminals and when f isn't a terminal
+func Detect(f *os.File) Mode {
+	for _, name := range ciVariables {
+		if value := os.Getenv(name); value != "" && value != "false" && value != "0" {
+			return Plain
+		}
+	}
+	if os.Getenv("TERM") == "dumb" || !isatty.IsTerminal(f.Fd()) {
+		return Plain
+	}
+	return TTY
+}
+
+// Resolve returns the mode for progress written to f: the requested one, or
+// the detected one for auto (and when none was requested)
+func Resolve(mode Mode, f *os.File) Mode {
+	if mode == "" || mode == Auto {
+		return Detect(f)
+	}
+	return mode
+}
+
+// Reporter shows that a step is still running
+type Reporter interface {
+	// Start begins the step, described by message ("" for none)
+	Start(message string)
+
+	// Update replaces the step's message and shows it right away
+	Update(message string)
+
+	// Clear hides the indicator so output can be printed, and restarts the
+	// wait before it shows again
+	Clear()
+
+	// Stop ends the step and hides the indicator
+	Stop()
+}
+
+// New returns a reporter writing to w. The TTY spinner shows once the step
+// has printed nothing for idle; plain heartbeats come every HeartbeatInterval
+// without output.
+func New(mode Mode, w io.Writer, idle time.Duration) Reporter {
+	switch mode {
+	case TTY:
+		return &ttyReporter{w: w, idle: idle, interval: 100 * time.Millisecond}
+	case Plain:
+		return &plainReporter{w: w, heartbeat: HeartbeatInterval}
+	default:
+		return noReporter{}
+	}
+}
+
+// noReporter reports nothing
+type noReporter struct{}
+
+func (noReporter) Start(string)  {}
+func (noReporter) Update(string) {}
+func (noReporter) Clear()        {}
+func (noReporter) Stop()         {}
+
+// ticker runs a reporter's tick function in the background until stopped
+type ticker struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+func startTicker(interval time.Duration, tick func()) *ticker {
+	t := &ticker{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		timer := time.NewTicker(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-timer.C:
+				tick()
+			}
+		}
+	}()
+	return t
+}
+
+// Stop stops the ticker and waits for a running tick to finish
+func (t *ticker) Stop() {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	<-t.done
+}
+
+// spinnerFrames are the frames of the TTY spinner
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// ttyReporter draws a spinner and the message on the current line, which
+// output must leave empty (ending with a newline)
+type ttyReporter struct {
+	w        io.Writer
+	idle     time.Duration
+	interval time.Duration
+
+	mu      sync.Mutex
+	message string
+	last    time.Time // Last output
+	frame   int
+	shown   bool
+	ticker  *ticker
+	stopped bool
+}
+
+func (r *ttyReporter) Start(message string) {
+	r.mu.Lock()
+	r.message = message
+	r.last = time.Now()
+	r.mu.Unlock()
+	r.ticker = startTicker(r.interval, r.tick)
+}
+
+func (r *ttyReporter) tick() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stopped && time.Since(r.last) >= r.idle {
+		r.draw()
+	}
+}
+
+func (r *ttyReporter) Update(message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.message = message
+	if !r.stopped {
+		r.draw()
+	}
+}
+
+func (r *ttyReporter) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hide()
+	r.last = time.Now()
+}
+
+func (r *ttyReporter) Stop() {
+	r.ticker.Stop()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hide()
+	r.stopped = true
+}
+
+// draw redraws the line with the next frame
+func (r *ttyReporter) draw() {
+	line := spinnerFrames[r.frame%len(spinnerFrames)]
+	if r.message != "" {
+		line += " " + r.message
+	}
+	fmt.Fprintf(r.w, "\r\033[K%s", line)
+	r.frame++
+	r.shown = true
+}
+
+// hide clears the line if the spinner is on it
+func (r *ttyReporter) hide() {
+	if r.shown {
+		fmt.Fprint(r.w, "\r\033[K")
+		r.shown = false
+	}
+}
+
+// plainReporter prints timestamped lines: the message when the step starts
+// or changes, and a heartbeat while it prints nothing
+type plainReporter struct {
+	w         io.Writer
+	heartbeat time.Duration
+
+	mu      sync.Mutex
+	message string
+	started time.Time
+	last    time.Time // Last output or line printed
+	ticker  *ticker
+}
+
+func (r *plainReporter) Start(message string) {
+	r.mu.Lock()
+	r.message = message
+	r.started = time.Now()
+	r.last = r.started
+	if message != "" {
+		r.println(message)
+	}
+	r.mu.Unlock()
+
+	interval := time.Second
+	if r.heartbeat < interval {
+		interval = r.heartbeat
+	}
+	r.ticker = startTicker(interval, r.tick)
+}
+
+func (r *plainReporter) tick() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.last) < r.heartbeat {
+		return
+	}
+	running := fmt.Sprintf("still running after %s", time.Since(r.started).Round(time.Second))
+	if r.message != "" {
+		running = fmt.Sprintf("%s (%s)", r.message, running)
+	}
+	r.println(running)
+	r.last = time.Now()
+}
+
+func (r *plainReporter) Update(message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.message = message
+	r.println(message)
+	r.last = time.Now()
+}
+
+func (r *plainReporter) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = time.Now()
+}
+
+func (r *plainReporter) Stop() {
+	r.ticker.Stop()
+}
+
+// println prints a line stamped with the time
+func (r *plainReporter) println(text string) {
+	fmt.Fprintf(r.w, "[%s] %s\n", time.Now().Format("15:04:05"), text)
+}
@@ -0,0 +1,133 @@
+package progress
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range Modes {
+		if got, err := ParseMode(string(mode)); err != nil || got != mode {
+			t.Errorf("ParseMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	if _, err := ParseMode("fancy"); err == nil {
+		t.Error("ParseMode(fancy) error = nil, want an error")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, name := range ciVariables {
+		t.Setenv(name, "")
+	}
+
+	tests := []struct {
+		name string
+		mode Mode
+		ci   string
+		want Mode
+	}{
+		{name: "tty requested", mode: TTY, ci: "true", want: TTY},
+		{name: "none requested", mode: None, want: None},
+		{name: "auto on a file", mode: Auto, want: Plain},
+		{name: "unset on a file", mode: "", want: Plain},
+		{name: "auto in CI", mode: Auto, ci: "true", want: Plain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CI", tt.ci)
+			if got := Resolve(tt.mode, f); got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTTYReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := &ttyReporter{w: &buf, idle: 0, interval: 5 * time.Millisecond}
+	r.Start("Waiting")
+	time.Sleep(30 * time.Millisecond)
+	r.Stop()
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "\r\033[K⠋ Waiting\r\033[K⠙ Waiting") {
+		t.Errorf("output = %q, want the spinner redrawn in place", out)
+	}
+	if !strings.HasSuffix(out, "\r\033[K") {
+		t.Errorf("output = %q, want the line cleared on Stop", out)
+	}
+}
+
+func TestTTYReporterIdle(t *testing.T) {
+	var buf bytes.Buffer
+	r := &ttyReporter{w: &buf, idle: time.Hour, interval: 5 * time.Millisecond}
+	r.Start("")
+	time.Sleep(20 * time.Millisecond)
+	r.Clear()
+	r.Stop()
+	if buf.Len() != 0 {
+		t.Errorf("output = %q, want nothing before the step is idle", buf.String())
+	}
+
+	r = &ttyReporter{w: &buf, idle: time.Hour, interval: time.Hour}
+	r.Start("")
+	r.Update("3 minutes left")
+	r.Stop()
+	if buf.String() != "\r\033[K⠋ 3 minutes left\r\033[K" {
+		t.Errorf("output = %q, want an update drawn right away", buf.String())
+	}
+}
+
+func TestPlainReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := &plainReporter{w: &buf, heartbeat: 20 * time.Millisecond}
+	r.Start("Waiting for capacity")
+	time.Sleep(70 * time.Millisecond)
+	r.Update("1 minute left")
+	r.Stop()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	stamp := regexp.MustCompile(`^\[\d\d:\d\d:\d\d\] `)
+	for _, line := range lines {
+		if !stamp.MatchString(line) {
+			t.Errorf("line %q isn't timestamped", line)
+		}
+		if strings.ContainsAny(line, "\r\b\033") {
+			t.Errorf("line %q has terminal control characters", line)
+		}
+	}
+	if len(lines) < 3 {
+		t.Fatalf("output = %q, want the message, a heartbeat and the update", buf.String())
+	}
+	if !strings.HasSuffix(lines[0], "Waiting for capacity") ||
+		!strings.Contains(lines[1], "Waiting for capacity (still running after") ||
+		!strings.HasSuffix(lines[len(lines)-1], "1 minute left") {
+		t.Errorf("output = %q", buf.String())
+	}
+}
+
+func TestPlainReporterOutput(t *testing.T) {
+	var buf bytes.Buffer
+	r := &plainReporter{w: &buf, heartbeat: 30 * time.Millisecond}
+	r.Start("")
+	for i := 0; i < 6; i++ {
+		time.Sleep(10 * time.Millisecond)
+		r.Clear() // The step printed something
+	}
+	r.Stop()
+	if buf.Len() != 0 {
+		t.Errorf("output = %q, want no heartbeat while the step prints", buf.String())
+	}
+}
@@ -2,9 +2,11 @@ package router
 
 import (
 	"fmt"
-	"sort"
+	"math"
 
 	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/calibration"
+	"github.com/crlian/ai-dispatcher/pkg/tokenizer"
 	"github.com/crlian/ai-dispatcher/pkg/trackers"
 )
 
@@ -23,8 +25,10 @@ type CostEstimate struct {
 	ToolName         string            `json:"tool_name"`
 	EstimatedCost    float64           `json:"estimated_cost"`
 	EstimatedTokens  int               `json:"estimated_tokens"`
+	Tokenizer        string            `json:"tokenizer"`
 	AvailablePercent float64           `json:"available_percent"`
 	CurrentCost5h    float64           `json:"current_cost_5h"`
+	RemainingMinutes int               `json:"remaining_minutes"`
 	WillExceedLimit  bool              `json:"will_exceed_limit"`
 	IsAvailable      bool              `json:"is_available"`
 	Confidence       float64           `json:"confidence"`
@@ -32,7 +36,8 @@ type CostEstimate struct {
 
 // CostCalculator calculates costs for different AI tools
 type CostCalculator struct {
-	trackers []trackers.UsageTracker
+	trackers    []trackers.UsageTracker
+	calibration *calibration.Model // Per-tool corrections (none when nil)
 }
 
 // NewCostCalculator creates a new cost calculator
@@ -42,6 +47,11 @@ func NewCostCalculator(trackers []trackers.UsageTracker) *CostCalculator {
 	}
 }
 
+// SetCalibration sets the model used to correct each tool's token estimate
+func (cc *CostCalculator) SetCalibration(model *calibration.Model) {
+	cc.calibration = model
+}
+
 // CalculateCosts calculates cost estimates for all available tools
 func (cc *CostCalculator) CalculateCosts(analysis *analyzers.ComplexityAnalysis) ([]*CostEstimate, error) {
 	estimates := make([]*CostEstimate, 0, len(cc.trackers))
@@ -67,8 +77,18 @@ func (cc *CostCalculator) calculateForTool(tracker trackers.UsageTracker, analys
 	// Get tool pricing
 	pricePerToken := cc.getPricing(tracker.GetToolType()) / 1000.0
 
+	// The analysis carries the tool-agnostic calibration; adjust it for how
+	// this tool's usage differs, then count the prompt with the tool's tokenizer
+	tokens := analysis.Tokens
+	if cc.calibration != nil {
+		adjustment := cc.calibration.Adjustment(string(tracker.GetToolType()), string(analysis.Level), string(analysis.Category))
+		tokens = int(math.Round(float64(tokens) * adjustment))
+	}
+	tok := tokenizer.ForTool(tracker.GetToolType())
+	tokens += tok.Count(analysis.Prompt)
+
 	// Calculate estimated cost
-	estimatedCost := float64(analysis.Tokens) * pricePerToken
+	estimatedCost := float64(tokens) * pricePerToken
 
 	// Get current usage
 	available, err := tracker.GetAvailablePercentage()
@@ -86,6 +106,12 @@ func (cc *CostCalculator) calculateForTool(tracker trackers.UsageTracker, analys
 		return nil, err
 	}
 
+	// Remaining window time is informational (used by reset-aware strategies)
+	remainingMinutes, err := tracker.GetRemainingTime()
+	if err != nil {
+		remainingMinutes = 0
+	}
+
 	// Check if adding this task would exceed limits
 	// Assuming a limit based on available percentage and current cost
 	willExceedLimit := !isAvailable || available < 10.0
@@ -94,9 +120,11 @@ func (cc *CostCalculator) calculateForTool(tracker trackers.UsageTracker, analys
 		Tool:             tracker.GetToolType(),
 		ToolName:         tracker.GetToolName(),
 		EstimatedCost:    estimatedCost,
-		EstimatedTokens:  analysis.Tokens,
+		EstimatedTokens:  tokens,
+		Tokenizer:        tok.Name(),
 		AvailablePercent: available,
 		CurrentCost5h:    currentCost,
+		RemainingMinutes: remainingMinutes,
 		WillExceedLimit:  willExceedLimit,
 		IsAvailable:      isAvailable,
 		Confidence:       analysis.Confidence,
@@ -120,40 +148,7 @@ func (cc *CostCalculator) getPricing(toolType trackers.ToolType) float64 {
 // SortEstimates sorts cost estimates by priority
 // Priority: available > free > cheaper > expensive
 func (cc *CostCalculator) SortEstimates(estimates []*CostEstimate) []*CostEstimate {
-	sorted := make([]*CostEstimate, len(estimates))
-	copy(sorted, estimates)
-
-	sort.Slice(sorted, func(i, j int) bool {
-		a, b := sorted[i], sorted[j]
-
-		// 1. Prioritize available tools
-		if a.IsAvailable != b.IsAvailable {
-			return a.IsAvailable
-		}
-
-		// 2. Prioritize tools that won't exceed limit
-		if a.WillExceedLimit != b.WillExceedLimit {
-			return !a.WillExceedLimit
-		}
-
-		// 3. Prioritize free tools
-		if a.EstimatedCost == 0 && b.EstimatedCost != 0 {
-			return true
-		}
-		if a.EstimatedCost != 0 && b.EstimatedCost == 0 {
-			return false
-		}
-
-		// 4. Sort by cost (cheaper first)
-		if a.EstimatedCost != b.EstimatedCost {
-			return a.EstimatedCost < b.EstimatedCost
-		}
-
-		// 5. Sort by available percentage (more available first)
-		return a.AvailablePercent > b.AvailablePercent
-	})
-
-	return sorted
+	return rankBy(estimates, nil)
 }
 
 // GetBestEstimate returns the best cost estimate based on priority
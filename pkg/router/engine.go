@@ -1,13 +1,44 @@
 package router
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/calibration"
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
 	"github.com/crlian/ai-dispatcher/pkg/trackers"
 )
 
+// ErrNoToolsAvailable is returned when every tool has exceeded its limits
+var ErrNoToolsAvailable = errors.New("no tools available - all tools have exceeded their limits or are unavailable")
+
+// ErrNoToolPermitted is returned when no tool can enforce the requested permissions
+var ErrNoToolPermitted = errors.New("no tool can enforce the requested permissions")
+
+// ExecutionMode is how the selected tool should be invoked
+type ExecutionMode string
+
+const (
+	// ModeExecute runs the task with full access to the working directory
+	ModeExecute ExecutionMode = "execute"
+	// ModeQuery only asks the tool for an answer, without changing files
+	ModeQuery ExecutionMode = "query"
+)
+
+// QueryConfidenceThreshold is the category confidence needed to route a
+// question to the read-only query path
+const QueryConfidenceThreshold = 0.6
+
+// ModeForAnalysis returns the execution mode for a task's category
+func ModeForAnalysis(analysis *analyzers.ComplexityAnalysis) ExecutionMode {
+	if analysis != nil && analysis.IsReadOnly() && analysis.CategoryConfidence >= QueryConfidenceThreshold {
+		return ModeQuery
+	}
+	return ModeExecute
+}
+
 // RoutingDecision represents the decision made by the routing engine
 type RoutingDecision struct {
 	SelectedTool trackers.ToolType             `json:"selected_tool"`
@@ -16,23 +47,101 @@ type RoutingDecision struct {
 	Alternatives []*CostEstimate               `json:"alternatives"`
 	SelectedCost *CostEstimate                 `json:"selected_cost"`
 	Complexity   *analyzers.ComplexityAnalysis `json:"complexity"`
+	Strategy     string                        `json:"strategy"`
+	Mode         ExecutionMode                 `json:"mode"`
 	WasForced    bool                          `json:"was_forced"`
+
+	// Permissions is the profile requested for the run, and Skipped the
+	// tools passed over because they can't enforce it
+	Permissions *permissions.Profile `json:"permissions,omitempty"`
+	Skipped     []*SkippedTool       `json:"skipped,omitempty"`
+}
+
+// SkippedTool is a tool left out of a decision, and why
+type SkippedTool struct {
+	Tool   trackers.ToolType `json:"tool"`
+	Reason string            `json:"reason"`
 }
 
 // DecisionEngine makes routing decisions for task execution
 type DecisionEngine struct {
-	calculator *CostCalculator
-	trackers   []trackers.UsageTracker
+	calculator  *CostCalculator
+	trackers    []trackers.UsageTracker
+	strategy    Strategy
+	permissions *permissions.Profile
 }
 
-// NewDecisionEngine creates a new decision engine
-func NewDecisionEngine(trackers []trackers.UsageTracker) *DecisionEngine {
+// NewDecisionEngine creates a new decision engine that ranks tools with the
+// given strategy (the cheapest strategy when nil)
+func NewDecisionEngine(trackers []trackers.UsageTracker, strategy Strategy) *DecisionEngine {
+	if strategy == nil {
+		strategy = &CheapestStrategy{}
+	}
 	return &DecisionEngine{
 		calculator: NewCostCalculator(trackers),
 		trackers:   trackers,
+		strategy:   strategy,
 	}
 }
 
+// SetCalibration sets the model used to correct each tool's token estimate
+func (de *DecisionEngine) SetCalibration(model *calibration.Model) {
+	de.calculator.SetCalibration(model)
+}
+
+// SetPermissions sets the profile tools must be able to enforce to be
+// selected (any tool can be when nil)
+func (de *DecisionEngine) SetPermissions(profile *permissions.Profile) {
+	de.permissions = profile
+}
+
+// permitted splits estimates into the tools that can enforce the requested
+// permissions and those that can't
+func (de *DecisionEngine) permitted(estimates []*CostEstimate) ([]*CostEstimate, []*SkippedTool) {
+	if de.permissions == nil {
+		return estimates, nil
+	}
+	var kept []*CostEstimate
+	var skipped []*SkippedTool
+	for _, estimate := range estimates {
+		if err := permissions.Check(estimate.Tool, de.permissions); err != nil {
+			skipped = append(skipped, &SkippedTool{Tool: estimate.Tool, Reason: err.Error()})
+			continue
+		}
+		kept = append(kept, estimate)
+	}
+	return kept, skipped
+}
+
+// GetStrategy returns the strategy used to rank tools
+func (de *DecisionEngine) GetStrategy() Strategy {
+	return de.strategy
+}
+
+// Rank returns the available tools for a task in the strategy's order of preference
+func (de *DecisionEngine) Rank(analysis *analyzers.ComplexityAnalysis) ([]*CostEstimate, error) {
+	estimates, err := de.calculator.CalculateCosts(analysis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate costs: %w", err)
+	}
+	estimates, _ = de.permitted(estimates)
+
+	available := de.calculator.FilterAvailable(estimates)
+	return de.strategy.Rank(available, analysis), nil
+}
+
+// CommitDecision tells stateful strategies that a decision was acted on
+// Forced decisions don't advance the strategy's state
+func (de *DecisionEngine) CommitDecision(decision *RoutingDecision) error {
+	if decision == nil || decision.WasForced {
+		return nil
+	}
+	if recorder, ok := de.strategy.(SelectionRecorder); ok {
+		return recorder.RecordSelection(decision.SelectedTool)
+	}
+	return nil
+}
+
 // MakeDecision determines the best tool to use for a task
 // If forceTool is specified, it will attempt to use that tool
 func (de *DecisionEngine) MakeDecision(
@@ -50,18 +159,23 @@ func (de *DecisionEngine) MakeDecision(
 		return de.handleForcedTool(forceTool, estimates, analysis)
 	}
 
-	// Filter to available tools
+	// Leave out the tools that can't enforce the permissions, then the
+	// unavailable ones
+	estimates, skipped := de.permitted(estimates)
+	if len(estimates) == 0 {
+		return nil, fmt.Errorf("%w (%s)", ErrNoToolPermitted, de.permissions)
+	}
 	available := de.calculator.FilterAvailable(estimates)
 	if len(available) == 0 {
-		return nil, fmt.Errorf("no tools available - all tools have exceeded their limits or are unavailable")
+		return nil, ErrNoToolsAvailable
 	}
 
-	// Sort by priority and select best
-	sorted := de.calculator.SortEstimates(available)
+	// Rank with the configured strategy and select best
+	sorted := de.strategy.Rank(available, analysis)
 	selected := sorted[0]
 
 	// Build reason
-	reason := de.buildReason(selected, analysis, sorted)
+	reason := de.buildReason(selected, analysis, sorted, skipped)
 
 	return &RoutingDecision{
 		SelectedTool: selected.Tool,
@@ -70,7 +184,11 @@ func (de *DecisionEngine) MakeDecision(
 		Alternatives: sorted[1:], // All other options
 		SelectedCost: selected,
 		Complexity:   analysis,
+		Strategy:     de.strategy.Name(),
+		Mode:         ModeForAnalysis(analysis),
 		WasForced:    false,
+		Permissions:  de.permissions,
+		Skipped:      skipped,
 	}, nil
 }
 
@@ -85,6 +203,11 @@ func (de *DecisionEngine) handleForcedTool(
 	if err != nil {
 		return nil, fmt.Errorf("invalid forced tool: %w", err)
 	}
+	if de.permissions != nil {
+		if err := permissions.Check(toolType, de.permissions); err != nil {
+			return nil, fmt.Errorf("forced tool %s: %w", forceTool, err)
+		}
+	}
 
 	// Find the forced tool in estimates
 	var selected *CostEstimate
@@ -118,7 +241,10 @@ func (de *DecisionEngine) handleForcedTool(
 		Alternatives: alternatives,
 		SelectedCost: selected,
 		Complexity:   analysis,
+		Strategy:     de.strategy.Name(),
+		Mode:         ModeForAnalysis(analysis),
 		WasForced:    true,
+		Permissions:  de.permissions,
 	}, nil
 }
 
@@ -127,6 +253,7 @@ func (de *DecisionEngine) buildReason(
 	selected *CostEstimate,
 	analysis *analyzers.ComplexityAnalysis,
 	allEstimates []*CostEstimate,
+	skipped []*SkippedTool,
 ) string {
 	var parts []string
 
@@ -146,6 +273,11 @@ func (de *DecisionEngine) buildReason(
 		))
 	}
 
+	// Mention non-default strategies
+	if de.strategy.Name() != DefaultStrategy {
+		parts = append(parts, fmt.Sprintf("Strategy: %s", de.strategy.Name()))
+	}
+
 	// Add complexity context
 	parts = append(parts, fmt.Sprintf(
 		"Task complexity: %s (~%d tokens, confidence: %.1f%%, method: %s)",
@@ -155,6 +287,28 @@ func (de *DecisionEngine) buildReason(
 		analysis.Method,
 	))
 
+	// Add category context
+	if analysis.Category != "" {
+		category := fmt.Sprintf("Category: %s (confidence: %.1f%%)", analysis.Category, analysis.CategoryConfidence*100)
+		if ModeForAnalysis(analysis) == ModeQuery {
+			category += ", answered read-only"
+		}
+		parts = append(parts, category)
+	}
+
+	// Mention the permissions and the tools that can't enforce them
+	if de.permissions != nil {
+		permission := fmt.Sprintf("Permissions: %s", de.permissions)
+		if len(skipped) > 0 {
+			var names []string
+			for _, s := range skipped {
+				names = append(names, string(s.Tool))
+			}
+			permission += fmt.Sprintf(" (skipped %s)", strings.Join(names, ", "))
+		}
+		parts = append(parts, permission)
+	}
+
 	// Add reasoning if available
 	if analysis.Reasoning != "" {
 		parts = append(parts, fmt.Sprintf("Reason: %s", analysis.Reasoning))
@@ -227,7 +381,8 @@ type ToolStatus struct {
 	RemainingTime int               `json:"remaining_time_minutes"`
 	CurrentCost   float64           `json:"current_cost_5h"`
 	IsAvailable   bool              `json:"is_available"`
-	Status        string            `json:"status"` // "available", "low", "limited", "error"
+	Status        string            `json:"status"`         // "available", "low", "limited", "error"
+	Rank          int               `json:"rank,omitempty"` // Position in the strategy's preference order (0 = not ranked)
 	Error         string            `json:"error,omitempty"`
 }
 
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+func TestModeForAnalysis(t *testing.T) {
+	tests := []struct {
+		name     string
+		analysis *analyzers.ComplexityAnalysis
+		expected ExecutionMode
+	}{
+		{
+			name:     "confident question is answered read-only",
+			analysis: &analyzers.ComplexityAnalysis{Category: analyzers.Question, CategoryConfidence: 0.8},
+			expected: ModeQuery,
+		},
+		{
+			name:     "question asking for a change is executed",
+			analysis: &analyzers.ComplexityAnalysis{Category: analyzers.Question, CategoryConfidence: 0.8, Actions: []string{"fix"}},
+			expected: ModeExecute,
+		},
+		{
+			name:     "uncertain question is executed",
+			analysis: &analyzers.ComplexityAnalysis{Category: analyzers.Question, CategoryConfidence: 0.4},
+			expected: ModeExecute,
+		},
+		{
+			name:     "bug fix is executed",
+			analysis: &analyzers.ComplexityAnalysis{Category: analyzers.BugFix, CategoryConfidence: 0.9},
+			expected: ModeExecute,
+		},
+		{
+			name:     "nil analysis is executed",
+			analysis: nil,
+			expected: ModeExecute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ModeForAnalysis(tt.analysis); got != tt.expected {
+				t.Errorf("ModeForAnalysis() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMakeDecisionSetsMode(t *testing.T) {
+	engine := NewDecisionEngine([]trackers.UsageTracker{
+		&recoveringTracker{toolType: trackers.CodexTool},
+	}, nil)
+
+	analysis := &analyzers.ComplexityAnalysis{
+		Level:              analyzers.Simple,
+		Tokens:             150,
+		Category:           analyzers.Question,
+		CategoryConfidence: 0.9,
+	}
+
+	decision, err := engine.MakeDecision(analysis, "")
+	if err != nil {
+		t.Fatalf("MakeDecision() error = %v", err)
+	}
+	if decision.Mode != ModeQuery {
+		t.Errorf("decision mode = %v, want %v", decision.Mode, ModeQuery)
+	}
+
+	forced, err := engine.MakeDecision(analysis, string(trackers.CodexTool))
+	if err != nil {
+		t.Fatalf("MakeDecision() forced error = %v", err)
+	}
+	if forced.Mode != ModeQuery {
+		t.Errorf("forced decision mode = %v, want %v", forced.Mode, ModeQuery)
+	}
+}
+
+func TestMakeDecisionPermissions(t *testing.T) {
+	engine := NewDecisionEngine([]trackers.UsageTracker{
+		&recoveringTracker{toolType: trackers.OpenCodeTool},
+		&recoveringTracker{toolType: trackers.CodexTool},
+		&recoveringTracker{toolType: trackers.ClaudeCodeTool},
+	}, nil)
+	analysis := &analyzers.ComplexityAnalysis{Level: analyzers.Simple, Tokens: 150}
+
+	tests := []struct {
+		name     string
+		profile  *permissions.Profile
+		force    string
+		want     trackers.ToolType
+		skipped  int
+		wantErr  error
+		anyError bool
+	}{
+		{
+			name:    "read-only skips opencode",
+			profile: &permissions.Profile{Level: permissions.ReadOnly},
+			want:    trackers.CodexTool,
+			skipped: 1,
+		},
+		{
+			name:    "command rules leave only claude",
+			profile: &permissions.Profile{Level: permissions.WorkspaceWrite, DeniedCommands: []string{"git push"}},
+			want:    trackers.ClaudeCodeTool,
+			skipped: 2,
+		},
+		{
+			name:    "full access keeps every tool",
+			profile: &permissions.Profile{Level: permissions.FullAccess},
+			want:    trackers.OpenCodeTool,
+		},
+		{
+			name:     "forced tool that can't enforce the profile",
+			profile:  &permissions.Profile{Level: permissions.ReadOnly},
+			force:    string(trackers.OpenCodeTool),
+			anyError: true,
+		},
+		{
+			name:    "no tool can enforce the profile",
+			profile: &permissions.Profile{Level: "root"},
+			wantErr: ErrNoToolPermitted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine.SetPermissions(tt.profile)
+			decision, err := engine.MakeDecision(analysis, tt.force)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("MakeDecision() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.anyError:
+				if err == nil {
+					t.Fatal("MakeDecision() error = nil, want an error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("MakeDecision() error = %v", err)
+			}
+
+			if decision.SelectedTool != tt.want {
+				t.Errorf("selected %s, want %s", decision.SelectedTool, tt.want)
+			}
+			if len(decision.Skipped) != tt.skipped {
+				t.Errorf("skipped %d tools, want %d", len(decision.Skipped), tt.skipped)
+			}
+			if decision.Permissions != tt.profile {
+				t.Errorf("decision permissions = %v, want %v", decision.Permissions, tt.profile)
+			}
+		})
+	}
+}
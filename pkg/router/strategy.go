@@ -0,0 +1,292 @@
+package router
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// Built-in strategy names
+const (
+	StrategyCheapest          = "cheapest"
+	StrategyBestQuality       = "best-quality"
+	StrategyFastest           = "fastest"
+	StrategyRoundRobin        = "round-robin"
+	StrategyDrainSoonestReset = "drain-soonest-reset"
+)
+
+// DefaultStrategy is used when neither --strategy nor the config selects one
+const DefaultStrategy = StrategyCheapest
+
+const (
+	roundRobinStateFileName = "round_robin"
+	unknownRemainingMinutes = 1 << 30
+)
+
+// Strategy ranks the available tools for a task. The first estimate of the
+// returned slice is the one the decision engine selects.
+type Strategy interface {
+	// Name returns the strategy name used by --strategy and the config
+	Name() string
+
+	// Rank returns the estimates ordered by preference (it must not modify the input)
+	Rank(estimates []*CostEstimate, analysis *analyzers.ComplexityAnalysis) []*CostEstimate
+}
+
+// SelectionRecorder is implemented by strategies that keep state across runs.
+// The engine calls it through CommitDecision once a decision is acted on.
+type SelectionRecorder interface {
+	RecordSelection(tool trackers.ToolType) error
+}
+
+// ToolQuality ranks tools by output quality (higher is better)
+var ToolQuality = map[trackers.ToolType]int{
+	trackers.ClaudeCodeTool: 3,
+	trackers.CodexTool:      2,
+	trackers.OpenCodeTool:   1,
+}
+
+// ToolSpeed ranks tools by typical turnaround time (higher is faster). The
+// fastest strategy falls back to it until the history has timed every tool.
+// Codex on low reasoning effort answers without planning first, while Claude
+// Code explores the repository before editing.
+var ToolSpeed = map[trackers.ToolType]int{
+	trackers.CodexTool:      3,
+	trackers.ClaudeCodeTool: 2,
+	trackers.OpenCodeTool:   1,
+}
+
+// GetStrategy returns a built-in strategy by name. Stateful strategies keep
+// their state in stateDir (in memory when empty).
+func GetStrategy(name, stateDir string) (Strategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", StrategyCheapest:
+		return &CheapestStrategy{}, nil
+	case StrategyBestQuality:
+		return &BestQualityStrategy{}, nil
+	case StrategyFastest:
+		return &FastestStrategy{}, nil
+	case StrategyRoundRobin:
+		statePath := ""
+		if stateDir != "" {
+			statePath = filepath.Join(stateDir, roundRobinStateFileName)
+		}
+		return NewRoundRobinStrategy(statePath), nil
+	case StrategyDrainSoonestReset:
+		return &DrainSoonestResetStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown strategy %q: must be one of [%s]", name, strings.Join(StrategyNames(), ", "))
+	}
+}
+
+// StrategyNames returns the names of all built-in strategies
+func StrategyNames() []string {
+	return []string{
+		StrategyCheapest,
+		StrategyBestQuality,
+		StrategyFastest,
+		StrategyRoundRobin,
+		StrategyDrainSoonestReset,
+	}
+}
+
+// CheapestStrategy prefers free and cheaper tools (the original behavior)
+type CheapestStrategy struct{}
+
+// Name returns the strategy name
+func (s *CheapestStrategy) Name() string { return StrategyCheapest }
+
+// Rank orders estimates by availability, then cost, then capacity
+func (s *CheapestStrategy) Rank(estimates []*CostEstimate, _ *analyzers.ComplexityAnalysis) []*CostEstimate {
+	return rankBy(estimates, nil)
+}
+
+// BestQualityStrategy prefers the highest quality tool, falling back to cost
+type BestQualityStrategy struct{}
+
+// Name returns the strategy name
+func (s *BestQualityStrategy) Name() string { return StrategyBestQuality }
+
+// Rank orders estimates by tool quality
+func (s *BestQualityStrategy) Rank(estimates []*CostEstimate, _ *analyzers.ComplexityAnalysis) []*CostEstimate {
+	return rankBy(estimates, func(a, b *CostEstimate) int {
+		return ToolQuality[b.Tool] - ToolQuality[a.Tool]
+	})
+}
+
+// FastestStrategy prefers the tool with the quickest turnaround
+type FastestStrategy struct {
+	durations map[trackers.ToolType]time.Duration
+}
+
+// Name returns the strategy name
+func (s *FastestStrategy) Name() string { return StrategyFastest }
+
+// SetDurations sets each tool's typical run duration, such as the median
+// from the history
+func (s *FastestStrategy) SetDurations(durations map[trackers.ToolType]time.Duration) {
+	s.durations = durations
+}
+
+// Rank orders estimates by measured duration when every tool has one, and
+// by ToolSpeed otherwise
+func (s *FastestStrategy) Rank(estimates []*CostEstimate, _ *analyzers.ComplexityAnalysis) []*CostEstimate {
+	for _, estimate := range estimates {
+		if _, ok := s.durations[estimate.Tool]; !ok {
+			return rankBy(estimates, func(a, b *CostEstimate) int {
+				return ToolSpeed[b.Tool] - ToolSpeed[a.Tool]
+			})
+		}
+	}
+	return rankBy(estimates, func(a, b *CostEstimate) int {
+		return cmp.Compare(s.durations[a.Tool], s.durations[b.Tool])
+	})
+}
+
+// DrainSoonestResetStrategy prefers the tool whose window resets first, so
+// capacity that is about to be restored anyway gets used before it expires
+type DrainSoonestResetStrategy struct{}
+
+// Name returns the strategy name
+func (s *DrainSoonestResetStrategy) Name() string { return StrategyDrainSoonestReset }
+
+// Rank orders estimates by remaining window time (unknown times go last)
+func (s *DrainSoonestResetStrategy) Rank(estimates []*CostEstimate, _ *analyzers.ComplexityAnalysis) []*CostEstimate {
+	return rankBy(estimates, func(a, b *CostEstimate) int {
+		return remainingOrUnknown(a) - remainingOrUnknown(b)
+	})
+}
+
+// remainingOrUnknown treats an unknown reset time as the furthest away
+func remainingOrUnknown(estimate *CostEstimate) int {
+	if estimate.RemainingMinutes <= 0 {
+		return unknownRemainingMinutes
+	}
+	return estimate.RemainingMinutes
+}
+
+// RoundRobinStrategy rotates through the available tools. The last selected
+// tool is persisted in a state file so the rotation continues across runs.
+type RoundRobinStrategy struct {
+	mu        sync.Mutex
+	statePath string
+	last      trackers.ToolType
+}
+
+// NewRoundRobinStrategy creates a round-robin strategy persisted at statePath
+// (kept in memory when statePath is empty)
+func NewRoundRobinStrategy(statePath string) *RoundRobinStrategy {
+	s := &RoundRobinStrategy{statePath: statePath}
+	if statePath != "" {
+		if data, err := os.ReadFile(statePath); err == nil {
+			s.last = trackers.ToolType(strings.TrimSpace(string(data)))
+		}
+	}
+	return s
+}
+
+// Name returns the strategy name
+func (s *RoundRobinStrategy) Name() string { return StrategyRoundRobin }
+
+// Rank puts the tool after the last selected one first, in GetAllToolTypes order
+func (s *RoundRobinStrategy) Rank(estimates []*CostEstimate, _ *analyzers.ComplexityAnalysis) []*CostEstimate {
+	s.mu.Lock()
+	last := s.last
+	s.mu.Unlock()
+
+	order := trackers.GetAllToolTypes()
+	position := make(map[trackers.ToolType]int, len(order))
+	start := 0
+	for i, tool := range order {
+		position[tool] = i
+		if tool == last {
+			start = i + 1
+		}
+	}
+
+	// Distance from the slot after the last selection, wrapping around
+	distance := func(tool trackers.ToolType) int {
+		pos, ok := position[tool]
+		if !ok {
+			return len(order)
+		}
+		return (pos - start + len(order)) % len(order)
+	}
+
+	return rankBy(estimates, func(a, b *CostEstimate) int {
+		return distance(a.Tool) - distance(b.Tool)
+	})
+}
+
+// RecordSelection remembers the selected tool and persists it
+func (s *RoundRobinStrategy) RecordSelection(tool trackers.ToolType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.last = tool
+	if s.statePath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.statePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(s.statePath, []byte(string(tool)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to save round-robin state: %w", err)
+	}
+	return nil
+}
+
+// rankBy sorts a copy of the estimates. Availability always comes first, then
+// the strategy's preference (negative means a before b), then the cheapest
+// ordering as a tie-breaker.
+func rankBy(estimates []*CostEstimate, prefer func(a, b *CostEstimate) int) []*CostEstimate {
+	sorted := make([]*CostEstimate, len(estimates))
+	copy(sorted, estimates)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+
+		// 1. Prioritize available tools
+		if a.IsAvailable != b.IsAvailable {
+			return a.IsAvailable
+		}
+
+		// 2. Prioritize tools that won't exceed limit
+		if a.WillExceedLimit != b.WillExceedLimit {
+			return !a.WillExceedLimit
+		}
+
+		// 3. Strategy preference
+		if prefer != nil {
+			if diff := prefer(a, b); diff != 0 {
+				return diff < 0
+			}
+		}
+
+		// 4. Prioritize free tools
+		if a.EstimatedCost == 0 && b.EstimatedCost != 0 {
+			return true
+		}
+		if a.EstimatedCost != 0 && b.EstimatedCost == 0 {
+			return false
+		}
+
+		// 5. Sort by cost (cheaper first)
+		if a.EstimatedCost != b.EstimatedCost {
+			return a.EstimatedCost < b.EstimatedCost
+		}
+
+		// 6. Sort by available percentage (more available first)
+		return a.AvailablePercent > b.AvailablePercent
+	})
+
+	return sorted
+}
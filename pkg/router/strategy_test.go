@@ -0,0 +1,187 @@
+package router
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// strategyEstimates returns three available tools with distinct costs and reset times
+func strategyEstimates() []*CostEstimate {
+	return []*CostEstimate{
+		{
+			Tool:             trackers.ClaudeCodeTool,
+			EstimatedCost:    0.015,
+			AvailablePercent: 80.0,
+			RemainingMinutes: 200,
+			IsAvailable:      true,
+		},
+		{
+			Tool:             trackers.CodexTool,
+			EstimatedCost:    0.0,
+			AvailablePercent: 60.0,
+			RemainingMinutes: 30,
+			IsAvailable:      true,
+		},
+		{
+			Tool:             trackers.OpenCodeTool,
+			EstimatedCost:    0.0,
+			AvailablePercent: 90.0,
+			RemainingMinutes: 0, // unknown
+			IsAvailable:      true,
+		},
+	}
+}
+
+func TestStrategyRank(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		want     []trackers.ToolType
+	}{
+		{
+			name:     "cheapest prefers free tools with more capacity",
+			strategy: StrategyCheapest,
+			want:     []trackers.ToolType{trackers.OpenCodeTool, trackers.CodexTool, trackers.ClaudeCodeTool},
+		},
+		{
+			name:     "best-quality prefers claude",
+			strategy: StrategyBestQuality,
+			want:     []trackers.ToolType{trackers.ClaudeCodeTool, trackers.CodexTool, trackers.OpenCodeTool},
+		},
+		{
+			name:     "fastest without timings prefers codex",
+			strategy: StrategyFastest,
+			want:     []trackers.ToolType{trackers.CodexTool, trackers.ClaudeCodeTool, trackers.OpenCodeTool},
+		},
+		{
+			name:     "drain-soonest-reset puts unknown reset last",
+			strategy: StrategyDrainSoonestReset,
+			want:     []trackers.ToolType{trackers.CodexTool, trackers.ClaudeCodeTool, trackers.OpenCodeTool},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := GetStrategy(tt.strategy, "")
+			if err != nil {
+				t.Fatalf("GetStrategy() error = %v", err)
+			}
+
+			ranked := strategy.Rank(strategyEstimates(), nil)
+			for i, tool := range tt.want {
+				if ranked[i].Tool != tool {
+					t.Errorf("Rank()[%d] = %s, want %s", i, ranked[i].Tool, tool)
+				}
+			}
+		})
+	}
+}
+
+func TestFastestStrategyDurations(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations map[trackers.ToolType]time.Duration
+		want      []trackers.ToolType
+	}{
+		{
+			name: "measured durations",
+			durations: map[trackers.ToolType]time.Duration{
+				trackers.ClaudeCodeTool: 40 * time.Second,
+				trackers.CodexTool:      90 * time.Second,
+				trackers.OpenCodeTool:   20 * time.Second,
+			},
+			want: []trackers.ToolType{trackers.OpenCodeTool, trackers.ClaudeCodeTool, trackers.CodexTool},
+		},
+		{
+			// Durations can't be compared with an untimed tool
+			name: "a tool without timings",
+			durations: map[trackers.ToolType]time.Duration{
+				trackers.ClaudeCodeTool: 40 * time.Second,
+				trackers.CodexTool:      90 * time.Second,
+			},
+			want: []trackers.ToolType{trackers.CodexTool, trackers.ClaudeCodeTool, trackers.OpenCodeTool},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := &FastestStrategy{}
+			strategy.SetDurations(tt.durations)
+			ranked := strategy.Rank(strategyEstimates(), nil)
+			for i, tool := range tt.want {
+				if ranked[i].Tool != tool {
+					t.Errorf("Rank()[%d] = %s, want %s", i, ranked[i].Tool, tool)
+				}
+			}
+		})
+	}
+}
+
+// TestFastestAndBestQualityDisagree checks the two strategies aren't the
+// same ranking under different names
+func TestFastestAndBestQualityDisagree(t *testing.T) {
+	quality := (&BestQualityStrategy{}).Rank(strategyEstimates(), nil)
+	fastest := (&FastestStrategy{}).Rank(strategyEstimates(), nil)
+	if quality[0].Tool == fastest[0].Tool {
+		t.Errorf("best-quality and fastest both pick %s without timings", quality[0].Tool)
+	}
+
+	timed := &FastestStrategy{}
+	timed.SetDurations(map[trackers.ToolType]time.Duration{
+		trackers.ClaudeCodeTool: 3 * time.Minute,
+		trackers.CodexTool:      time.Minute,
+		trackers.OpenCodeTool:   2 * time.Minute,
+	})
+	if fastest := timed.Rank(strategyEstimates(), nil); quality[0].Tool == fastest[0].Tool {
+		t.Errorf("best-quality and fastest both pick %s with timings", quality[0].Tool)
+	}
+}
+
+func TestStrategyRankKeepsAvailabilityFirst(t *testing.T) {
+	estimates := strategyEstimates()
+	estimates[0].IsAvailable = false // claude
+
+	strategy, _ := GetStrategy(StrategyBestQuality, "")
+	ranked := strategy.Rank(estimates, nil)
+	if ranked[0].Tool != trackers.CodexTool {
+		t.Errorf("Rank()[0] = %s, want %s", ranked[0].Tool, trackers.CodexTool)
+	}
+	if ranked[len(ranked)-1].Tool != trackers.ClaudeCodeTool {
+		t.Errorf("unavailable tool should be ranked last, got %s", ranked[len(ranked)-1].Tool)
+	}
+}
+
+func TestRoundRobinStrategy(t *testing.T) {
+	dir := t.TempDir()
+	strategy, err := GetStrategy(StrategyRoundRobin, dir)
+	if err != nil {
+		t.Fatalf("GetStrategy() error = %v", err)
+	}
+	rr := strategy.(*RoundRobinStrategy)
+
+	want := []trackers.ToolType{trackers.ClaudeCodeTool, trackers.CodexTool, trackers.OpenCodeTool, trackers.ClaudeCodeTool}
+	for i, tool := range want {
+		ranked := rr.Rank(strategyEstimates(), nil)
+		if ranked[0].Tool != tool {
+			t.Fatalf("selection %d = %s, want %s", i+1, ranked[0].Tool, tool)
+		}
+		if err := rr.RecordSelection(ranked[0].Tool); err != nil {
+			t.Fatalf("RecordSelection() error = %v", err)
+		}
+	}
+
+	// A new instance continues the rotation from the state file
+	restored := NewRoundRobinStrategy(filepath.Join(dir, roundRobinStateFileName))
+	if got := restored.Rank(strategyEstimates(), nil)[0].Tool; got != trackers.CodexTool {
+		t.Errorf("restored rotation = %s, want %s", got, trackers.CodexTool)
+	}
+}
+
+func TestGetStrategyUnknown(t *testing.T) {
+	if _, err := GetStrategy("random", ""); err == nil {
+		t.Error("GetStrategy() expected error for unknown strategy")
+	}
+}
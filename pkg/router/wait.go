@@ -0,0 +1,167 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/permissions"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// DefaultPollInterval is how often trackers are re-polled while waiting
+const DefaultPollInterval = 30 * time.Second
+
+// ErrWaitTimeout is returned when capacity did not return within the maximum wait
+var ErrWaitTimeout = errors.New("gave up waiting for tool capacity")
+
+// WaitOptions configures WaitForCapacity
+type WaitOptions struct {
+	Tool         trackers.ToolType // Tool to wait for; empty waits for any tool
+	MaxWait      time.Duration     // Maximum time to wait (0 = until capacity returns)
+	PollInterval time.Duration     // How often to re-poll the trackers
+	OnTick       func(*WaitStatus) // Called with the countdown while waiting
+}
+
+// WaitStatus describes the current state of a wait
+type WaitStatus struct {
+	Tool      trackers.ToolType `json:"tool"`
+	ToolName  string            `json:"tool_name"`
+	Available float64           `json:"available_percent"`
+	ResetIn   time.Duration     `json:"reset_in"` // Countdown until the window resets (0 if unknown)
+	Waited    time.Duration     `json:"waited"`
+}
+
+// WaitForCapacity blocks until the requested tool (or any tool when none is
+// named) becomes available. The trackers are re-polled every PollInterval and
+// immediately once the expected reset time has passed. It returns the tool
+// that became available.
+func (de *DecisionEngine) WaitForCapacity(ctx context.Context, opts WaitOptions) (trackers.ToolType, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+
+	if opts.Tool != "" && de.findTracker(opts.Tool) == nil {
+		return "", fmt.Errorf("tool %s is not tracked", opts.Tool)
+	}
+	if opts.Tool != "" && de.permissions != nil {
+		if err := permissions.Check(opts.Tool, de.permissions); err != nil {
+			return "", err
+		}
+	}
+
+	// Countdown updates at most every second, but never slower than polling
+	tickInterval := time.Second
+	if opts.PollInterval < tickInterval {
+		tickInterval = opts.PollInterval
+	}
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	var deadline <-chan time.Time
+	if opts.MaxWait > 0 {
+		timer := time.NewTimer(opts.MaxWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		polledAt := time.Now()
+		status, ready := de.pollCapacity(opts.Tool)
+		if ready {
+			return status.Tool, nil
+		}
+
+		resetAt := time.Time{}
+		if status.ResetIn > 0 {
+			resetAt = polledAt.Add(status.ResetIn)
+		}
+
+		// Count down between polls
+		for {
+			status.Waited = time.Since(start)
+			if !resetAt.IsZero() {
+				status.ResetIn = time.Until(resetAt)
+				if status.ResetIn < 0 {
+					status.ResetIn = 0
+				}
+			}
+			if opts.OnTick != nil {
+				opts.OnTick(status)
+			}
+
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-deadline:
+				return "", fmt.Errorf("%w after %s", ErrWaitTimeout, opts.MaxWait)
+			case <-ticker.C:
+			}
+
+			if time.Since(polledAt) >= opts.PollInterval {
+				break
+			}
+			if !resetAt.IsZero() && !time.Now().Before(resetAt) {
+				break
+			}
+		}
+	}
+}
+
+// pollCapacity checks the trackers once. It reports whether the awaited tool
+// is available and, if not, the status of the tool whose window resets first.
+func (de *DecisionEngine) pollCapacity(tool trackers.ToolType) (*WaitStatus, bool) {
+	var soonest *WaitStatus
+
+	for _, tracker := range de.trackers {
+		if tool != "" && tracker.GetToolType() != tool {
+			continue
+		}
+		if de.permissions != nil && permissions.Check(tracker.GetToolType(), de.permissions) != nil {
+			continue // Capacity of a tool that can't be used doesn't help
+		}
+
+		status := &WaitStatus{
+			Tool:     tracker.GetToolType(),
+			ToolName: tracker.GetToolName(),
+		}
+
+		available, err := tracker.GetAvailablePercentage()
+		if err != nil {
+			continue
+		}
+		status.Available = available
+
+		isAvailable, err := tracker.IsAvailable()
+		if err == nil && isAvailable {
+			return status, true
+		}
+
+		if minutes, err := tracker.GetRemainingTime(); err == nil && minutes > 0 {
+			status.ResetIn = time.Duration(minutes) * time.Minute
+		}
+
+		if soonest == nil || (status.ResetIn > 0 && (soonest.ResetIn == 0 || status.ResetIn < soonest.ResetIn)) {
+			soonest = status
+		}
+	}
+
+	if soonest == nil {
+		// No tracker could report its state; keep waiting on the requested tool
+		soonest = &WaitStatus{Tool: tool, ToolName: string(tool)}
+	}
+
+	return soonest, false
+}
+
+// findTracker returns the tracker for a tool type, if any
+func (de *DecisionEngine) findTracker(tool trackers.ToolType) trackers.UsageTracker {
+	for _, tracker := range de.trackers {
+		if tracker.GetToolType() == tool {
+			return tracker
+		}
+	}
+	return nil
+}
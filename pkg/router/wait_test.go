@@ -0,0 +1,124 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// recoveringTracker becomes available after a number of polls
+type recoveringTracker struct {
+	toolType      trackers.ToolType
+	pollsUntilUp  int
+	remainingMins int
+	polls         int
+}
+
+func (r *recoveringTracker) GetAvailablePercentage() (float64, error) {
+	r.polls++
+	if r.polls > r.pollsUntilUp {
+		return 100, nil
+	}
+	return 1, nil
+}
+
+func (r *recoveringTracker) GetRemainingTime() (int, error) { return r.remainingMins, nil }
+
+func (r *recoveringTracker) GetTotalCost5hWindow() (float64, error) { return 0, nil }
+
+func (r *recoveringTracker) IsAvailable() (bool, error) {
+	return r.polls > r.pollsUntilUp, nil
+}
+
+func (r *recoveringTracker) GetToolName() string { return string(r.toolType) }
+
+func (r *recoveringTracker) GetToolType() trackers.ToolType { return r.toolType }
+
+func TestWaitForCapacity(t *testing.T) {
+	claude := &recoveringTracker{toolType: trackers.ClaudeCodeTool, pollsUntilUp: 1000, remainingMins: 90}
+	codex := &recoveringTracker{toolType: trackers.CodexTool, pollsUntilUp: 3, remainingMins: 30}
+	engine := NewDecisionEngine([]trackers.UsageTracker{claude, codex}, nil)
+
+	t.Run("named tool", func(t *testing.T) {
+		codex.polls = 0
+		var ticks int
+		tool, err := engine.WaitForCapacity(context.Background(), WaitOptions{
+			Tool:         trackers.CodexTool,
+			PollInterval: time.Millisecond,
+			OnTick: func(status *WaitStatus) {
+				ticks++
+				if status.Tool != trackers.CodexTool {
+					t.Errorf("countdown for %s, want codex", status.Tool)
+				}
+			},
+		})
+		if err != nil {
+			t.Fatalf("WaitForCapacity() error = %v", err)
+		}
+		if tool != trackers.CodexTool {
+			t.Errorf("WaitForCapacity() = %s, want codex", tool)
+		}
+		if ticks == 0 {
+			t.Error("OnTick was never called")
+		}
+	})
+
+	t.Run("any tool reports soonest reset", func(t *testing.T) {
+		codex.polls = 0
+		var first *WaitStatus
+		tool, err := engine.WaitForCapacity(context.Background(), WaitOptions{
+			PollInterval: time.Millisecond,
+			OnTick: func(status *WaitStatus) {
+				if first == nil {
+					copied := *status
+					first = &copied
+				}
+			},
+		})
+		if err != nil {
+			t.Fatalf("WaitForCapacity() error = %v", err)
+		}
+		if tool != trackers.CodexTool {
+			t.Errorf("WaitForCapacity() = %s, want codex", tool)
+		}
+		if first == nil || first.Tool != trackers.CodexTool {
+			t.Fatalf("first countdown = %+v, want codex (resets first)", first)
+		}
+		if first.ResetIn <= 29*time.Minute || first.ResetIn > 30*time.Minute {
+			t.Errorf("ResetIn = %v, want ~30m", first.ResetIn)
+		}
+	})
+
+	t.Run("max wait", func(t *testing.T) {
+		_, err := engine.WaitForCapacity(context.Background(), WaitOptions{
+			Tool:         trackers.ClaudeCodeTool,
+			MaxWait:      20 * time.Millisecond,
+			PollInterval: time.Millisecond,
+		})
+		if !errors.Is(err, ErrWaitTimeout) {
+			t.Errorf("WaitForCapacity() error = %v, want ErrWaitTimeout", err)
+		}
+	})
+
+	t.Run("untracked tool", func(t *testing.T) {
+		_, err := engine.WaitForCapacity(context.Background(), WaitOptions{Tool: trackers.OpenCodeTool})
+		if err == nil {
+			t.Error("WaitForCapacity() expected error for untracked tool")
+		}
+	})
+}
+
+func TestMakeDecisionNoToolsAvailable(t *testing.T) {
+	engine := NewDecisionEngine([]trackers.UsageTracker{
+		&recoveringTracker{toolType: trackers.ClaudeCodeTool, pollsUntilUp: 1000},
+	}, nil)
+
+	_, err := engine.MakeDecision(&analyzers.ComplexityAnalysis{Level: analyzers.Simple, Tokens: 150}, "")
+	if !errors.Is(err, ErrNoToolsAvailable) {
+		t.Errorf("MakeDecision() error = %v, want ErrNoToolsAvailable", err)
+	}
+}
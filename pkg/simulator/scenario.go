@@ -0,0 +1,149 @@
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/router"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// DefaultWindow is the usage window length used when a tool script omits it
+const DefaultWindow = 5 * time.Hour
+
+// DefaultWindowTokens is the token capacity of a window when a tool script omits it
+const DefaultWindowTokens = 100000
+
+// Scenario describes a recorded sequence of tasks and the tracker states they run against
+type Scenario struct {
+	Name                  string       `yaml:"name" json:"name"`
+	AvailabilityThreshold float64      `yaml:"availability_threshold" json:"availability_threshold"` // Overrides trackers.AvailabilityThreshold
+	Strategy              string       `yaml:"strategy" json:"strategy"`                             // Routing strategy (default: cheapest)
+	Tools                 []ToolScript `yaml:"tools" json:"tools"`
+	Tasks                 []TaskScript `yaml:"tasks" json:"tasks"`
+}
+
+// ToolScript describes the starting state of a tool's usage window
+type ToolScript struct {
+	Tool         string        `yaml:"tool" json:"tool"`                   // claude-code, codex, opencode
+	Name         string        `yaml:"name" json:"name"`                   // Display name (defaults to the tool type)
+	Available    float64       `yaml:"available" json:"available"`         // Available percentage at the start (0-100)
+	ResetsIn     time.Duration `yaml:"resets_in" json:"resets_in"`         // Time until the current window resets
+	Window       time.Duration `yaml:"window" json:"window"`               // Length of a full window
+	WindowTokens int           `yaml:"window_tokens" json:"window_tokens"` // Tokens that make up 100% of a window
+}
+
+// TaskScript describes a single task replayed by the simulator
+type TaskScript struct {
+	At     time.Duration `yaml:"at" json:"at"`         // Offset from the start of the scenario
+	Task   string        `yaml:"task" json:"task"`     // Task description, analyzed heuristically
+	Level  string        `yaml:"level" json:"level"`   // Optional complexity override
+	Tokens int           `yaml:"tokens" json:"tokens"` // Optional token override
+	Force  string        `yaml:"force" json:"force"`   // Optional forced tool
+}
+
+// LoadScenario reads and validates a scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario parses a YAML (or JSON) scenario and fills in defaults
+func ParseScenario(data []byte) (*Scenario, error) {
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+
+	if err := scenario.normalize(); err != nil {
+		return nil, err
+	}
+
+	return &scenario, nil
+}
+
+// normalize validates the scenario and applies defaults
+func (s *Scenario) normalize() error {
+	if len(s.Tools) == 0 {
+		return fmt.Errorf("scenario must define at least one tool")
+	}
+	if len(s.Tasks) == 0 {
+		return fmt.Errorf("scenario must define at least one task")
+	}
+
+	if s.AvailabilityThreshold <= 0 {
+		s.AvailabilityThreshold = trackers.AvailabilityThreshold
+	}
+
+	if s.Strategy == "" {
+		s.Strategy = router.DefaultStrategy
+	}
+	if _, err := router.GetStrategy(s.Strategy, ""); err != nil {
+		return err
+	}
+
+	seen := make(map[trackers.ToolType]bool)
+	for i := range s.Tools {
+		tool := &s.Tools[i]
+		toolType, err := trackers.ValidateToolType(tool.Tool)
+		if err != nil {
+			return fmt.Errorf("tool %d: %w", i+1, err)
+		}
+		if seen[toolType] {
+			return fmt.Errorf("tool %s is defined more than once", toolType)
+		}
+		seen[toolType] = true
+		tool.Tool = string(toolType)
+
+		if tool.Name == "" {
+			tool.Name = string(toolType)
+		}
+		if tool.Available < 0 || tool.Available > 100 {
+			return fmt.Errorf("tool %s: available must be between 0 and 100", toolType)
+		}
+		if tool.Window <= 0 {
+			tool.Window = DefaultWindow
+		}
+		if tool.ResetsIn <= 0 || tool.ResetsIn > tool.Window {
+			tool.ResetsIn = tool.Window
+		}
+		if tool.WindowTokens <= 0 {
+			tool.WindowTokens = DefaultWindowTokens
+		}
+	}
+
+	for i := range s.Tasks {
+		task := &s.Tasks[i]
+		if task.Task == "" {
+			return fmt.Errorf("task %d: task description cannot be empty", i+1)
+		}
+		if task.At < 0 {
+			return fmt.Errorf("task %d: at cannot be negative", i+1)
+		}
+		switch analyzers.ComplexityLevel(task.Level) {
+		case "", analyzers.Simple, analyzers.Medium, analyzers.Complex:
+		default:
+			return fmt.Errorf("task %d: invalid level %q: must be one of [simple, medium, complex]", i+1, task.Level)
+		}
+		if task.Force != "" {
+			if _, err := trackers.ValidateToolType(task.Force); err != nil {
+				return fmt.Errorf("task %d: %w", i+1, err)
+			}
+		}
+	}
+
+	// Replay tasks in chronological order, keeping file order for ties
+	sort.SliceStable(s.Tasks, func(i, j int) bool {
+		return s.Tasks[i].At < s.Tasks[j].At
+	})
+
+	return nil
+}
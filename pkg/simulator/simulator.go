@@ -0,0 +1,216 @@
+package simulator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/analyzers"
+	"github.com/crlian/ai-dispatcher/pkg/router"
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// TaskOutcome is the routing result for a single replayed task
+type TaskOutcome struct {
+	At             time.Duration             `json:"at"`
+	Task           string                    `json:"task"`
+	Level          analyzers.ComplexityLevel `json:"level"`
+	Tokens         int                       `json:"tokens"`
+	Tool           trackers.ToolType         `json:"tool,omitempty"`
+	ToolName       string                    `json:"tool_name,omitempty"`
+	EstimatedCost  float64                   `json:"estimated_cost"`
+	AvailableAfter float64                   `json:"available_after"`
+	WasForced      bool                      `json:"was_forced"`
+	Error          string                    `json:"error,omitempty"`
+}
+
+// Exhaustion records the moment a tool's window dropped below the threshold
+type Exhaustion struct {
+	Tool     trackers.ToolType `json:"tool"`
+	ToolName string            `json:"tool_name"`
+	At       time.Duration     `json:"at"`
+	ResetsAt time.Duration     `json:"resets_at"`
+	Task     string            `json:"task"`
+}
+
+// ToolSummary aggregates the projected usage of a tool over the scenario
+type ToolSummary struct {
+	Tool           trackers.ToolType `json:"tool"`
+	ToolName       string            `json:"tool_name"`
+	Tasks          int               `json:"tasks"`
+	Tokens         int               `json:"tokens"`
+	Spend          float64           `json:"spend"`
+	FinalAvailable float64           `json:"final_available"`
+	Exhaustions    int               `json:"exhaustions"`
+}
+
+// Report is the complete result of a simulation run
+type Report struct {
+	Scenario    string         `json:"scenario"`
+	Strategy    string         `json:"strategy"`
+	Threshold   float64        `json:"availability_threshold"`
+	Outcomes    []*TaskOutcome `json:"outcomes"`
+	Exhaustions []*Exhaustion  `json:"exhaustions"`
+	Tools       []*ToolSummary `json:"tools"`
+	TotalSpend  float64        `json:"total_spend"`
+	Unrouted    int            `json:"unrouted"`
+}
+
+// Simulator replays a scenario against scripted trackers
+type Simulator struct {
+	scenario *Scenario
+	clock    *Clock
+	trackers []*ScriptedTracker
+}
+
+// NewSimulator creates a simulator for a validated scenario
+func NewSimulator(scenario *Scenario) *Simulator {
+	clock := &Clock{}
+	scripted := make([]*ScriptedTracker, 0, len(scenario.Tools))
+	for _, tool := range scenario.Tools {
+		scripted = append(scripted, NewScriptedTracker(tool, clock, scenario.AvailabilityThreshold))
+	}
+
+	return &Simulator{
+		scenario: scenario,
+		clock:    clock,
+		trackers: scripted,
+	}
+}
+
+// Run replays every task in order and returns the projected outcome
+func (s *Simulator) Run() (*Report, error) {
+	usageTrackers := make([]trackers.UsageTracker, 0, len(s.trackers))
+	byType := make(map[trackers.ToolType]*ScriptedTracker)
+	summaries := make(map[trackers.ToolType]*ToolSummary)
+	for _, t := range s.trackers {
+		usageTrackers = append(usageTrackers, t)
+		byType[t.GetToolType()] = t
+		summaries[t.GetToolType()] = &ToolSummary{
+			Tool:     t.GetToolType(),
+			ToolName: t.GetToolName(),
+		}
+	}
+
+	// Stateful strategies keep their state in memory for the simulation only
+	strategy, err := router.GetStrategy(s.scenario.Strategy, "")
+	if err != nil {
+		return nil, err
+	}
+	engine := router.NewDecisionEngine(usageTrackers, strategy)
+	// Scenarios are replayed offline, so the working directory is not inspected
+	analyzer := analyzers.NewComplexityAnalyzer()
+	analyzer.SetWorkDir("")
+
+	report := &Report{
+		Scenario:  s.scenario.Name,
+		Strategy:  strategy.Name(),
+		Threshold: s.scenario.AvailabilityThreshold,
+	}
+
+	// Track which window each tool was last exhausted in to report it once
+	exhaustedWindow := make(map[trackers.ToolType]time.Duration)
+
+	for _, task := range s.scenario.Tasks {
+		s.clock.Advance(task.At)
+
+		analysis, err := s.analyze(analyzer, task)
+		if err != nil {
+			return nil, err
+		}
+
+		outcome := &TaskOutcome{
+			At:     task.At,
+			Task:   task.Task,
+			Level:  analysis.Level,
+			Tokens: analysis.Tokens,
+		}
+		report.Outcomes = append(report.Outcomes, outcome)
+
+		decision, err := engine.MakeDecision(analysis, task.Force)
+		if err != nil {
+			outcome.Error = err.Error()
+			report.Unrouted++
+			continue
+		}
+
+		if err := engine.CommitDecision(decision); err != nil {
+			return nil, err
+		}
+
+		tracker := byType[decision.SelectedTool]
+		cost := 0.0
+		if decision.SelectedCost != nil {
+			cost = decision.SelectedCost.EstimatedCost
+		}
+		tracker.Consume(analysis.Tokens, cost)
+
+		available, _ := tracker.GetAvailablePercentage()
+		outcome.Tool = decision.SelectedTool
+		outcome.ToolName = decision.SelectedName
+		outcome.EstimatedCost = cost
+		outcome.AvailableAfter = available
+		outcome.WasForced = decision.WasForced
+
+		summary := summaries[decision.SelectedTool]
+		summary.Tasks++
+		summary.Tokens += analysis.Tokens
+		summary.Spend += cost
+		report.TotalSpend += cost
+
+		resetAt := tracker.ResetAt()
+		if available < s.scenario.AvailabilityThreshold && exhaustedWindow[tracker.GetToolType()] != resetAt {
+			exhaustedWindow[tracker.GetToolType()] = resetAt
+			summary.Exhaustions++
+			report.Exhaustions = append(report.Exhaustions, &Exhaustion{
+				Tool:     tracker.GetToolType(),
+				ToolName: tracker.GetToolName(),
+				At:       s.clock.Now(),
+				ResetsAt: resetAt,
+				Task:     task.Task,
+			})
+		}
+	}
+
+	for _, t := range s.trackers {
+		summary := summaries[t.GetToolType()]
+		summary.FinalAvailable, _ = t.GetAvailablePercentage()
+		report.Tools = append(report.Tools, summary)
+	}
+
+	return report, nil
+}
+
+// analyze builds the complexity analysis for a task, honoring overrides
+func (s *Simulator) analyze(analyzer *analyzers.ComplexityAnalyzer, task TaskScript) (*analyzers.ComplexityAnalysis, error) {
+	analysis, err := analyzer.AnalyzeComplexity(task.Task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze task %q: %w", task.Task, err)
+	}
+
+	if task.Level != "" {
+		analysis.Level = analyzers.ComplexityLevel(task.Level)
+		analysis.Method = "scenario"
+		analysis.Confidence = 1.0
+		if task.Tokens == 0 {
+			analysis.Tokens = defaultTokensForLevel(analysis.Level)
+		}
+	}
+	if task.Tokens > 0 {
+		analysis.Tokens = task.Tokens
+		analysis.Method = "scenario"
+	}
+
+	return analysis, nil
+}
+
+// defaultTokensForLevel mirrors the heuristic analyzer's token estimates
+func defaultTokensForLevel(level analyzers.ComplexityLevel) int {
+	switch level {
+	case analyzers.Simple:
+		return 150
+	case analyzers.Complex:
+		return 1500
+	default:
+		return 500
+	}
+}
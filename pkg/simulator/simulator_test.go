@@ -0,0 +1,167 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+const testScenario = `
+name: test
+tools:
+  - tool: claude-code
+    name: Claude Code
+    available: 50
+    resets_in: 1h
+    window_tokens: 2000
+tasks:
+  - at: 30m
+    task: "refactor the entire authentication system"
+  - at: 0m
+    task: "fix typo in README"
+  - at: 40m
+    task: "implement new billing service"
+  - at: 2h
+    task: "rename variable"
+`
+
+func TestParseScenario(t *testing.T) {
+	scenario, err := ParseScenario([]byte(testScenario))
+	if err != nil {
+		t.Fatalf("ParseScenario() error = %v", err)
+	}
+
+	if scenario.AvailabilityThreshold != trackers.AvailabilityThreshold {
+		t.Errorf("AvailabilityThreshold = %v, want default %v", scenario.AvailabilityThreshold, trackers.AvailabilityThreshold)
+	}
+
+	if scenario.Tools[0].Window != DefaultWindow {
+		t.Errorf("Window = %v, want default %v", scenario.Tools[0].Window, DefaultWindow)
+	}
+
+	// Tasks must be sorted chronologically
+	for i := 1; i < len(scenario.Tasks); i++ {
+		if scenario.Tasks[i].At < scenario.Tasks[i-1].At {
+			t.Fatalf("tasks not sorted: %v before %v", scenario.Tasks[i-1].At, scenario.Tasks[i].At)
+		}
+	}
+}
+
+func TestParseScenarioErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario string
+	}{
+		{
+			name:     "no tools",
+			scenario: "tasks:\n  - task: fix bug\n",
+		},
+		{
+			name:     "no tasks",
+			scenario: "tools:\n  - tool: codex\n",
+		},
+		{
+			name:     "invalid tool",
+			scenario: "tools:\n  - tool: cursor\ntasks:\n  - task: fix bug\n",
+		},
+		{
+			name:     "invalid level",
+			scenario: "tools:\n  - tool: codex\ntasks:\n  - task: fix bug\n    level: huge\n",
+		},
+		{
+			name:     "duplicate tool",
+			scenario: "tools:\n  - tool: codex\n  - tool: codex\ntasks:\n  - task: fix bug\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseScenario([]byte(tt.scenario)); err == nil {
+				t.Error("ParseScenario() expected error but got none")
+			}
+		})
+	}
+}
+
+func TestSimulatorRun(t *testing.T) {
+	scenario, err := ParseScenario([]byte(testScenario))
+	if err != nil {
+		t.Fatalf("ParseScenario() error = %v", err)
+	}
+
+	report, err := NewSimulator(scenario).Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(report.Outcomes) != 4 {
+		t.Fatalf("Outcomes = %d, want 4", len(report.Outcomes))
+	}
+
+	// 50% of 2000 tokens left; the 150-token simple task fits
+	first := report.Outcomes[0]
+	if first.Tool != trackers.ClaudeCodeTool {
+		t.Errorf("first task routed to %q, want claude-code", first.Tool)
+	}
+	if first.EstimatedCost <= 0 {
+		t.Errorf("first task cost = %v, want > 0", first.EstimatedCost)
+	}
+
+	// The complex task exhausts the window
+	if len(report.Exhaustions) != 1 {
+		t.Fatalf("Exhaustions = %d, want 1", len(report.Exhaustions))
+	}
+	if report.Exhaustions[0].At != 30*time.Minute || report.Exhaustions[0].ResetsAt != time.Hour {
+		t.Errorf("exhaustion at %v resets %v, want 30m / 1h", report.Exhaustions[0].At, report.Exhaustions[0].ResetsAt)
+	}
+
+	// The next task cannot be routed until the window resets
+	if report.Outcomes[2].Error == "" {
+		t.Error("third task should be unrouted while the window is exhausted")
+	}
+	if report.Unrouted != 1 {
+		t.Errorf("Unrouted = %d, want 1", report.Unrouted)
+	}
+
+	// After the reset at 1h the last task is routed on a fresh window
+	last := report.Outcomes[3]
+	if last.Error != "" {
+		t.Errorf("last task error = %q, want routed after reset", last.Error)
+	}
+	if last.AvailableAfter < 90 {
+		t.Errorf("last task available after = %v, want fresh window", last.AvailableAfter)
+	}
+
+	if report.Tools[0].Tasks != 3 {
+		t.Errorf("Tasks = %d, want 3", report.Tools[0].Tasks)
+	}
+}
+
+func TestScriptedTrackerThreshold(t *testing.T) {
+	clock := &Clock{}
+	tracker := NewScriptedTracker(ToolScript{
+		Tool:         string(trackers.CodexTool),
+		Name:         "Codex",
+		Available:    30,
+		ResetsIn:     time.Hour,
+		Window:       5 * time.Hour,
+		WindowTokens: 1000,
+	}, clock, 40)
+
+	available, _ := tracker.IsAvailable()
+	if available {
+		t.Error("IsAvailable() = true, want false below custom threshold")
+	}
+
+	clock.Advance(time.Hour)
+	available, _ = tracker.IsAvailable()
+	if !available {
+		t.Error("IsAvailable() = false, want true after window reset")
+	}
+
+	remaining, _ := tracker.GetRemainingTime()
+	if remaining != 300 {
+		t.Errorf("GetRemainingTime() = %d, want 300", remaining)
+	}
+}
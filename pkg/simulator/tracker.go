@@ -0,0 +1,117 @@
+package simulator
+
+import (
+	"time"
+
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// Clock is the simulated time shared by all scripted trackers
+type Clock struct {
+	now time.Duration
+}
+
+// Now returns the current offset from the start of the scenario
+func (c *Clock) Now() time.Duration {
+	return c.now
+}
+
+// Advance moves the clock forward to the given offset (never backwards)
+func (c *Clock) Advance(to time.Duration) {
+	if to > c.now {
+		c.now = to
+	}
+}
+
+// ScriptedTracker is a UsageTracker whose state is driven by a scenario
+// instead of a real usage API. Capacity is consumed as tasks are routed to it
+// and restored whenever its window resets on the simulated clock.
+type ScriptedTracker struct {
+	toolName     string
+	toolType     trackers.ToolType
+	clock        *Clock
+	threshold    float64
+	window       time.Duration
+	windowTokens int
+	usedTokens   int
+	cost         float64
+	resetAt      time.Duration
+}
+
+// NewScriptedTracker creates a tracker from a tool script
+func NewScriptedTracker(script ToolScript, clock *Clock, threshold float64) *ScriptedTracker {
+	used := int(float64(script.WindowTokens) * (100 - script.Available) / 100.0)
+	return &ScriptedTracker{
+		toolName:     script.Name,
+		toolType:     trackers.ToolType(script.Tool),
+		clock:        clock,
+		threshold:    threshold,
+		window:       script.Window,
+		windowTokens: script.WindowTokens,
+		usedTokens:   used,
+		resetAt:      script.ResetsIn,
+	}
+}
+
+// GetAvailablePercentage returns the remaining capacity of the current window
+func (t *ScriptedTracker) GetAvailablePercentage() (float64, error) {
+	t.roll()
+	available := 100.0 - float64(t.usedTokens)/float64(t.windowTokens)*100.0
+	if available < 0 {
+		available = 0
+	}
+	return available, nil
+}
+
+// GetRemainingTime returns the minutes left until the window resets
+func (t *ScriptedTracker) GetRemainingTime() (int, error) {
+	t.roll()
+	return int((t.resetAt - t.clock.Now()).Minutes()), nil
+}
+
+// GetTotalCost5hWindow returns the simulated spend in the current window
+func (t *ScriptedTracker) GetTotalCost5hWindow() (float64, error) {
+	t.roll()
+	return t.cost, nil
+}
+
+// IsAvailable returns true if the tool is above the scenario's threshold
+func (t *ScriptedTracker) IsAvailable() (bool, error) {
+	available, err := t.GetAvailablePercentage()
+	if err != nil {
+		return false, err
+	}
+	return available >= t.threshold, nil
+}
+
+// GetToolName returns the tool name
+func (t *ScriptedTracker) GetToolName() string {
+	return t.toolName
+}
+
+// GetToolType returns the tool type
+func (t *ScriptedTracker) GetToolType() trackers.ToolType {
+	return t.toolType
+}
+
+// Consume records a routed task against the current window
+func (t *ScriptedTracker) Consume(tokens int, cost float64) {
+	t.roll()
+	t.usedTokens += tokens
+	t.cost += cost
+}
+
+// ResetAt returns the offset at which the current window resets
+func (t *ScriptedTracker) ResetAt() time.Duration {
+	t.roll()
+	return t.resetAt
+}
+
+// roll starts new windows for every reset that has passed on the clock
+func (t *ScriptedTracker) roll() {
+	for t.clock.Now() >= t.resetAt {
+		t.resetAt += t.window
+		t.usedTokens = 0
+		t.cost = 0
+	}
+}
@@ -0,0 +1,254 @@
+package tokenizer
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// Tokenizer counts the tokens a model would see for a piece of text
+type Tokenizer interface {
+	// Name returns the tokenizer name (cl100k, claude)
+	Name() string
+
+	// Count returns the number of tokens in text
+	Count(text string) int
+}
+
+// approxBPE approximates a byte-pair encoding tokenizer without a vocabulary.
+// Text is pre-tokenized the way cl100k splits it (words with their leading
+// space, digit groups, punctuation runs, whitespace runs) and each piece is
+// costed from its length and how many UTF-8 bytes its characters take.
+type approxBPE struct {
+	name            string
+	lettersPerToken float64 // ASCII letters merged into one token inside a word
+	digitsPerToken  float64 // Digits grouped into one token
+	symbolsPerToken float64 // ASCII punctuation merged into one token
+	spacesPerToken  float64 // Spaces merged into one token
+	twoByteCost     float64 // Tokens per 2-byte rune (accented Latin, Cyrillic, Greek)
+	threeByteCost   float64 // Tokens per 3-byte rune (CJK, most other scripts)
+	fourByteCost    float64 // Tokens per 4-byte rune (emoji, rare CJK)
+}
+
+var (
+	// cl100k approximates the OpenAI cl100k_base encoding
+	cl100k = &approxBPE{
+		name:            "cl100k",
+		lettersPerToken: 8,
+		digitsPerToken:  3,
+		symbolsPerToken: 2,
+		spacesPerToken:  8,
+		twoByteCost:     0.5,
+		threeByteCost:   1,
+		fourByteCost:    2,
+	}
+
+	// claude approximates the Claude tokenizer, which splits words and
+	// punctuation more finely than cl100k
+	claude = &approxBPE{
+		name:            "claude",
+		lettersPerToken: 6,
+		digitsPerToken:  3,
+		symbolsPerToken: 1.5,
+		spacesPerToken:  4,
+		twoByteCost:     0.6,
+		threeByteCost:   1.2,
+		fourByteCost:    2,
+	}
+)
+
+// CL100K returns the cl100k-style tokenizer
+func CL100K() Tokenizer { return cl100k }
+
+// Claude returns the Claude-like tokenizer
+func Claude() Tokenizer { return claude }
+
+// Default returns the tokenizer used when the target tool is not known
+func Default() Tokenizer { return cl100k }
+
+// ForTool returns the tokenizer that best matches a tool's model
+func ForTool(toolType trackers.ToolType) Tokenizer {
+	switch toolType {
+	case trackers.ClaudeCodeTool:
+		return claude
+	default:
+		return cl100k
+	}
+}
+
+// Get returns a tokenizer by name, or nil if the name is unknown
+func Get(name string) Tokenizer {
+	switch strings.ToLower(name) {
+	case cl100k.name:
+		return cl100k
+	case claude.name:
+		return claude
+	default:
+		return nil
+	}
+}
+
+// Name returns the tokenizer name
+func (t *approxBPE) Name() string { return t.name }
+
+// Count returns the approximate number of tokens in text
+func (t *approxBPE) Count(text string) int {
+	total := 0
+	n := len(text)
+
+	for i := 0; i < n; {
+		r, size := rune(text[i]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(text[i:])
+		}
+
+		switch {
+		case isLetter(r):
+			var tokens int
+			i, tokens = t.scanWord(text, i)
+			total += tokens
+
+		case r >= '0' && r <= '9':
+			j := i
+			for j < n && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			total += ceilDiv(float64(j-i), t.digitsPerToken)
+			i = j
+
+		case isSpace(r):
+			var tokens int
+			i, tokens = t.scanSpace(text, i)
+			total += tokens
+
+		case r < utf8.RuneSelf:
+			j := i
+			for j < n && text[j] < utf8.RuneSelf && isSymbol(rune(text[j])) {
+				j++
+			}
+			total += ceilDiv(float64(j-i), t.symbolsPerToken)
+			i = j
+
+		default:
+			// Non-ASCII symbols and emoji are costed by their encoded size
+			total += int(math.Ceil(t.runeCost(size)))
+			i += size
+		}
+	}
+
+	return total
+}
+
+// scanWord consumes a run of letters and returns its token count. Mixed-case
+// identifiers are split at lower-to-upper transitions (DecisionEngine is
+// costed as Decision + Engine), which is where BPE merges usually stop.
+func (t *approxBPE) scanWord(text string, i int) (int, int) {
+	tokens := 0
+	part := 0.0
+	prevLower := false
+
+	for i < len(text) {
+		r, size := rune(text[i]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(text[i:])
+		}
+		if !isLetter(r) {
+			break
+		}
+
+		if prevLower && r >= 'A' && r <= 'Z' {
+			tokens += max(1, int(math.Ceil(part)))
+			part = 0
+		}
+		prevLower = unicode.IsLower(r)
+
+		if size == 1 {
+			part += 1 / t.lettersPerToken
+		} else {
+			part += t.runeCost(size)
+		}
+		i += size
+	}
+
+	tokens += max(1, int(math.Ceil(part)))
+	return i, tokens
+}
+
+// scanSpace consumes a run of whitespace and returns its token count.
+// Everything up to the last newline is one token, and a single space right
+// before a word or punctuation is merged into that token instead.
+func (t *approxBPE) scanSpace(text string, i int) (int, int) {
+	j := i
+	lastNewline := -1
+	for j < len(text) {
+		r, size := rune(text[j]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(text[j:])
+		}
+		if !isSpace(r) {
+			break
+		}
+		if r == '\n' || r == '\r' {
+			lastNewline = j
+		}
+		j += size
+	}
+
+	tokens := 0
+	start := i
+	if lastNewline >= 0 {
+		tokens++
+		start = lastNewline + 1
+	}
+
+	spaces := j - start
+	if spaces > 0 && j < len(text) && text[j-1] == ' ' && !(text[j] >= '0' && text[j] <= '9') {
+		spaces--
+	}
+	if spaces > 0 {
+		tokens += ceilDiv(float64(spaces), t.spacesPerToken)
+	}
+
+	return j, tokens
+}
+
+// runeCost returns the token cost of a multi-byte rune
+func (t *approxBPE) runeCost(size int) float64 {
+	switch size {
+	case 2:
+		return t.twoByteCost
+	case 3:
+		return t.threeByteCost
+	default:
+		return t.fourByteCost
+	}
+}
+
+// isLetter reports whether r is part of a word
+func isLetter(r rune) bool {
+	if r < utf8.RuneSelf {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	}
+	return unicode.IsLetter(r) || unicode.Is(unicode.Mn, r)
+}
+
+// isSpace reports whether r is whitespace
+func isSpace(r rune) bool {
+	if r < utf8.RuneSelf {
+		return r == ' ' || r == '\n' || r == '\t' || r == '\r' || r == '\v' || r == '\f'
+	}
+	return unicode.IsSpace(r)
+}
+
+// isSymbol reports whether an ASCII rune is punctuation
+func isSymbol(r rune) bool {
+	return !isLetter(r) && !isSpace(r) && !(r >= '0' && r <= '9')
+}
+
+// ceilDiv divides and rounds up
+func ceilDiv(n, per float64) int {
+	return int(math.Ceil(n / per))
+}
@@ -0,0 +1,118 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crlian/ai-dispatcher/pkg/trackers"
+)
+
+// codeSample is a small Go snippet used to compare tokenizers
+const codeSample = `func (de *DecisionEngine) MakeDecision(analysis *analyzers.ComplexityAnalysis, forceTool string) (*RoutingDecision, error) {
+	if forceTool != "" {
+		return de.handleForcedTool(analysis, forceTool)
+	}
+	estimates, err := de.calculator.CalculateCosts(analysis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate costs: %w", err)
+	}
+	return de.pick(estimates), nil
+}
+`
+
+func TestCL100KCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{name: "empty text", text: "", expected: 0},
+		{name: "single word", text: "hello", expected: 1},
+		{name: "sentence", text: "This is a test string with some words", expected: 8},
+		{name: "repeated words", text: strings.Repeat("test ", 100), expected: 101},
+		{name: "code line", text: "func main() {", expected: 4},
+		{name: "camel case identifier", text: "DecisionEngine", expected: 2},
+		{name: "digit groups", text: "12345", expected: 2},
+		{name: "accented word", text: "código", expected: 2},
+		{name: "newline and indentation", text: "\n\n    return", expected: 3},
+		{name: "CJK characters", text: "你好", expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CL100K().Count(tt.text); got != tt.expected {
+				t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClaudeCountsMoreThanCL100K(t *testing.T) {
+	samples := []string{
+		codeSample,
+		"Refactoriza el servicio de autenticación para usar JWT en lugar de sesiones",
+		"Implement a comprehensive internationalization layer for the configuration subsystem",
+	}
+
+	for _, sample := range samples {
+		cl, cy := CL100K().Count(sample), Claude().Count(sample)
+		if cy < cl {
+			t.Errorf("Claude().Count() = %d, want >= CL100K().Count() = %d for %q", cy, cl, sample)
+		}
+	}
+}
+
+func TestCountDiffersFromCharacterEstimate(t *testing.T) {
+	// Multi-byte text was badly undercounted by len/4 on runes and overcounted on bytes
+	spanish := "¿Cuál es la mejor opción para la autenticación?"
+	got := CL100K().Count(spanish)
+	if got < 9 || got > 16 {
+		t.Errorf("Count(%q) = %d, want between 9 and 16", spanish, got)
+	}
+}
+
+func TestForTool(t *testing.T) {
+	tests := []struct {
+		tool trackers.ToolType
+		want string
+	}{
+		{trackers.ClaudeCodeTool, "claude"},
+		{trackers.CodexTool, "cl100k"},
+		{trackers.OpenCodeTool, "cl100k"},
+		{trackers.ToolType("unknown"), "cl100k"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.tool), func(t *testing.T) {
+			if got := ForTool(tt.tool).Name(); got != tt.want {
+				t.Errorf("ForTool(%s) = %s, want %s", tt.tool, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	if Get("cl100k") != CL100K() || Get("Claude") != Claude() {
+		t.Error("Get() should return the bundled tokenizers by name")
+	}
+	if Get("gpt2") != nil {
+		t.Error("Get() should return nil for unknown tokenizers")
+	}
+}
+
+// largePrompt is roughly a 1 MB prompt mixing prose and code
+var largePrompt = strings.Repeat(codeSample+"Explica por qué la función devuelve un error cuando el análisis falla.\n", 2500)
+
+func BenchmarkCL100KCount(b *testing.B) {
+	b.SetBytes(int64(len(largePrompt)))
+	for i := 0; i < b.N; i++ {
+		CL100K().Count(largePrompt)
+	}
+}
+
+func BenchmarkClaudeCount(b *testing.B) {
+	b.SetBytes(int64(len(largePrompt)))
+	for i := 0; i < b.N; i++ {
+		Claude().Count(largePrompt)
+	}
+}
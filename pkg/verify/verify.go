@@ -0,0 +1,145 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// MaxOutputBytes is how much of a command's output is kept. The end is kept,
+// since that's where build and test failures are reported.
+const MaxOutputBytes = 10000
+
+// Result is the outcome of one verification command
+type Result struct {
+	Command  string        `json:"command"`
+	Passed   bool          `json:"passed"`
+	ExitCode int           `json:"exit_code"`
+	Output   string        `json:"output,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Run runs a command line through the shell in dir. A command that can't be
+// started or times out fails like one that exits non-zero.
+func Run(ctx context.Context, dir, command string) *Result {
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Don't wait for background processes holding the output open after a kill
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	result := &Result{
+		Command:  command,
+		Passed:   err == nil,
+		Output:   Tail(output.String(), MaxOutputBytes),
+		Duration: time.Since(start),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		result.ExitCode = -1
+		result.Output = strings.TrimSpace(result.Output + "\n" + fmt.Sprintf("%s: %v", command, ctx.Err()))
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		result.ExitCode = -1
+		result.Output = strings.TrimSpace(result.Output + "\n" + err.Error())
+	}
+	return result
+}
+
+// RunAll runs the commands in order, stopping at the first that fails
+func RunAll(ctx context.Context, dir string, commands []string) []*Result {
+	var results []*Result
+	for _, command := range commands {
+		result := Run(ctx, dir, command)
+		results = append(results, result)
+		if !result.Passed {
+			break
+		}
+	}
+	return results
+}
+
+// Passed reports whether every command passed
+func Passed(results []*Result) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// FailurePrompt appends the output of the failed commands to a task, so a
+// tool can be asked to fix what the previous attempt broke
+func FailurePrompt(task string, results []*Result) string {
+	var b strings.Builder
+	b.WriteString(task)
+	b.WriteString("\n\nThe previous attempt at this task didn't pass verification. ")
+	b.WriteString("Its changes are still in the working tree; fix the failures below.\n")
+	for _, r := range results {
+		if r.Passed {
+			continue
+		}
+		fmt.Fprintf(&b, "\n<verification command=%q exit_code=\"%d\">\n%s\n</verification>\n",
+			r.Command, r.ExitCode, strings.TrimRight(r.Output, "\n"))
+	}
+	return b.String()
+}
+
+// DetectTestCommand guesses the test command from the project files in dir
+func DetectTestCommand(dir string) string {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	switch {
+	case exists("go.mod"):
+		return "go test ./..."
+	case exists("Cargo.toml"):
+		return "cargo test"
+	case exists("package.json"):
+		return "npm test"
+	case exists("pyproject.toml"), exists("pytest.ini"), exists("setup.py"):
+		return "pytest"
+	case exists("Makefile"):
+		return "make test"
+	default:
+		return ""
+	}
+}
+
+// Tail keeps the last maxBytes of output, starting at a line boundary
+func Tail(output string, maxBytes int) string {
+	if len(output) <= maxBytes {
+		return output
+	}
+	cut := output[len(output)-maxBytes:]
+	if i := strings.IndexByte(cut, '\n'); i >= 0 && i < len(cut)-1 {
+		cut = cut[i+1:]
+	}
+	return "...(truncated)\n" + cut
+}
+
+// shellCommand runs a command line through the platform's shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,130 @@
+package verify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		passed   bool
+		exitCode int
+		output   string
+	}{
+		{"passes", "echo ok", true, 0, "ok\n"},
+		{"fails with exit code", "echo broken >&2; exit 3", false, 3, "broken\n"},
+		{"runs in dir", "cat marker.txt", true, 0, "here"},
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "marker.txt"), []byte("here"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Run(context.Background(), dir, tt.command)
+			if result.Passed != tt.passed {
+				t.Errorf("Passed = %v, want %v", result.Passed, tt.passed)
+			}
+			if result.ExitCode != tt.exitCode {
+				t.Errorf("ExitCode = %d, want %d", result.ExitCode, tt.exitCode)
+			}
+			if result.Output != tt.output {
+				t.Errorf("Output = %q, want %q", result.Output, tt.output)
+			}
+		})
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result := Run(ctx, t.TempDir(), "sleep 5")
+	if result.Passed {
+		t.Fatal("Passed = true for a command that timed out")
+	}
+	if result.ExitCode != -1 {
+		t.Errorf("ExitCode = %d, want -1", result.ExitCode)
+	}
+	if !strings.Contains(result.Output, "deadline exceeded") {
+		t.Errorf("Output = %q, want the timeout", result.Output)
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	results := RunAll(context.Background(), t.TempDir(), []string{"true", "false", "echo never"})
+	if len(results) != 2 {
+		t.Fatalf("ran %d commands, want 2 (stop at the first failure)", len(results))
+	}
+	if Passed(results) {
+		t.Error("Passed() = true, want false")
+	}
+	if !Passed(RunAll(context.Background(), t.TempDir(), []string{"true", "true"})) {
+		t.Error("Passed() = false for passing commands")
+	}
+}
+
+func TestFailurePrompt(t *testing.T) {
+	results := []*Result{
+		{Command: "go build ./...", Passed: true},
+		{Command: "go test ./...", ExitCode: 1, Output: "--- FAIL: TestLogin\n"},
+	}
+
+	prompt := FailurePrompt("fix the login", results)
+	if !strings.HasPrefix(prompt, "fix the login\n\n") {
+		t.Errorf("prompt doesn't start with the task: %q", prompt)
+	}
+	if !strings.Contains(prompt, "<verification command=\"go test ./...\" exit_code=\"1\">\n--- FAIL: TestLogin\n</verification>") {
+		t.Errorf("prompt is missing the failure: %q", prompt)
+	}
+	if strings.Contains(prompt, "go build") {
+		t.Errorf("prompt includes a passing command: %q", prompt)
+	}
+}
+
+func TestDetectTestCommand(t *testing.T) {
+	tests := []struct {
+		files []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"go.mod"}, "go test ./..."},
+		{[]string{"package.json"}, "npm test"},
+		{[]string{"Cargo.toml", "Makefile"}, "cargo test"},
+		{[]string{"pyproject.toml"}, "pytest"},
+		{[]string{"Makefile"}, "make test"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := DetectTestCommand(dir); got != tt.want {
+				t.Errorf("DetectTestCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTail(t *testing.T) {
+	output := strings.Repeat("line\n", 100) + "FAIL\n"
+	got := Tail(output, 20)
+	if !strings.HasPrefix(got, "...(truncated)\n") || !strings.HasSuffix(got, "FAIL\n") {
+		t.Errorf("Tail() = %q", got)
+	}
+	if Tail("short", 20) != "short" {
+		t.Error("Tail() changed output under the limit")
+	}
+}
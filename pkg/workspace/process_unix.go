@@ -0,0 +1,23 @@
+//go:build !windows
+
+package workspace
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given PID is running
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 only checks the process exists; Go reports a missing one as
+	// ErrProcessDone
+	return !errors.Is(process.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
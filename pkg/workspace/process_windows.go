@@ -0,0 +1,31 @@
+//go:build windows
+
+package workspace
+
+import (
+	"errors"
+	"syscall"
+)
+
+// stillActive is the exit code Windows reports for a running process
+const stillActive = 259
+
+// processAlive reports whether a process with the given PID is running.
+// Windows can't send signal 0, so it asks for the process's exit code.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Another user's process can't be opened, but it exists
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return true // Keep the worktree when unsure
+	}
+	return code == stillActive
+}
@@ -0,0 +1,273 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DirName is the directory inside the state directory that holds worktrees
+const DirName = "worktrees"
+
+// BranchPrefix prefixes the branches created for isolated runs
+const BranchPrefix = "ai-dispatcher/"
+
+// gitTimeout bounds every git command
+const gitTimeout = time.Minute
+
+// Worktree is a temporary git worktree an isolated run executes in
+type Worktree struct {
+	ID       string    `json:"id"`
+	RepoRoot string    `json:"repo_root"` // Top level of the repository the worktree belongs to
+	Path     string    `json:"path"`
+	Branch   string    `json:"branch"`
+	Base     string    `json:"base"` // Commit the worktree started from, including uncommitted changes
+	PID      int       `json:"pid"`  // Process that owns the worktree
+	Created  time.Time `json:"created"`
+
+	marker string
+}
+
+// wipMessage is the message of the commit carrying uncommitted changes into
+// a worktree
+const wipMessage = "ai-dispatcher: uncommitted changes"
+
+// Create adds a worktree for the repository containing dir on a new branch
+// from HEAD. Uncommitted changes to tracked files are carried over as the
+// branch's first commit, so the tool sees the same code as the caller. A
+// marker in stateDir lets Cleanup remove the worktree if this process dies
+// before removing it.
+func Create(dir, stateDir, id string) (*Worktree, error) {
+	root, err := git(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("isolated runs need a git repository: %w", err)
+	}
+	head, err := git(root, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	wip, err := gitRaw(root, nil, "diff", "--binary", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uncommitted changes: %w", err)
+	}
+
+	wt := &Worktree{
+		ID:       id,
+		RepoRoot: root,
+		Path:     filepath.Join(stateDir, DirName, id),
+		Branch:   BranchPrefix + id,
+		Base:     head,
+		PID:      os.Getpid(),
+		Created:  time.Now(),
+		marker:   filepath.Join(stateDir, DirName, id+".json"),
+	}
+
+	// Write the marker first, so a crash during creation is cleaned up too
+	if err := wt.writeMarker(); err != nil {
+		return nil, err
+	}
+	if _, err := git(root, nil, "worktree", "add", "-b", wt.Branch, wt.Path, head); err != nil {
+		os.Remove(wt.marker)
+		return nil, fmt.Errorf("failed to create worktree: %w", err)
+	}
+	if wip != "" {
+		if err := wt.commitWIP(wip); err != nil {
+			wt.Remove()
+			return nil, err
+		}
+	}
+
+	return wt, nil
+}
+
+// commitWIP applies the caller's uncommitted changes to the worktree and
+// commits them, so the tool's changes are diffed from there
+func (w *Worktree) commitWIP(diff string) error {
+	if _, err := git(w.Path, strings.NewReader(diff), "apply", "--index", "--whitespace=nowarn", "-"); err != nil {
+		return fmt.Errorf("failed to carry over uncommitted changes: %w", err)
+	}
+	if err := w.commit(wipMessage); err != nil {
+		return fmt.Errorf("failed to commit uncommitted changes: %w", err)
+	}
+	base, err := git(w.Path, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to resolve worktree HEAD: %w", err)
+	}
+	w.Base = base
+	return w.writeMarker()
+}
+
+// Diff returns the changes made in the worktree since it was created,
+// including new files
+func (w *Worktree) Diff() (string, error) {
+	if _, err := git(w.Path, nil, "add", "-A"); err != nil {
+		return "", fmt.Errorf("failed to stage worktree changes: %w", err)
+	}
+	diff, err := gitRaw(w.Path, nil, "diff", "--cached", "--binary", w.Base)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff worktree: %w", err)
+	}
+	return diff, nil
+}
+
+// DiffStat returns a summary of the changes made in the worktree
+func (w *Worktree) DiffStat() (string, error) {
+	if _, err := git(w.Path, nil, "add", "-A"); err != nil {
+		return "", fmt.Errorf("failed to stage worktree changes: %w", err)
+	}
+	stat, err := git(w.Path, nil, "diff", "--cached", "--stat", w.Base)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff worktree: %w", err)
+	}
+	return stat, nil
+}
+
+// Apply applies the worktree's changes to the repository's working tree and
+// removes the worktree
+func (w *Worktree) Apply() error {
+	diff, err := w.Diff()
+	if err != nil {
+		return err
+	}
+	if diff != "" {
+		if _, err := git(w.RepoRoot, strings.NewReader(diff), "apply", "--whitespace=nowarn", "-"); err != nil {
+			return fmt.Errorf("failed to apply changes: %w", err)
+		}
+	}
+	return w.Remove()
+}
+
+// Keep commits the worktree's changes to its branch and removes the
+// worktree, leaving the branch for the caller to merge or inspect
+func (w *Worktree) Keep(message string) error {
+	if _, err := git(w.Path, nil, "add", "-A"); err != nil {
+		return fmt.Errorf("failed to stage worktree changes: %w", err)
+	}
+	if err := w.commit(message); err != nil {
+		return fmt.Errorf("failed to commit worktree changes: %w", err)
+	}
+
+	if _, err := git(w.RepoRoot, nil, "worktree", "remove", "--force", w.Path); err != nil {
+		return fmt.Errorf("failed to remove worktree: %w", err)
+	}
+	os.Remove(w.marker)
+	return nil
+}
+
+// commit commits what is staged in the worktree, skipping hooks
+func (w *Worktree) commit(message string) error {
+	args := []string{"commit", "--allow-empty", "--no-verify", "-m", message}
+	if _, err := git(w.Path, nil, "var", "GIT_COMMITTER_IDENT"); err != nil {
+		// No identity configured: commit as the dispatcher rather than fail
+		args = append([]string{"-c", "user.name=ai-dispatcher", "-c", "user.email=ai-dispatcher@localhost"}, args...)
+	}
+	_, err := git(w.Path, nil, args...)
+	return err
+}
+
+// Remove deletes the worktree and its branch, discarding any changes
+func (w *Worktree) Remove() error {
+	var errs []error
+	if _, err := os.Stat(w.Path); err == nil {
+		if _, err := git(w.RepoRoot, nil, "worktree", "remove", "--force", w.Path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove worktree: %w", err))
+		}
+	}
+	// Also forget worktrees whose directory is already gone
+	git(w.RepoRoot, nil, "worktree", "prune")
+	if _, err := git(w.RepoRoot, nil, "rev-parse", "--verify", "--quiet", "refs/heads/"+w.Branch); err == nil {
+		if _, err := git(w.RepoRoot, nil, "branch", "-D", w.Branch); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete branch %s: %w", w.Branch, err))
+		}
+	}
+	if err := os.Remove(w.marker); err != nil && !errors.Is(err, os.ErrNotExist) {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Cleanup removes the worktrees left behind by processes that are no longer
+// running, and returns the IDs it removed
+func Cleanup(stateDir string) ([]string, error) {
+	markers, err := filepath.Glob(filepath.Join(stateDir, DirName, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	var errs []error
+	for _, marker := range markers {
+		data, err := os.ReadFile(marker)
+		if err != nil {
+			continue
+		}
+		var wt Worktree
+		if err := json.Unmarshal(data, &wt); err != nil {
+			os.Remove(marker)
+			continue
+		}
+		wt.marker = marker
+
+		if wt.PID != os.Getpid() && processAlive(wt.PID) {
+			continue
+		}
+		if err := wt.Remove(); err != nil {
+			errs = append(errs, fmt.Errorf("worktree %s: %w", wt.ID, err))
+			continue
+		}
+		removed = append(removed, wt.ID)
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// writeMarker records the worktree so Cleanup can find it
+func (w *Worktree) writeMarker() error {
+	if err := os.MkdirAll(filepath.Dir(w.marker), 0o755); err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("failed to encode worktree marker: %w", err)
+	}
+	if err := os.WriteFile(w.marker, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write worktree marker: %w", err)
+	}
+	return nil
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(dir string, stdin *strings.Reader, args ...string) (string, error) {
+	out, err := gitRaw(dir, stdin, args...)
+	return strings.TrimSpace(out), err
+}
+
+// gitRaw runs a git command in dir and returns its output unchanged
+func gitRaw(dir string, stdin *strings.Reader, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,173 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo creates a git repository with one committed file
+func initRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "test"},
+		{"config", "user.email", "test@example.com"},
+	} {
+		if _, err := git(dir, nil, args...); err != nil {
+			t.Skipf("git unavailable: %v", err)
+		}
+	}
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	if _, err := git(dir, nil, "add", "-A"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(dir, nil, "commit", "-q", "-m", "initial"); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWorktreeApply(t *testing.T) {
+	repo := initRepo(t)
+	state := t.TempDir()
+
+	// Uncommitted changes are visible in the worktree
+	writeFile(t, filepath.Join(repo, "main.go"), "package main\n\n// dirty\n")
+
+	wt, err := Create(repo, state, "run1")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got := readFile(t, filepath.Join(wt.Path, "main.go")); !strings.Contains(got, "// dirty") {
+		t.Errorf("worktree main.go = %q, want the uncommitted change", got)
+	}
+	// The uncommitted changes are an ordinary commit on top of HEAD
+	head, err := git(repo, nil, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parents, err := git(repo, nil, "rev-list", "--parents", "-n", "1", wt.Base); err != nil || parents != wt.Base+" "+head {
+		t.Errorf("base %s has parents %q (%v), want only HEAD %s", wt.Base, parents, err, head)
+	}
+	if got := readFile(t, filepath.Join(repo, "main.go")); !strings.Contains(got, "// dirty") {
+		t.Error("Create() touched the main working tree")
+	}
+
+	writeFile(t, filepath.Join(wt.Path, "main.go"), "package main\n\n// dirty\n// tool edit\n")
+	writeFile(t, filepath.Join(wt.Path, "new.go"), "package main\n")
+
+	diff, err := wt.Diff()
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !strings.Contains(diff, "+// tool edit") || !strings.Contains(diff, "new.go") || strings.Contains(diff, "+// dirty") {
+		t.Errorf("Diff() = %q, want only the tool's changes", diff)
+	}
+
+	if err := wt.Apply(); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := readFile(t, filepath.Join(repo, "main.go")); !strings.Contains(got, "// tool edit") {
+		t.Errorf("main.go = %q, want the tool's change applied", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "new.go")); err != nil {
+		t.Errorf("new.go not applied: %v", err)
+	}
+	if _, err := os.Stat(wt.Path); !os.IsNotExist(err) {
+		t.Errorf("worktree still exists after Apply()")
+	}
+	if _, err := git(repo, nil, "rev-parse", "--verify", "--quiet", "refs/heads/"+wt.Branch); err == nil {
+		t.Errorf("branch %s still exists after Apply()", wt.Branch)
+	}
+}
+
+func TestWorktreeKeep(t *testing.T) {
+	repo := initRepo(t)
+	wt, err := Create(repo, t.TempDir(), "run2")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if head, err := git(repo, nil, "rev-parse", "HEAD"); err != nil || wt.Base != head {
+		t.Errorf("Base = %s, want HEAD %s of a clean repository", wt.Base, head)
+	}
+	writeFile(t, filepath.Join(wt.Path, "main.go"), "package main\n\n// kept\n")
+
+	if err := wt.Keep("ai-dispatcher: keep"); err != nil {
+		t.Fatalf("Keep() error = %v", err)
+	}
+	if got := readFile(t, filepath.Join(repo, "main.go")); strings.Contains(got, "// kept") {
+		t.Error("Keep() changed the main working tree")
+	}
+	content, err := git(repo, nil, "show", wt.Branch+":main.go")
+	if err != nil || !strings.Contains(content, "// kept") {
+		t.Errorf("branch content = %q (%v), want the kept change", content, err)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	repo := initRepo(t)
+	state := t.TempDir()
+
+	wt, err := Create(repo, state, "crashed")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Pretend the owning process died
+	wt.PID = 0
+	if err := wt.writeMarker(); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Cleanup(state)
+	if err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0] != "crashed" {
+		t.Errorf("Cleanup() removed %v, want [crashed]", removed)
+	}
+	if _, err := os.Stat(wt.Path); !os.IsNotExist(err) {
+		t.Error("worktree still exists after Cleanup()")
+	}
+}
+
+func TestCleanupSkipsRunning(t *testing.T) {
+	repo := initRepo(t)
+	state := t.TempDir()
+
+	wt, err := Create(repo, state, "running")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer wt.Remove()
+
+	// The parent of the test process is alive
+	wt.PID = os.Getppid()
+	if err := wt.writeMarker(); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Cleanup(state)
+	if err != nil || len(removed) != 0 {
+		t.Errorf("Cleanup() = %v, %v; want nothing removed", removed, err)
+	}
+}
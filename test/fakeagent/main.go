@@ -0,0 +1,106 @@
+// Command fakeagent stands in for claude, codex and opencode in end-to-end
+// tests. It replays a recorded output stream and is configured through the
+// environment:
+//
+//	FAKE_AGENT_STREAM  file replayed to stdout, line by line
+//	FAKE_AGENT_DELAY   pause before each line (a Go duration, like 20ms)
+//	FAKE_AGENT_STDERR  text written to stderr after the stream
+//	FAKE_AGENT_HANG    how long to keep running after the stream, to test timeouts
+//	FAKE_AGENT_EXIT    exit code (0 when unset)
+//	FAKE_AGENT_ARGS    file the arguments are written to, one per line
+//	FAKE_AGENT_WRITE   file written in the working directory, as an edit would
+//
+// Build it with mocks.BuildFakeAgent.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "fakeagent: %v\n", err)
+		os.Exit(125)
+	}
+}
+
+func run() error {
+	if path := os.Getenv("FAKE_AGENT_ARGS"); path != "" {
+		args := strings.Join(os.Args[1:], "\n") + "\n"
+		if err := os.WriteFile(path, []byte(args), 0o644); err != nil {
+			return err
+		}
+	}
+
+	if name := os.Getenv("FAKE_AGENT_WRITE"); name != "" {
+		if err := os.WriteFile(name, []byte("written by fakeagent\n"), 0o644); err != nil {
+			return err
+		}
+	}
+
+	delay, err := duration("FAKE_AGENT_DELAY")
+	if err != nil {
+		return err
+	}
+	if path := os.Getenv("FAKE_AGENT_STREAM"); path != "" {
+		if err := replay(path, delay); err != nil {
+			return err
+		}
+	}
+
+	if text := os.Getenv("FAKE_AGENT_STDERR"); text != "" {
+		fmt.Fprintln(os.Stderr, text)
+	}
+
+	hang, err := duration("FAKE_AGENT_HANG")
+	if err != nil {
+		return err
+	}
+	time.Sleep(hang)
+
+	code := 0
+	if value := os.Getenv("FAKE_AGENT_EXIT"); value != "" {
+		if code, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid FAKE_AGENT_EXIT %q: %w", value, err)
+		}
+	}
+	os.Exit(code)
+	return nil
+}
+
+// replay copies the stream to stdout a line at a time, pausing before each
+func replay(path string, delay time.Duration) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		time.Sleep(delay)
+		if _, err := fmt.Println(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// duration reads a duration from the environment (zero when unset)
+func duration(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	return d, nil
+}
@@ -19,20 +19,19 @@ func TestFullRoutingPipeline(t *testing.T) {
 		}
 
 		// Analyze complexity
-		analyzer := analyzers.NewComplexityAnalyzer(mockTrackers)
+		analyzer := analyzers.NewComplexityAnalyzer()
 		analysis, err := analyzer.AnalyzeComplexity("fix typo in README")
 		if err != nil {
 			t.Fatalf("AnalyzeComplexity() error = %v", err)
 		}
 
-		// Note: LLM analysis (mocked) returns "medium", but that's okay for this test
 		// We're primarily testing that the router selects the free tool
 		if analysis.Level == "" {
 			t.Error("Analysis level should not be empty")
 		}
 
 		// Make routing decision
-		engine := router.NewDecisionEngine(mockTrackers)
+		engine := router.NewDecisionEngine(mockTrackers, nil)
 		decision, err := engine.MakeDecision(analysis, "")
 		if err != nil {
 			t.Fatalf("MakeDecision() error = %v", err)
@@ -56,14 +55,14 @@ func TestFullRoutingPipeline(t *testing.T) {
 			createMockTracker("Claude Code", trackers.ClaudeCodeTool, 50.0, 3.30),
 		}
 
-		analyzer := analyzers.NewComplexityAnalyzer(mockTrackers)
+		analyzer := analyzers.NewComplexityAnalyzer()
 		analysis, err := analyzer.AnalyzeComplexity("refactor entire authentication system")
 		if err != nil {
 			t.Fatalf("AnalyzeComplexity() error = %v", err)
 		}
 
 		// Force Claude Code
-		engine := router.NewDecisionEngine(mockTrackers)
+		engine := router.NewDecisionEngine(mockTrackers, nil)
 		decision, err := engine.MakeDecision(analysis, "claude-code")
 		if err != nil {
 			t.Fatalf("MakeDecision() error = %v", err)
@@ -85,13 +84,13 @@ func TestFullRoutingPipeline(t *testing.T) {
 			createMockTracker("Claude Code", trackers.ClaudeCodeTool, 2.0, 6.47), // 2% available
 		}
 
-		analyzer := analyzers.NewComplexityAnalyzer(unavailableTrackers)
+		analyzer := analyzers.NewComplexityAnalyzer()
 		analysis, err := analyzer.AnalyzeComplexity("simple task")
 		if err != nil {
 			t.Fatalf("AnalyzeComplexity() error = %v", err)
 		}
 
-		engine := router.NewDecisionEngine(unavailableTrackers)
+		engine := router.NewDecisionEngine(unavailableTrackers, nil)
 		_, err = engine.MakeDecision(analysis, "")
 		if err == nil {
 			t.Error("Expected error when no tools available, got nil")
@@ -144,7 +143,7 @@ func TestToolStatus(t *testing.T) {
 		createMockTracker("Claude Code", trackers.ClaudeCodeTool, 50.0, 3.30),
 	}
 
-	engine := router.NewDecisionEngine(mockTrackers)
+	engine := router.NewDecisionEngine(mockTrackers, nil)
 	statuses, err := engine.GetToolStatus()
 	if err != nil {
 		t.Fatalf("GetToolStatus() error = %v", err)
@@ -0,0 +1,75 @@
+package mocks
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// FakeAgent is how a test run of the fake agent behaves (see test/fakeagent)
+type FakeAgent struct {
+	Stream   string // Recorded stream file replayed to stdout
+	Delay    string // Pause before each line, like "20ms"
+	Stderr   string // Written to stderr after the stream
+	Hang     string // How long to keep running after the stream
+	ExitCode int
+	ArgsFile string // File the arguments are written to
+	Write    string // File written in the working directory
+}
+
+var fakeAgent struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+// BuildFakeAgent builds the fake agent once per test binary and returns its path
+func BuildFakeAgent(t *testing.T) string {
+	t.Helper()
+	fakeAgent.once.Do(func() {
+		dir, err := os.MkdirTemp("", "fakeagent")
+		if err != nil {
+			fakeAgent.err = err
+			return
+		}
+		path := filepath.Join(dir, "fakeagent")
+		if runtime.GOOS == "windows" {
+			path += ".exe"
+		}
+		out, err := exec.Command("go", "build", "-o", path, "github.com/crlian/ai-dispatcher/test/fakeagent").CombinedOutput()
+		if err != nil {
+			fakeAgent.err = fmt.Errorf("%w\n%s", err, out)
+			return
+		}
+		fakeAgent.path = path
+	})
+	if fakeAgent.err != nil {
+		t.Fatalf("failed to build the fake agent: %v", fakeAgent.err)
+	}
+	return fakeAgent.path
+}
+
+// Setenv configures the fake agent for the rest of the test
+func (f *FakeAgent) Setenv(t *testing.T) {
+	t.Helper()
+	exitCode := ""
+	if f.ExitCode != 0 {
+		exitCode = strconv.Itoa(f.ExitCode)
+	}
+	for name, value := range map[string]string{
+		"FAKE_AGENT_STREAM": f.Stream,
+		"FAKE_AGENT_DELAY":  f.Delay,
+		"FAKE_AGENT_STDERR": f.Stderr,
+		"FAKE_AGENT_HANG":   f.Hang,
+		"FAKE_AGENT_EXIT":   exitCode,
+		"FAKE_AGENT_ARGS":   f.ArgsFile,
+		"FAKE_AGENT_WRITE":  f.Write,
+	} {
+		t.Setenv(name, value)
+	}
+}